@@ -16,8 +16,14 @@ func NewServerOptionalMTLSCreds(caCertPool *x509.CertPool, certs ...tls.Certific
 }
 
 func NewClientMTLSCreds(caCertPool *x509.CertPool, certs ...tls.Certificate) credentials.TransportCredentials {
-	return credentials.NewTLS(&tls.Config{
+	return credentials.NewTLS(NewClientTLSConfig(caCertPool, certs...))
+}
+
+// NewClientTLSConfig returns a TLS config verifying the server against given CA pool
+// and presenting given certificates to the server, eg. for AMQP or HTTP clients.
+func NewClientTLSConfig(caCertPool *x509.CertPool, certs ...tls.Certificate) *tls.Config {
+	return &tls.Config{
 		Certificates: certs,
 		RootCAs:      caCertPool,
-	})
+	}
 }
@@ -2,7 +2,9 @@ package broker
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"mime"
+	"time"
 
 	"github.com/krixlion/dev_forum-lib/event"
 	"github.com/krixlion/dev_forum-lib/logging"
@@ -11,24 +13,38 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	_ event.DeliveryConsumer = (*Broker)(nil)
+	_ event.PatternConsumer  = (*Broker)(nil)
+	_ event.Scheduler        = (*Broker)(nil)
+)
+
 // Broker is a wrapper for rabbitmq.RabbitMQ.
 type Broker struct {
 	messageQueue *rabbitmq.RabbitMQ
 	logger       logging.Logger
 	tracer       trace.Tracer
+	opts         options
 }
 
-func NewBroker(mq *rabbitmq.RabbitMQ, logger logging.Logger, tracer trace.Tracer) *Broker {
-	return &Broker{
+func NewBroker(mq *rabbitmq.RabbitMQ, logger logging.Logger, tracer trace.Tracer, opts ...Option) *Broker {
+	b := &Broker{
 		messageQueue: mq,
 		logger:       logger,
 		tracer:       tracer,
+		opts:         defaultOptions(),
 	}
+
+	for _, opt := range opts {
+		opt.apply(&b.opts)
+	}
+
+	return b
 }
 
 // ResilientPublish returns an error only if the queue is full or if it failed to serialize the event.
 func (b *Broker) ResilientPublish(e event.Event) error {
-	msg, err := messageFromEvent(e)
+	msg, err := messageFromEvent(e, b.opts.codec)
 	if err != nil {
 		return err
 	}
@@ -39,9 +55,9 @@ func (b *Broker) ResilientPublish(e event.Event) error {
 func (b *Broker) Publish(ctx context.Context, e event.Event) (err error) {
 	ctx, span := b.tracer.Start(ctx, "broker.Publish", trace.WithSpanKind(trace.SpanKindProducer))
 	defer span.End()
-	defer tracing.SetSpanErr(span, err)
+	defer func() { tracing.SetSpanErr(span, err) }()
 
-	msg, err := messageFromEvent(e)
+	msg, err := messageFromEvent(e, b.opts.codec)
 	if err != nil {
 		return err
 	}
@@ -49,45 +65,209 @@ func (b *Broker) Publish(ctx context.Context, e event.Event) (err error) {
 	return b.messageQueue.Publish(ctx, msg)
 }
 
-func (b *Broker) Consume(ctx context.Context, queue string, eventType event.EventType) (_ <-chan event.Event, err error) {
+// PublishAt publishes the event at given time using delay queues.
+// Requires scheduling to be enabled on the underlying RabbitMQ using rabbitmq.WithScheduling.
+func (b *Broker) PublishAt(ctx context.Context, e event.Event, at time.Time) (err error) {
+	ctx, span := b.tracer.Start(ctx, "broker.PublishAt", trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	msg, err := messageFromEvent(e, b.opts.codec)
+	if err != nil {
+		return err
+	}
+
+	return b.messageQueue.PublishAt(ctx, msg, at)
+}
+
+// PublishAfter publishes the event after given delay. See PublishAt.
+func (b *Broker) PublishAfter(ctx context.Context, e event.Event, delay time.Duration) error {
+	return b.PublishAt(ctx, e, time.Now().Add(delay))
+}
+
+// Cancel cancels publishing of the event with given ID scheduled using a broker sharing
+// the consumer name. Cancelling an event which is not scheduled has no effect.
+// See rabbitmq.RabbitMQ.CancelScheduled for how long cancellations are kept.
+func (b *Broker) Cancel(ctx context.Context, id string) error {
+	return b.messageQueue.CancelScheduled(ctx, id)
+}
+
+// Consume returns a channel receiving events of the given type from the queue.
+// Each event is acknowledged as soon as it is received from the returned channel,
+// so events which are being handled when the process stops are lost. For at-least-once
+// processing use ConsumeDeliveries together with dispatcher.Dispatcher.AddDeliveryProvider.
+// Events which were not received before the context got cancelled are requeued.
+func (b *Broker) Consume(ctx context.Context, queue string, eventType event.EventType) (<-chan event.Event, error) {
+	deliveries, err := b.ConsumeDeliveries(ctx, queue, eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	return eventsFromDeliveries(ctx, b.logger, deliveries), nil
+}
+
+// ConsumeDeliveries returns a channel receiving events of the given type from the queue.
+// Messages are decoded using the codec matching their content type.
+// Received events are upcasted to the latest version of their type.
+// Every delivery has to be settled by the caller. Messages of unsupported content
+// types, messages which cannot be unmarshaled into an event, upcasted or whose body
+// does not match the registered payload type are dead-lettered if dead-lettering
+// is enabled for the queue and discarded otherwise.
+func (b *Broker) ConsumeDeliveries(ctx context.Context, queue string, eventType event.EventType) (_ <-chan event.Delivery, err error) {
 	ctx, span := b.tracer.Start(ctx, "broker.Consume init")
 	defer span.End()
-	defer tracing.SetSpanErr(span, err)
+	defer func() { tracing.SetSpanErr(span, err) }()
 
 	r, err := routeFromEvent(eventType)
 	if err != nil {
 		return nil, err
 	}
 
-	messages, err := b.messageQueue.Consume(ctx, queue, r)
+	return b.consumeDeliveries(ctx, queue, []rabbitmq.Route{r})
+}
+
+// ConsumePattern returns a channel receiving events of all types matching the pattern from the queue.
+// The queue is bound using topic routing keys, eg. "article-*" is bound as "article.event.*".
+// Patterns with a wildcard noun are bound to every exchange set using WithExchanges
+// and ErrNoExchanges is returned if there are none.
+// Events are acknowledged the same way as in Consume.
+func (b *Broker) ConsumePattern(ctx context.Context, queue string, pattern event.Pattern) (<-chan event.Event, error) {
+	deliveries, err := b.ConsumePatternDeliveries(ctx, queue, pattern)
 	if err != nil {
 		return nil, err
 	}
 
-	events := make(chan event.Event)
+	return eventsFromDeliveries(ctx, b.logger, deliveries), nil
+}
+
+// ConsumePatternDeliveries works like ConsumePattern except that every received delivery
+// has to be settled by the caller. See ConsumeDeliveries.
+func (b *Broker) ConsumePatternDeliveries(ctx context.Context, queue string, pattern event.Pattern) (_ <-chan event.Delivery, err error) {
+	ctx, span := b.tracer.Start(ctx, "broker.Consume init")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	routes, err := routesFromPattern(pattern, b.opts.exchanges)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.consumeDeliveries(ctx, queue, routes)
+}
+
+func (b *Broker) consumeDeliveries(ctx context.Context, queue string, routes []rabbitmq.Route) (<-chan event.Delivery, error) {
+	messages, err := b.messageQueue.ConsumeDeliveries(ctx, queue, routes[0], routes[1:]...)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make(chan event.Delivery)
 	go func() {
-		for {
+		defer close(deliveries)
+		for msg := range messages {
+			e, err := b.eventFromDelivery(msg)
+			if err != nil {
+				continue
+			}
+
 			select {
+			case deliveries <- event.Delivery{Event: e, Acknowledger: msg}:
 			case <-ctx.Done():
+				if err := msg.Nack(true); err != nil {
+					b.logger.Log(ctx, "Failed to requeue message", "err", err)
+				}
 				return
-			case msg := <-messages:
-				func() {
-					ctx, span := b.tracer.Start(tracing.InjectMetadataIntoContext(context.Background(), msg.Headers), "broker.Consume", trace.WithSpanKind(trace.SpanKindConsumer))
-					defer span.End()
-
-					e := event.Event{}
-					if err := json.Unmarshal(msg.Body, &e); err != nil {
-						tracing.SetSpanErr(span, err)
-						b.logger.Log(ctx, "Failed to process message", "err", err)
-						return
-					}
-
-					e.Metadata = tracing.ExtractMetadataFromContext(ctx)
-					events <- e
-				}()
 			}
 		}
 	}()
 
-	return events, nil
+	return deliveries, nil
+}
+
+// eventFromDelivery unmarshals the delivered message into an event.
+// Messages which fail to unmarshal, upcast or validate are dead-lettered.
+func (b *Broker) eventFromDelivery(msg rabbitmq.Delivery) (_ event.Event, err error) {
+	ctx, span := b.tracer.Start(tracing.InjectMetadataIntoContext(context.Background(), msg.Headers), "broker.Consume", trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	e := event.Event{}
+	if err := b.unmarshal(msg, &e); err != nil {
+		b.logger.Log(ctx, "Failed to process message", "err", err)
+		if err := msg.DeadLetter(fmt.Sprintf("failed to unmarshal event: %v", err)); err != nil {
+			b.logger.Log(ctx, "Failed to dead-letter message", "err", err)
+		}
+		return event.Event{}, err
+	}
+
+	// Events published by producers not aware of event IDs may carry them only in the message properties.
+	if e.Id == "" {
+		e.Id = msg.MessageId
+	}
+	if e.CorrelationId == "" {
+		e.CorrelationId = msg.CorrelationId
+	}
+
+	e, err = event.Upcast(e)
+	if err == nil {
+		err = event.Validate(e)
+	}
+
+	if err != nil {
+		b.logger.Log(ctx, "Received invalid event", "err", err)
+		if err := msg.DeadLetter(err.Error()); err != nil {
+			b.logger.Log(ctx, "Failed to dead-letter message", "err", err)
+		}
+		return event.Event{}, err
+	}
+
+	e.Metadata = tracing.ExtractMetadataFromContext(ctx)
+	return e, nil
+}
+
+// unmarshal decodes the message using the codec matching its content type.
+// Parameters of the content type, eg. a charset, are ignored.
+// Messages with no content type are assumed to be JSON.
+func (b *Broker) unmarshal(msg rabbitmq.Delivery, e *event.Event) error {
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = rabbitmq.ContentTypeJson
+	}
+
+	mediaType, _, err := mime.ParseMediaType(string(contentType))
+	if err != nil {
+		return fmt.Errorf("%w: %q: %w", ErrUnsupportedContentType, contentType, err)
+	}
+
+	codec, ok := b.opts.codecs[rabbitmq.ContentType(mediaType)]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+
+	return codec.Unmarshal(msg.Body, e)
+}
+
+// eventsFromDeliveries passes the events through the returned channel and acknowledges
+// each of them once it is received. Events which were not received before
+// the context got cancelled are requeued. The returned channel is closed
+// when the deliveries channel is closed.
+func eventsFromDeliveries(ctx context.Context, logger logging.Logger, deliveries <-chan event.Delivery) <-chan event.Event {
+	events := make(chan event.Event)
+	go func() {
+		defer close(events)
+		for d := range deliveries {
+			select {
+			case events <- d.Event:
+				if err := d.Ack(); err != nil {
+					logger.Log(ctx, "Failed to acknowledge event", "err", err)
+				}
+			case <-ctx.Done():
+				if err := d.Nack(true); err != nil {
+					logger.Log(ctx, "Failed to requeue event", "err", err)
+				}
+			}
+		}
+	}()
+
+	return events
 }
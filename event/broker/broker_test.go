@@ -0,0 +1,63 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	rabbitmq "github.com/krixlion/dev_forum-lib/rabbitmq"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestBroker_unmarshal(t *testing.T) {
+	tests := []struct {
+		desc        string
+		contentType rabbitmq.ContentType
+		want        event.Event
+		wantErr     error
+	}{
+		{
+			desc:        "Test if content type parameters are ignored",
+			contentType: "application/json; charset=utf-8",
+			want:        event.Event{Version: 3},
+		},
+		{
+			desc:        "Test if content type is matched case-insensitively",
+			contentType: "Application/JSON",
+			want:        event.Event{Version: 3},
+		},
+		{
+			desc: "Test if messages with no content type are assumed to be JSON",
+			want: event.Event{Version: 3},
+		},
+		{
+			desc:        "Test if returns ErrUnsupportedContentType on unknown content type",
+			contentType: "text/xml",
+			wantErr:     ErrUnsupportedContentType,
+		},
+		{
+			desc:        "Test if returns ErrUnsupportedContentType on malformed content type",
+			contentType: "application/json; charset",
+			wantErr:     ErrUnsupportedContentType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			b := NewBroker(nil, nulls.NullLogger{}, noop.NewTracerProvider().Tracer(""))
+			msg := rabbitmq.Delivery{Message: rabbitmq.Message{ContentType: tt.contentType, Body: []byte(`{"version":3}`)}}
+
+			got := event.Event{}
+			err := b.unmarshal(msg, &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Broker.unmarshal():\n error = %v\n want = %v", err, tt.wantErr)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("Broker.unmarshal():\n got = %+v\n want = %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	rabbitmq "github.com/krixlion/dev_forum-lib/rabbitmq"
+)
+
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// Codec serializes events into message bodies.
+// The codec's content type is carried in published messages,
+// so that consumers are able to pick a matching codec.
+type Codec interface {
+	ContentType() rabbitmq.ContentType
+	Marshal(event.Event) ([]byte, error)
+	Unmarshal([]byte, *event.Event) error
+}
+
+// defaultCodecs returns all codecs implemented in this package keyed by their content type.
+func defaultCodecs() map[rabbitmq.ContentType]Codec {
+	codecs := make(map[rabbitmq.ContentType]Codec)
+	for _, codec := range []Codec{JSONCodec{}, ProtobufCodec{}, MsgpackCodec{}} {
+		codecs[codec.ContentType()] = codec
+	}
+	return codecs
+}
+
+// JSONCodec serializes events into JSON. It is the default codec.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() rabbitmq.ContentType {
+	return rabbitmq.ContentTypeJson
+}
+
+func (JSONCodec) Marshal(e event.Event) ([]byte, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON tags on event.Event, err: %v", err)
+	}
+	return body, nil
+}
+
+func (JSONCodec) Unmarshal(data []byte, e *event.Event) error {
+	return json.Unmarshal(data, e)
+}
@@ -0,0 +1,565 @@
+package broker
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	rabbitmq "github.com/krixlion/dev_forum-lib/rabbitmq"
+)
+
+var errInvalidMsgpack = errors.New("invalid msgpack event")
+
+// MsgpackCodec serializes events into MessagePack.
+// The event is encoded as a map keyed the same way as its JSON representation,
+// the body is encoded as binary and the timestamp using the timestamp extension type.
+type MsgpackCodec struct{}
+
+// Keys of the msgpack event map.
+const (
+	msgpackAggregateId = "aggregate_id"
+	msgpackType        = "type"
+	msgpackBody        = "body"
+	msgpackTimestamp   = "timestamp"
+	msgpackMetadata    = "metadata"
+	msgpackVersion     = "version"
+
+	msgpackId                  = "id"
+	msgpackAggregateInstanceId = "aggregate_instance_id"
+	msgpackCorrelationId       = "correlation_id"
+	msgpackCausationId         = "causation_id"
+)
+
+// Format codes of the msgpack specification.
+const (
+	msgpackNil          = 0xc0
+	msgpackFalse        = 0xc2
+	msgpackTrue         = 0xc3
+	msgpackBin8         = 0xc4
+	msgpackBin16        = 0xc5
+	msgpackBin32        = 0xc6
+	msgpackExt8         = 0xc7
+	msgpackExt16        = 0xc8
+	msgpackExt32        = 0xc9
+	msgpackFloat32      = 0xca
+	msgpackFloat64      = 0xcb
+	msgpackUint8        = 0xcc
+	msgpackUint16       = 0xcd
+	msgpackUint32       = 0xce
+	msgpackUint64       = 0xcf
+	msgpackInt8         = 0xd0
+	msgpackInt16        = 0xd1
+	msgpackInt32        = 0xd2
+	msgpackInt64        = 0xd3
+	msgpackFixExt1      = 0xd4
+	msgpackFixExt16     = 0xd8
+	msgpackStr8         = 0xd9
+	msgpackStr16        = 0xda
+	msgpackStr32        = 0xdb
+	msgpackArray16      = 0xdc
+	msgpackArray32      = 0xdd
+	msgpackMap16        = 0xde
+	msgpackMap32        = 0xdf
+	msgpackTimestampExt = -1 // Extension type of timestamps.
+)
+
+// msgpackMaxDepth limits the nesting of unknown values, which are skipped recursively.
+const msgpackMaxDepth = 32
+
+func (MsgpackCodec) ContentType() rabbitmq.ContentType {
+	return rabbitmq.ContentTypeMsgpack
+}
+
+func (MsgpackCodec) Marshal(e event.Event) ([]byte, error) {
+	b := appendMsgpackMapHeader(nil, 10)
+
+	b = appendMsgpackString(b, msgpackId)
+	b = appendMsgpackString(b, e.Id)
+
+	b = appendMsgpackString(b, msgpackAggregateId)
+	b = appendMsgpackString(b, string(e.AggregateId))
+
+	b = appendMsgpackString(b, msgpackAggregateInstanceId)
+	b = appendMsgpackString(b, e.AggregateInstanceId)
+
+	b = appendMsgpackString(b, msgpackCorrelationId)
+	b = appendMsgpackString(b, e.CorrelationId)
+
+	b = appendMsgpackString(b, msgpackCausationId)
+	b = appendMsgpackString(b, e.CausationId)
+
+	b = appendMsgpackString(b, msgpackType)
+	b = appendMsgpackString(b, string(e.Type))
+
+	b = appendMsgpackString(b, msgpackBody)
+	b = appendMsgpackBinary(b, e.Body)
+
+	b = appendMsgpackString(b, msgpackTimestamp)
+	b = appendMsgpackTimestamp(b, e.Timestamp)
+
+	b = appendMsgpackString(b, msgpackMetadata)
+	b = appendMsgpackStringMap(b, e.Metadata)
+
+	b = appendMsgpackString(b, msgpackVersion)
+	b = appendMsgpackInt(b, int64(e.Version))
+
+	return b, nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, e *event.Event) error {
+	*e = event.Event{}
+	r := &msgpackReader{data: data}
+
+	n, err := r.readMapHeader()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		key, err := r.readString()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case msgpackId:
+			if e.Id, err = r.readString(); err != nil {
+				return err
+			}
+		case msgpackAggregateInstanceId:
+			if e.AggregateInstanceId, err = r.readString(); err != nil {
+				return err
+			}
+		case msgpackCorrelationId:
+			if e.CorrelationId, err = r.readString(); err != nil {
+				return err
+			}
+		case msgpackCausationId:
+			if e.CausationId, err = r.readString(); err != nil {
+				return err
+			}
+		case msgpackAggregateId:
+			v, err := r.readString()
+			if err != nil {
+				return err
+			}
+			e.AggregateId = event.AggregateId(v)
+		case msgpackType:
+			v, err := r.readString()
+			if err != nil {
+				return err
+			}
+			e.Type = event.EventType(v)
+		case msgpackBody:
+			if e.Body, err = r.readBinary(); err != nil {
+				return err
+			}
+		case msgpackTimestamp:
+			if e.Timestamp, err = r.readTimestamp(); err != nil {
+				return err
+			}
+		case msgpackMetadata:
+			if e.Metadata, err = r.readStringMap(); err != nil {
+				return err
+			}
+		case msgpackVersion:
+			v, err := r.readInt()
+			if err != nil {
+				return err
+			}
+			e.Version = int(v)
+		default:
+			if err := r.skip(0); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, msgpackMap16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, msgpackMap32), uint32(n))
+	}
+}
+
+func appendMsgpackStringMap(b []byte, m map[string]string) []byte {
+	if m == nil {
+		return append(b, msgpackNil)
+	}
+
+	b = appendMsgpackMapHeader(b, len(m))
+	for k, v := range m {
+		b = appendMsgpackString(b, k)
+		b = appendMsgpackString(b, v)
+	}
+	return b
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, msgpackStr8, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, msgpackStr16), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, msgpackStr32), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackBinary(b []byte, v []byte) []byte {
+	if v == nil {
+		return append(b, msgpackNil)
+	}
+
+	switch n := len(v); {
+	case n <= math.MaxUint8:
+		b = append(b, msgpackBin8, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, msgpackBin16), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, msgpackBin32), uint32(n))
+	}
+	return append(b, v...)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	if v >= 0 && v < 128 {
+		return append(b, byte(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, msgpackInt64), uint64(v))
+}
+
+// appendMsgpackTimestamp appends the time using the 96-bit format of the timestamp extension type.
+func appendMsgpackTimestamp(b []byte, t time.Time) []byte {
+	if t.IsZero() {
+		return append(b, msgpackNil)
+	}
+
+	b = append(b, msgpackExt8, 12, 0xff) // 0xff is the timestamp extension type -1.
+	b = binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+	return binary.BigEndian.AppendUint64(b, uint64(t.Unix()))
+}
+
+type msgpackReader struct {
+	data []byte
+}
+
+func (r *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.data) < n {
+		return nil, fmt.Errorf("%w: unexpected end of data", errInvalidMsgpack)
+	}
+
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v, nil
+}
+
+func (r *msgpackReader) readCode() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// readLength reads a big endian unsigned integer of the given size in bytes.
+func (r *msgpackReader) readLength(size int) (int, error) {
+	b, err := r.next(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return int(b[0]), nil
+	case 2:
+		return int(binary.BigEndian.Uint16(b)), nil
+	default:
+		return int(binary.BigEndian.Uint32(b)), nil
+	}
+}
+
+func (r *msgpackReader) readMapHeader() (int, error) {
+	code, err := r.readCode()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case code&0xf0 == 0x80:
+		return int(code & 0x0f), nil
+	case code == msgpackMap16:
+		return r.readLength(2)
+	case code == msgpackMap32:
+		return r.readLength(4)
+	case code == msgpackNil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("%w: expected map, got 0x%x", errInvalidMsgpack, code)
+	}
+}
+
+func (r *msgpackReader) readString() (string, error) {
+	b, err := r.readBytes()
+	return string(b), err
+}
+
+func (r *msgpackReader) readBinary() ([]byte, error) {
+	b, err := r.readBytes()
+	if b == nil {
+		return nil, err
+	}
+	return append([]byte{}, b...), err
+}
+
+// readBytes reads either a string or a binary value.
+func (r *msgpackReader) readBytes() ([]byte, error) {
+	code, err := r.readCode()
+	if err != nil {
+		return nil, err
+	}
+
+	var n int
+	switch {
+	case code&0xe0 == 0xa0:
+		n = int(code & 0x1f)
+	case code == msgpackStr8 || code == msgpackBin8:
+		n, err = r.readLength(1)
+	case code == msgpackStr16 || code == msgpackBin16:
+		n, err = r.readLength(2)
+	case code == msgpackStr32 || code == msgpackBin32:
+		n, err = r.readLength(4)
+	case code == msgpackNil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: expected string or binary, got 0x%x", errInvalidMsgpack, code)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.next(n)
+}
+
+func (r *msgpackReader) readInt() (int64, error) {
+	code, err := r.readCode()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code >= msgpackUint8 && code <= msgpackUint64:
+		b, err := r.next(1 << (code - msgpackUint8))
+		if err != nil {
+			return 0, err
+		}
+		return int64(bigEndianUint(b)), nil
+	case code >= msgpackInt8 && code <= msgpackInt64:
+		b, err := r.next(1 << (code - msgpackInt8))
+		if err != nil {
+			return 0, err
+		}
+		// Sign extend the value to 64 bits.
+		shift := 64 - 8*len(b)
+		return int64(bigEndianUint(b)<<shift) >> shift, nil
+	case code == msgpackNil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("%w: expected integer, got 0x%x", errInvalidMsgpack, code)
+	}
+}
+
+func (r *msgpackReader) readTimestamp() (time.Time, error) {
+	code, err := r.readCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if code == msgpackNil {
+		return time.Time{}, nil
+	}
+
+	var n int
+	switch code {
+	case 0xd6: // fixext 4
+		n = 4
+	case 0xd7: // fixext 8
+		n = 8
+	case msgpackExt8:
+		if n, err = r.readLength(1); err != nil {
+			return time.Time{}, err
+		}
+	default:
+		return time.Time{}, fmt.Errorf("%w: expected timestamp, got 0x%x", errInvalidMsgpack, code)
+	}
+
+	extType, err := r.readCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if int8(extType) != msgpackTimestampExt {
+		return time.Time{}, fmt.Errorf("%w: expected timestamp extension, got %d", errInvalidMsgpack, int8(extType))
+	}
+
+	b, err := r.next(n)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(b)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(b)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(b[4:])), int64(binary.BigEndian.Uint32(b[:4]))), nil
+	default:
+		return time.Time{}, fmt.Errorf("%w: invalid timestamp length %d", errInvalidMsgpack, n)
+	}
+}
+
+func (r *msgpackReader) readStringMap() (map[string]string, error) {
+	if len(r.data) > 0 && r.data[0] == msgpackNil {
+		r.data = r.data[1:]
+		return nil, nil
+	}
+
+	n, err := r.readMapHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every key and value takes at least a byte, which bounds the size
+	// of the map by the remaining data rather than its untrusted header.
+	if n > len(r.data)/2 {
+		return nil, fmt.Errorf("%w: map of %d entries exceeds the data", errInvalidMsgpack, n)
+	}
+
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		k, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		m[k] = v
+	}
+
+	return m, nil
+}
+
+// skip discards the next value of any type.
+// Depth is the nesting level of the value, which is limited to msgpackMaxDepth.
+func (r *msgpackReader) skip(depth int) error {
+	if depth > msgpackMaxDepth {
+		return fmt.Errorf("%w: values nested deeper than %d", errInvalidMsgpack, msgpackMaxDepth)
+	}
+
+	code, err := r.readCode()
+	if err != nil {
+		return err
+	}
+
+	var size, items int
+	switch {
+	case code <= 0x7f, code >= 0xe0, code == msgpackNil, code == msgpackFalse, code == msgpackTrue:
+		return nil
+	case code&0xf0 == 0x80:
+		items = 2 * int(code&0x0f)
+	case code&0xf0 == 0x90:
+		items = int(code & 0x0f)
+	case code&0xe0 == 0xa0:
+		size = int(code & 0x1f)
+	case code == msgpackBin8, code == msgpackStr8:
+		size, err = r.readLength(1)
+	case code == msgpackBin16, code == msgpackStr16:
+		size, err = r.readLength(2)
+	case code == msgpackBin32, code == msgpackStr32:
+		size, err = r.readLength(4)
+	case code == msgpackExt8:
+		size, err = r.readLength(1)
+		size++
+	case code == msgpackExt16:
+		size, err = r.readLength(2)
+		size++
+	case code == msgpackExt32:
+		size, err = r.readLength(4)
+		size++
+	case code == msgpackFloat32:
+		size = 4
+	case code == msgpackFloat64:
+		size = 8
+	case code >= msgpackUint8 && code <= msgpackUint64:
+		size = 1 << (code - msgpackUint8)
+	case code >= msgpackInt8 && code <= msgpackInt64:
+		size = 1 << (code - msgpackInt8)
+	case code >= msgpackFixExt1 && code <= msgpackFixExt16:
+		size = 1<<(code-msgpackFixExt1) + 1
+	case code == msgpackArray16:
+		items, err = r.readLength(2)
+	case code == msgpackArray32:
+		items, err = r.readLength(4)
+	case code == msgpackMap16:
+		items, err = r.readLength(2)
+		items *= 2
+	case code == msgpackMap32:
+		items, err = r.readLength(4)
+		items *= 2
+	default:
+		return fmt.Errorf("%w: unknown format 0x%x", errInvalidMsgpack, code)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.next(size); err != nil {
+		return err
+	}
+
+	// Every item takes at least a byte.
+	if items > len(r.data) {
+		return fmt.Errorf("%w: %d items exceed the data", errInvalidMsgpack, items)
+	}
+
+	for i := 0; i < items; i++ {
+		if err := r.skip(depth + 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func bigEndianUint(b []byte) uint64 {
+	var v uint64
+	for _, x := range b {
+		v = v<<8 | uint64(x)
+	}
+	return v
+}
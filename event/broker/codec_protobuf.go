@@ -0,0 +1,201 @@
+package broker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	rabbitmq "github.com/krixlion/dev_forum-lib/rabbitmq"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var errInvalidProtobuf = errors.New("invalid protobuf event")
+
+// ProtobufCodec serializes events into the protobuf wire format.
+// The event's body is wrapped as is, following the schema below.
+//
+//	message Event {
+//	  string aggregate_id = 1;
+//	  string type = 2;
+//	  bytes body = 3;
+//	  google.protobuf.Timestamp timestamp = 4;
+//	  map<string, string> metadata = 5;
+//	  int64 version = 6;
+//	  string id = 7;
+//	  string aggregate_instance_id = 8;
+//	  string correlation_id = 9;
+//	  string causation_id = 10;
+//	}
+type ProtobufCodec struct{}
+
+// Field numbers of the protobuf event schema.
+const (
+	protoAggregateId protowire.Number = iota + 1
+	protoType
+	protoBody
+	protoTimestamp
+	protoMetadata
+	protoVersion
+	protoId
+	protoAggregateInstanceId
+	protoCorrelationId
+	protoCausationId
+)
+
+// Field numbers of google.protobuf.Timestamp and map entries.
+const (
+	protoSeconds protowire.Number = 1
+	protoNanos   protowire.Number = 2
+	protoKey     protowire.Number = 1
+	protoValue   protowire.Number = 2
+)
+
+func (ProtobufCodec) ContentType() rabbitmq.ContentType {
+	return rabbitmq.ContentTypeProtobuf
+}
+
+func (ProtobufCodec) Marshal(e event.Event) ([]byte, error) {
+	b := []byte{}
+	b = appendProtoString(b, protoAggregateId, string(e.AggregateId))
+	b = appendProtoString(b, protoType, string(e.Type))
+
+	if len(e.Body) > 0 {
+		b = protowire.AppendTag(b, protoBody, protowire.BytesType)
+		b = protowire.AppendBytes(b, e.Body)
+	}
+
+	if !e.Timestamp.IsZero() {
+		ts := []byte{}
+		ts = protowire.AppendTag(ts, protoSeconds, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(e.Timestamp.Unix()))
+		ts = protowire.AppendTag(ts, protoNanos, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(e.Timestamp.Nanosecond()))
+
+		b = protowire.AppendTag(b, protoTimestamp, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts)
+	}
+
+	for k, v := range e.Metadata {
+		entry := []byte{}
+		entry = appendProtoString(entry, protoKey, k)
+		entry = appendProtoString(entry, protoValue, v)
+
+		b = protowire.AppendTag(b, protoMetadata, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+
+	if e.Version != 0 {
+		b = protowire.AppendTag(b, protoVersion, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(e.Version))
+	}
+
+	b = appendProtoString(b, protoId, e.Id)
+	b = appendProtoString(b, protoAggregateInstanceId, e.AggregateInstanceId)
+	b = appendProtoString(b, protoCorrelationId, e.CorrelationId)
+	b = appendProtoString(b, protoCausationId, e.CausationId)
+
+	return b, nil
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, e *event.Event) error {
+	*e = event.Event{}
+
+	return consumeProtoFields(data, func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error {
+		switch {
+		case num == protoAggregateId && typ == protowire.BytesType:
+			e.AggregateId = event.AggregateId(value)
+		case num == protoType && typ == protowire.BytesType:
+			e.Type = event.EventType(value)
+		case num == protoId && typ == protowire.BytesType:
+			e.Id = string(value)
+		case num == protoAggregateInstanceId && typ == protowire.BytesType:
+			e.AggregateInstanceId = string(value)
+		case num == protoCorrelationId && typ == protowire.BytesType:
+			e.CorrelationId = string(value)
+		case num == protoCausationId && typ == protowire.BytesType:
+			e.CausationId = string(value)
+		case num == protoBody && typ == protowire.BytesType:
+			e.Body = append([]byte{}, value...)
+		case num == protoVersion && typ == protowire.VarintType:
+			e.Version = int(int64(varint))
+		case num == protoTimestamp && typ == protowire.BytesType:
+			var seconds, nanos uint64
+			err := consumeProtoFields(value, func(num protowire.Number, typ protowire.Type, _ []byte, varint uint64) error {
+				switch {
+				case num == protoSeconds && typ == protowire.VarintType:
+					seconds = varint
+				case num == protoNanos && typ == protowire.VarintType:
+					nanos = varint
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			e.Timestamp = time.Unix(int64(seconds), int64(nanos))
+		case num == protoMetadata && typ == protowire.BytesType:
+			var key, val string
+			err := consumeProtoFields(value, func(num protowire.Number, typ protowire.Type, value []byte, _ uint64) error {
+				switch {
+				case num == protoKey && typ == protowire.BytesType:
+					key = string(value)
+				case num == protoValue && typ == protowire.BytesType:
+					val = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if e.Metadata == nil {
+				e.Metadata = make(map[string]string)
+			}
+			e.Metadata[key] = val
+		}
+		return nil
+	})
+}
+
+func appendProtoString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// consumeProtoFields calls fn for every field of the message. Length-delimited fields
+// are passed as value and varint fields as varint. Other fields are skipped.
+func consumeProtoFields(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, varint uint64) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return errors.Join(errInvalidProtobuf, protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		var value []byte
+		var varint uint64
+
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+
+		if n < 0 {
+			return errors.Join(errInvalidProtobuf, protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		if err := fn(num, typ, value, varint); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
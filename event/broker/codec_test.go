@@ -0,0 +1,156 @@
+package broker
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/internal/gentest"
+)
+
+func TestCodecs(t *testing.T) {
+	e := event.Event{
+		Id:                  gentest.RandomString(36),
+		AggregateId:         event.ArticleAggregate,
+		AggregateInstanceId: gentest.RandomString(36),
+		CorrelationId:       gentest.RandomString(36),
+		CausationId:         gentest.RandomString(36),
+		Type:                event.ArticleCreated,
+		Body:                gentest.RandomJSONArticle(2, 5),
+		Timestamp:           time.Now().Round(0),
+		Version:             2,
+		Metadata:            map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}
+
+	tests := []struct {
+		desc  string
+		codec Codec
+		arg   event.Event
+	}{
+		{
+			desc:  "Test if JSON codec preserves the event",
+			codec: JSONCodec{},
+			arg:   e,
+		},
+		{
+			desc:  "Test if protobuf codec preserves the event",
+			codec: ProtobufCodec{},
+			arg:   e,
+		},
+		{
+			desc:  "Test if msgpack codec preserves the event",
+			codec: MsgpackCodec{},
+			arg:   e,
+		},
+		{
+			desc:  "Test if protobuf codec preserves an empty event",
+			codec: ProtobufCodec{},
+			arg:   event.Event{},
+		},
+		{
+			desc:  "Test if msgpack codec preserves an event with a large body",
+			codec: MsgpackCodec{},
+			arg: event.Event{
+				Type:      event.ArticleCreated,
+				Body:      gentest.RandomJSONArticle(100, 70000),
+				Timestamp: time.Unix(-100, 5),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			data, err := tt.codec.Marshal(tt.arg)
+			if err != nil {
+				t.Errorf("Codec.Marshal():\n error = %v", err)
+				return
+			}
+
+			got := event.Event{}
+			if err := tt.codec.Unmarshal(data, &got); err != nil {
+				t.Errorf("Codec.Unmarshal():\n error = %v", err)
+				return
+			}
+
+			if !cmp.Equal(got, tt.arg) {
+				t.Errorf("Codecs are not symmetrical:\n %s", cmp.Diff(got, tt.arg))
+			}
+		})
+	}
+}
+
+func TestCodecs_Unmarshal(t *testing.T) {
+	tests := []struct {
+		desc    string
+		codec   Codec
+		data    []byte
+		want    event.Event
+		wantErr bool
+	}{
+		{
+			desc:    "Test if protobuf codec returns an error on truncated data",
+			codec:   ProtobufCodec{},
+			data:    []byte{0x0a, 0x05, 'a', 'b'},
+			wantErr: true,
+		},
+		{
+			desc:    "Test if msgpack codec returns an error on truncated data",
+			codec:   MsgpackCodec{},
+			data:    []byte{0x81, 0xa4, 't', 'y', 'p', 'e', 0xa5, 'a'},
+			wantErr: true,
+		},
+		{
+			desc:    "Test if msgpack codec returns an error when data is not a map",
+			codec:   MsgpackCodec{},
+			data:    []byte{0xa1, 'a'},
+			wantErr: true,
+		},
+		{
+			desc:    "Test if msgpack codec returns an error when metadata size exceeds the data",
+			codec:   MsgpackCodec{},
+			data:    []byte{0x81, 0xa8, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', 0xdf, 0xff, 0xff, 0xff, 0xff},
+			wantErr: true,
+		},
+		{
+			desc:    "Test if msgpack codec returns an error on deeply nested unknown values",
+			codec:   MsgpackCodec{},
+			data:    append([]byte{0x81, 0xa3, 'f', 'o', 'o'}, append(bytes.Repeat([]byte{0x91}, 1000), 0xc0)...),
+			wantErr: true,
+		},
+		{
+			desc:  "Test if msgpack codec skips unknown keys",
+			codec: MsgpackCodec{},
+			data: []byte{
+				0x82,
+				0xa3, 'f', 'o', 'o', 0x92, 0xcd, 0x01, 0x02, 0x81, 0xa1, 'a', 0xc3,
+				0xa7, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x03,
+			},
+			want: event.Event{Version: 3},
+		},
+		{
+			desc:  "Test if protobuf codec skips unknown fields",
+			codec: ProtobufCodec{},
+			data:  []byte{0x7d, 0x01, 0x02, 0x03, 0x04, 0x30, 0x03},
+			want:  event.Event{Version: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := event.Event{}
+			err := tt.codec.Unmarshal(tt.data, &got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Codec.Unmarshal():\n error = %v\n wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("Codec.Unmarshal():\n got = %+v\n want = %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	rabbitmq "github.com/krixlion/dev_forum-lib/rabbitmq"
+)
+
+var (
+	_ event.Broker           = (*MemoryBroker)(nil)
+	_ event.DeliveryConsumer = (*MemoryBroker)(nil)
+	_ event.PatternConsumer  = (*MemoryBroker)(nil)
+)
+
+// MemoryBroker is an in-process implementation of event.Broker meant for tests and local development.
+// It routes events the same way Broker does on a RabbitMQ topic exchange, so every queue
+// bound to an event type receives its own copy of each published event and
+// consumers of the same queue compete for the events stored in it.
+// Events published to an exchange without any bound queues are discarded.
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	bindings map[string]map[binding]struct{} // Bindings keyed by exchange name.
+	queues   map[string]*memoryQueue
+}
+
+type binding struct {
+	queue      string
+	routingKey string
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		bindings: make(map[string]map[binding]struct{}),
+		queues:   make(map[string]*memoryQueue),
+	}
+}
+
+// ResilientPublish returns an error only if the event type does not follow the {noun}-{action} format
+// or if the event is invalid.
+// Since queues are unbounded it never has to retry and publishes the event immediately.
+func (b *MemoryBroker) ResilientPublish(e event.Event) error {
+	return b.Publish(context.Background(), e)
+}
+
+// Publish routes the event to every queue bound to the event's exchange with a matching routing key.
+// Returns an error if the event's body does not match its registered payload type.
+func (b *MemoryBroker) Publish(ctx context.Context, e event.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := event.ValidateStrict(e); err != nil {
+		return err
+	}
+
+	r, err := routeFromEvent(e.Type)
+	if err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	routed := make(map[string]struct{})
+	for _, exchange := range []string{r.ExchangeName, event.Wildcard} {
+		for bind := range b.bindings[exchange] {
+			if _, ok := routed[bind.queue]; ok || !matchTopic(bind.routingKey, r.RoutingKey) {
+				continue
+			}
+			routed[bind.queue] = struct{}{}
+			b.queues[bind.queue].push(cloneEvent(e))
+		}
+	}
+
+	return nil
+}
+
+// Consume declares the queue if it does not exist yet, binds it to the event type
+// and returns a channel receiving events from that queue.
+// Multiple calls with the same queue name register competing consumers.
+// The returned channel is closed when the context is cancelled.
+func (b *MemoryBroker) Consume(ctx context.Context, queue string, eventType event.EventType) (<-chan event.Event, error) {
+	deliveries, err := b.ConsumeDeliveries(ctx, queue, eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	return eventsFromDeliveries(ctx, nulls.NullLogger{}, deliveries), nil
+}
+
+// ConsumeDeliveries works like Consume except that every received delivery has to be settled.
+// Like with Broker, events are upcasted to the latest version of their type when they are delivered
+// and events which fail to upcast or validate afterwards are discarded.
+// Deliveries which are left unsettled when the context is cancelled are requeued.
+func (b *MemoryBroker) ConsumeDeliveries(ctx context.Context, queue string, eventType event.EventType) (<-chan event.Delivery, error) {
+	r, err := routeFromEvent(eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.consumeDeliveries(ctx, b.bind(queue, r)), nil
+}
+
+// ConsumePattern works like Consume except that the queue is bound to all event types matching the pattern.
+// Unlike Broker, patterns with a wildcard noun match events of every noun.
+func (b *MemoryBroker) ConsumePattern(ctx context.Context, queue string, pattern event.Pattern) (<-chan event.Event, error) {
+	deliveries, err := b.ConsumePatternDeliveries(ctx, queue, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return eventsFromDeliveries(ctx, nulls.NullLogger{}, deliveries), nil
+}
+
+// ConsumePatternDeliveries works like ConsumePattern except that every received delivery has to be settled.
+func (b *MemoryBroker) ConsumePatternDeliveries(ctx context.Context, queue string, pattern event.Pattern) (<-chan event.Delivery, error) {
+	// Bindings of a wildcard noun are stored under the wildcard exchange, see Publish.
+	routes, err := routesFromPattern(pattern, []string{event.Wildcard})
+	if err != nil {
+		return nil, err
+	}
+
+	var q *memoryQueue
+	for _, r := range routes {
+		q = b.bind(queue, r)
+	}
+
+	return b.consumeDeliveries(ctx, q), nil
+}
+
+func (b *MemoryBroker) consumeDeliveries(ctx context.Context, q *memoryQueue) <-chan event.Delivery {
+	c := &memoryConsumer{
+		queue:     q,
+		unsettled: make(map[*memoryDelivery]struct{}),
+	}
+
+	deliveries := make(chan event.Delivery)
+	go func() {
+		defer close(deliveries)
+		defer c.requeueUnsettled()
+
+		for {
+			e, ok := q.pop(ctx)
+			if !ok {
+				return
+			}
+
+			// Invalid events are discarded, the same way Broker dead-letters them.
+			e, err := event.Upcast(e)
+			if err == nil {
+				err = event.Validate(e)
+			}
+			if err != nil {
+				continue
+			}
+
+			select {
+			case deliveries <- event.Delivery{Event: e, Acknowledger: c.track(e)}:
+			case <-ctx.Done():
+				// Return the event to the queue so that other consumers can receive it.
+				q.requeue(e)
+				return
+			}
+		}
+	}()
+
+	return deliveries
+}
+
+// bind declares a queue and binds it to the route's exchange if it was not bound before.
+func (b *MemoryBroker) bind(queue string, r rabbitmq.Route) *memoryQueue {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queue]
+	if !ok {
+		q = newMemoryQueue()
+		b.queues[queue] = q
+	}
+
+	if _, ok := b.bindings[r.ExchangeName]; !ok {
+		b.bindings[r.ExchangeName] = make(map[binding]struct{})
+	}
+	b.bindings[r.ExchangeName][binding{queue: queue, routingKey: r.RoutingKey}] = struct{}{}
+
+	return q
+}
+
+// memoryQueue is an unbounded FIFO queue safe for concurrent use.
+type memoryQueue struct {
+	mu     sync.Mutex
+	events []event.Event
+	notify chan struct{} // Signals waiting consumers that the queue is not empty.
+}
+
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (q *memoryQueue) push(e event.Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.events = append(q.events, e)
+	q.signal()
+}
+
+// requeue puts the event back at the front of the queue.
+func (q *memoryQueue) requeue(e event.Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.events = slices.Insert(q.events, 0, e)
+	q.signal()
+}
+
+// pop blocks until an event is available or the context is cancelled.
+// It returns false if the context was cancelled.
+func (q *memoryQueue) pop(ctx context.Context) (event.Event, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.events) > 0 {
+			e := q.events[0]
+			q.events = q.events[1:]
+
+			// Wake up the next waiting consumer if there are events left.
+			if len(q.events) > 0 {
+				q.signal()
+			}
+			q.mu.Unlock()
+			return e, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return event.Event{}, false
+		}
+	}
+}
+
+// signal must be called with the mutex held.
+func (q *memoryQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+var errAlreadySettled = errors.New("delivery already settled")
+
+// memoryConsumer keeps track of deliveries which were not settled yet
+// in order to requeue them when the consumer is cancelled.
+type memoryConsumer struct {
+	queue     *memoryQueue
+	mu        sync.Mutex
+	unsettled map[*memoryDelivery]struct{}
+}
+
+func (c *memoryConsumer) track(e event.Event) *memoryDelivery {
+	d := &memoryDelivery{consumer: c, event: e}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unsettled[d] = struct{}{}
+
+	return d
+}
+
+func (c *memoryConsumer) settle(d *memoryDelivery, requeue bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.unsettled[d]; !ok {
+		return errAlreadySettled
+	}
+	delete(c.unsettled, d)
+
+	if requeue {
+		c.queue.requeue(d.event)
+	}
+
+	return nil
+}
+
+func (c *memoryConsumer) requeueUnsettled() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for d := range c.unsettled {
+		c.queue.requeue(d.event)
+	}
+	clear(c.unsettled)
+}
+
+var _ event.Acknowledger = (*memoryDelivery)(nil)
+
+type memoryDelivery struct {
+	consumer *memoryConsumer
+	event    event.Event
+}
+
+func (d *memoryDelivery) Ack() error {
+	return d.consumer.settle(d, false)
+}
+
+func (d *memoryDelivery) Nack(requeue bool) error {
+	return d.consumer.settle(d, requeue)
+}
+
+func (d *memoryDelivery) Reject(requeue bool) error {
+	return d.consumer.settle(d, requeue)
+}
+
+// cloneEvent returns a deep copy of the event so that consumers do not share its body and metadata.
+func cloneEvent(e event.Event) event.Event {
+	e.Body = slices.Clone(e.Body)
+	e.Metadata = maps.Clone(e.Metadata)
+	return e
+}
@@ -0,0 +1,324 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/internal/gentest"
+)
+
+func TestMemoryBroker_Publish(t *testing.T) {
+	e := event.Event{
+		AggregateId: event.ArticleAggregate,
+		Type:        event.ArticleCreated,
+		Body:        gentest.RandomJSONArticle(2, 5),
+		Timestamp:   time.Now(),
+		Version:     1,
+		Metadata:    map[string]string{},
+	}
+
+	tests := []struct {
+		desc   string
+		queues []string
+	}{
+		{
+			desc:   "Test if event is delivered to a single queue",
+			queues: []string{"test"},
+		},
+		{
+			desc:   "Test if event is delivered to every bound queue",
+			queues: []string{"test-1", "test-2", "test-3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			b := NewMemoryBroker()
+
+			consumers := make([]<-chan event.Event, 0, len(tt.queues))
+			for _, queue := range tt.queues {
+				events, err := b.Consume(ctx, queue, e.Type)
+				if err != nil {
+					t.Errorf("MemoryBroker.Consume() error = %v", err)
+					return
+				}
+				consumers = append(consumers, events)
+			}
+
+			if err := b.Publish(ctx, e); err != nil {
+				t.Errorf("MemoryBroker.Publish() error = %v", err)
+				return
+			}
+
+			for _, events := range consumers {
+				select {
+				case got := <-events:
+					if !cmp.Equal(got, e) {
+						t.Errorf("MemoryBroker.Publish():\n got = %+v\n want = %+v\n diff = %+v\n", got, e, cmp.Diff(got, e))
+					}
+				case <-ctx.Done():
+					t.Errorf("MemoryBroker.Publish(): timed out waiting for the event")
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryBroker_CompetingConsumers(t *testing.T) {
+	t.Run("Test if consumers of the same queue receive every event exactly once", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		const numEvents = 20
+		b := NewMemoryBroker()
+
+		first, err := b.Consume(ctx, "test", event.UserCreated)
+		if err != nil {
+			t.Errorf("MemoryBroker.Consume() error = %v", err)
+			return
+		}
+
+		second, err := b.Consume(ctx, "test", event.UserCreated)
+		if err != nil {
+			t.Errorf("MemoryBroker.Consume() error = %v", err)
+			return
+		}
+
+		for i := 0; i < numEvents; i++ {
+			if err := b.ResilientPublish(event.Event{Type: event.UserCreated, Body: []byte{byte(i)}}); err != nil {
+				t.Errorf("MemoryBroker.ResilientPublish() error = %v", err)
+				return
+			}
+		}
+
+		received := make(map[byte]int, numEvents)
+		for i := 0; i < numEvents; i++ {
+			select {
+			case e := <-first:
+				received[e.Body[0]]++
+			case e := <-second:
+				received[e.Body[0]]++
+			case <-ctx.Done():
+				t.Errorf("MemoryBroker.Consume(): timed out, received %d out of %d events", i, numEvents)
+				return
+			}
+		}
+
+		for i := 0; i < numEvents; i++ {
+			if received[byte(i)] != 1 {
+				t.Errorf("MemoryBroker.Consume(): event %d received %d times", i, received[byte(i)])
+			}
+		}
+	})
+}
+
+func TestMemoryBroker_Consume(t *testing.T) {
+	t.Run("Test if returned channel is closed on context cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events, err := NewMemoryBroker().Consume(ctx, "test", event.ArticleDeleted)
+		if err != nil {
+			t.Errorf("MemoryBroker.Consume() error = %v", err)
+			return
+		}
+
+		cancel()
+
+		select {
+		case _, ok := <-events:
+			if ok {
+				t.Errorf("MemoryBroker.Consume(): received an unexpected event")
+			}
+		case <-time.After(time.Second):
+			t.Errorf("MemoryBroker.Consume(): channel was not closed")
+		}
+	})
+
+	t.Run("Test if returns an error on invalid event type", func(t *testing.T) {
+		if _, err := NewMemoryBroker().Consume(context.Background(), "test", "invalid"); err == nil {
+			t.Errorf("MemoryBroker.Consume(): expected an error on invalid event type")
+		}
+	})
+}
+
+func TestMemoryBroker_ConsumePattern(t *testing.T) {
+	tests := []struct {
+		desc    string
+		pattern event.Pattern
+		want    []event.EventType
+	}{
+		{
+			desc:    "Test if wildcard action receives all events of the noun",
+			pattern: "article-*",
+			want:    []event.EventType{event.ArticleCreated, event.ArticleDeleted},
+		},
+		{
+			desc:    "Test if wildcard noun receives the action of all nouns",
+			pattern: "*-deleted",
+			want:    []event.EventType{event.ArticleDeleted, event.UserDeleted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			b := NewMemoryBroker()
+
+			events, err := b.ConsumePattern(ctx, "test", tt.pattern)
+			if err != nil {
+				t.Errorf("MemoryBroker.ConsumePattern() error = %v", err)
+				return
+			}
+
+			for _, eType := range []event.EventType{event.ArticleCreated, event.ArticleDeleted, event.UserCreated, event.UserDeleted} {
+				if err := b.Publish(ctx, event.Event{Type: eType, Body: []byte(`"test"`)}); err != nil {
+					t.Errorf("MemoryBroker.Publish() error = %v", err)
+					return
+				}
+			}
+
+			got := []event.EventType{}
+			for range tt.want {
+				select {
+				case e := <-events:
+					got = append(got, e.Type)
+				case <-ctx.Done():
+					t.Errorf("MemoryBroker.ConsumePattern(): timed out waiting for events")
+					return
+				}
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("MemoryBroker.ConsumePattern():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryBroker_ConsumeDeliveries(t *testing.T) {
+	t.Run("Test if negatively acknowledged event is redelivered", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		b := NewMemoryBroker()
+
+		deliveries, err := b.ConsumeDeliveries(ctx, "test", event.ArticleCreated)
+		if err != nil {
+			t.Errorf("MemoryBroker.ConsumeDeliveries() error = %v", err)
+			return
+		}
+
+		want := event.Event{Type: event.ArticleCreated, Body: gentest.RandomJSONArticle(2, 5), Version: 1}
+		if err := b.Publish(ctx, want); err != nil {
+			t.Errorf("MemoryBroker.Publish() error = %v", err)
+			return
+		}
+
+		first := <-deliveries
+		if err := first.Nack(true); err != nil {
+			t.Errorf("Delivery.Nack() error = %v", err)
+			return
+		}
+
+		second := <-deliveries
+		if !cmp.Equal(second.Event, want) {
+			t.Errorf("MemoryBroker.ConsumeDeliveries():\n got = %+v\n want = %+v\n", second.Event, want)
+		}
+
+		if err := second.Ack(); err != nil {
+			t.Errorf("Delivery.Ack() error = %v", err)
+		}
+
+		if err := second.Ack(); err == nil {
+			t.Errorf("Delivery.Ack(): expected an error on already settled delivery")
+		}
+	})
+
+	t.Run("Test if unsettled event is requeued when the consumer is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		b := NewMemoryBroker()
+
+		consumerCtx, cancelConsumer := context.WithCancel(ctx)
+		defer cancelConsumer()
+
+		deliveries, err := b.ConsumeDeliveries(consumerCtx, "test", event.ArticleCreated)
+		if err != nil {
+			t.Errorf("MemoryBroker.ConsumeDeliveries() error = %v", err)
+			return
+		}
+
+		want := event.Event{Type: event.ArticleCreated, Body: gentest.RandomJSONArticle(2, 5), Version: 1}
+		if err := b.Publish(ctx, want); err != nil {
+			t.Errorf("MemoryBroker.Publish() error = %v", err)
+			return
+		}
+
+		<-deliveries
+		cancelConsumer()
+
+		// Wait for the channel to close, after which unsettled deliveries are requeued.
+		for range deliveries {
+		}
+
+		events, err := b.Consume(ctx, "test", event.ArticleCreated)
+		if err != nil {
+			t.Errorf("MemoryBroker.Consume() error = %v", err)
+			return
+		}
+
+		select {
+		case got := <-events:
+			if !cmp.Equal(got, want) {
+				t.Errorf("MemoryBroker.ConsumeDeliveries():\n got = %+v\n want = %+v\n", got, want)
+			}
+		case <-ctx.Done():
+			t.Errorf("MemoryBroker.ConsumeDeliveries(): unsettled event was not requeued")
+		}
+	})
+}
+
+func TestMemoryBroker_upcast(t *testing.T) {
+	t.Run("Test if events are upcasted when delivered rather than when published", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		eType := event.EventType("memory_upcast-created")
+		b := NewMemoryBroker()
+
+		events, err := b.Consume(ctx, "test", eType)
+		if err != nil {
+			t.Errorf("MemoryBroker.Consume() error = %v", err)
+			return
+		}
+
+		if err := b.Publish(ctx, event.Event{Type: eType, Body: []byte(`"v1"`), Version: 1}); err != nil {
+			t.Errorf("MemoryBroker.Publish() error = %v", err)
+			return
+		}
+
+		// Upcasters deployed with the consumer apply to events published before.
+		event.RegisterUpcaster(eType, 1, func(e event.Event) (event.Event, error) {
+			e.Body = []byte(`"v2"`)
+			return e, nil
+		})
+
+		want := event.Event{Type: eType, Body: []byte(`"v2"`), Version: 2}
+		select {
+		case got := <-events:
+			if !cmp.Equal(got, want) {
+				t.Errorf("MemoryBroker.Consume():\n got = %+v\n want = %+v", got, want)
+			}
+		case <-ctx.Done():
+			t.Errorf("MemoryBroker.Consume(): event was not delivered")
+		}
+	})
+}
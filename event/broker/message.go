@@ -1,7 +1,6 @@
 package broker
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -11,12 +10,18 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-// messageFromEvent returns a message suitable for pub/sub methods and
-// a non-nil error if the event could not be marshaled into JSON.
-func messageFromEvent(e event.Event) (rabbitmq.Message, error) {
-	body, err := json.Marshal(e)
+var ErrNoExchanges = errors.New("no exchanges to bind a pattern with a wildcard noun to, see WithExchanges")
+
+// messageFromEvent returns a message suitable for pub/sub methods and a non-nil error
+// if the event's body is invalid or the event could not be marshaled using the codec.
+func messageFromEvent(e event.Event, codec Codec) (rabbitmq.Message, error) {
+	if err := event.ValidateStrict(e); err != nil {
+		return rabbitmq.Message{}, err
+	}
+
+	body, err := codec.Marshal(e)
 	if err != nil {
-		return rabbitmq.Message{}, fmt.Errorf("invalid JSON tags on event.Event, err: %v", err)
+		return rabbitmq.Message{}, err
 	}
 
 	r, err := routeFromEvent(e.Type)
@@ -25,18 +30,20 @@ func messageFromEvent(e event.Event) (rabbitmq.Message, error) {
 	}
 
 	return rabbitmq.Message{
-		Body:        body,
-		ContentType: rabbitmq.ContentTypeJson,
-		Route:       r,
-		Timestamp:   e.Timestamp,
-		Headers:     e.Metadata,
+		Body:          body,
+		ContentType:   codec.ContentType(),
+		MessageId:     e.Id,
+		CorrelationId: e.CorrelationId,
+		Route:         r,
+		Timestamp:     e.Timestamp,
+		Headers:       e.Metadata,
 	}, nil
 }
 
 func routeFromEvent(eType event.EventType) (rabbitmq.Route, error) {
 	noun, action, found := strings.Cut(string(eType), "-")
 	if !found {
-		return rabbitmq.Route{}, errors.New("event type does not follow {noun}-{action} format")
+		return rabbitmq.Route{}, fmt.Errorf("%w: %q", event.ErrInvalidType, eType)
 	}
 
 	return rabbitmq.Route{
@@ -45,3 +52,58 @@ func routeFromEvent(eType event.EventType) (rabbitmq.Route, error) {
 		RoutingKey:   noun + ".event." + action,
 	}, nil
 }
+
+// routesFromPattern returns topic routes of all event types matching the pattern.
+// Patterns with a wildcard noun are routed through every given exchange.
+func routesFromPattern(p event.Pattern, exchanges []string) ([]rabbitmq.Route, error) {
+	noun, action, err := p.Split()
+	if err != nil {
+		return nil, err
+	}
+
+	if noun != event.Wildcard {
+		exchanges = []string{noun}
+	}
+
+	if len(exchanges) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNoExchanges, p)
+	}
+
+	routes := make([]rabbitmq.Route, 0, len(exchanges))
+	for _, exchange := range exchanges {
+		routes = append(routes, rabbitmq.Route{
+			ExchangeName: exchange,
+			ExchangeType: amqp.ExchangeTopic,
+			RoutingKey:   exchange + ".event." + action,
+		})
+	}
+
+	return routes, nil
+}
+
+// matchTopic reports whether the routing key matches the binding key
+// according to the AMQP topic exchange rules. Words are delimited by dots,
+// "*" substitutes exactly one word and "#" substitutes zero or more words.
+func matchTopic(bindingKey, routingKey string) bool {
+	return matchWords(strings.Split(bindingKey, "."), strings.Split(routingKey, "."))
+}
+
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchWords(pattern[1:], words[1:])
+	}
+}
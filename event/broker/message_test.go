@@ -12,34 +12,50 @@ import (
 	rabbitmq "github.com/krixlion/dev_forum-lib/rabbitmq"
 )
 
+const testStringPublished event.EventType = "test_string-published"
+
+func init() {
+	event.Register[string](testStringPublished)
+}
+
 func Test_messageFromEvent(t *testing.T) {
 	jsonArticle := gentest.RandomJSONArticle(3, 5)
 	e := event.Event{
-		AggregateId: "article",
-		Type:        event.ArticleCreated,
-		Body:        jsonArticle,
-		Timestamp:   time.Now(),
-		Metadata:    map[string]string{},
+		Id:            gentest.RandomString(36),
+		AggregateId:   "article",
+		CorrelationId: gentest.RandomString(36),
+		Type:          event.ArticleCreated,
+		Body:          jsonArticle,
+		Timestamp:     time.Now(),
+		Metadata:      map[string]string{},
 	}
 	jsonEvent, err := json.Marshal(e)
 	if err != nil {
 		panic(err)
 	}
+	protoEvent, err := ProtobufCodec{}.Marshal(e)
+	if err != nil {
+		panic(err)
+	}
 
 	tests := []struct {
 		desc    string
 		arg     event.Event
+		codec   Codec
 		want    rabbitmq.Message
 		wantErr bool
 	}{
 		{
-			desc: "Test if message is correctly processed from simple event",
-			arg:  e,
+			desc:  "Test if message is correctly processed from simple event",
+			arg:   e,
+			codec: JSONCodec{},
 			want: rabbitmq.Message{
-				Body:        jsonEvent,
-				ContentType: "application/json",
-				Timestamp:   e.Timestamp,
-				Headers:     map[string]string{},
+				Body:          jsonEvent,
+				ContentType:   "application/json",
+				MessageId:     e.Id,
+				CorrelationId: e.CorrelationId,
+				Timestamp:     e.Timestamp,
+				Headers:       map[string]string{},
 				Route: rabbitmq.Route{
 					ExchangeName: "article",
 					ExchangeType: "topic",
@@ -48,10 +64,38 @@ func Test_messageFromEvent(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			desc:  "Test if message carries the content type of the codec",
+			arg:   e,
+			codec: ProtobufCodec{},
+			want: rabbitmq.Message{
+				Body:          protoEvent,
+				ContentType:   "application/x-protobuf",
+				MessageId:     e.Id,
+				CorrelationId: e.CorrelationId,
+				Timestamp:     e.Timestamp,
+				Headers:       map[string]string{},
+				Route: rabbitmq.Route{
+					ExchangeName: "article",
+					ExchangeType: "topic",
+					RoutingKey:   "article.event.created",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			desc: "Test if returns an error when body does not match the registered payload type",
+			arg: event.Event{
+				Type: testStringPublished,
+				Body: jsonArticle,
+			},
+			codec:   JSONCodec{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.desc, func(t *testing.T) {
-			got, err := messageFromEvent(tt.arg)
+			got, err := messageFromEvent(tt.arg, tt.codec)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("messageFromEvent():\n error = %v\n wantErr = %v", err, tt.wantErr)
 				return
@@ -109,3 +153,106 @@ func Test_routeFromEvent(t *testing.T) {
 		})
 	}
 }
+
+func Test_routesFromPattern(t *testing.T) {
+	tests := []struct {
+		desc      string
+		pattern   event.Pattern
+		exchanges []string
+		want      []rabbitmq.Route
+		wantErr   bool
+	}{
+		{
+			desc:    "Test if wildcard action is bound to the noun's exchange",
+			pattern: "article-*",
+			want: []rabbitmq.Route{
+				{ExchangeName: "article", ExchangeType: "topic", RoutingKey: "article.event.*"},
+			},
+		},
+		{
+			desc:      "Test if wildcard noun is bound to every exchange",
+			pattern:   "*-deleted",
+			exchanges: []string{"article", "user"},
+			want: []rabbitmq.Route{
+				{ExchangeName: "article", ExchangeType: "topic", RoutingKey: "article.event.deleted"},
+				{ExchangeName: "user", ExchangeType: "topic", RoutingKey: "user.event.deleted"},
+			},
+		},
+		{
+			desc:    "Test if returns an error when there are no exchanges for wildcard noun",
+			pattern: "*-deleted",
+			wantErr: true,
+		},
+		{
+			desc:    "Test if returns an error on invalid pattern",
+			pattern: "article",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := routesFromPattern(tt.pattern, tt.exchanges)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("routesFromPattern():\n error = %v\n wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("routesFromPattern():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchTopic(t *testing.T) {
+	tests := []struct {
+		desc       string
+		bindingKey string
+		routingKey string
+		want       bool
+	}{
+		{
+			desc:       "Test if identical keys match",
+			bindingKey: "article.event.created",
+			routingKey: "article.event.created",
+			want:       true,
+		},
+		{
+			desc:       "Test if different keys do not match",
+			bindingKey: "article.event.created",
+			routingKey: "article.event.deleted",
+			want:       false,
+		},
+		{
+			desc:       "Test if star substitutes exactly one word",
+			bindingKey: "article.event.*",
+			routingKey: "article.event.created",
+			want:       true,
+		},
+		{
+			desc:       "Test if star does not substitute multiple words",
+			bindingKey: "article.*",
+			routingKey: "article.event.created",
+			want:       false,
+		},
+		{
+			desc:       "Test if hash substitutes multiple words",
+			bindingKey: "article.#",
+			routingKey: "article.event.created",
+			want:       true,
+		},
+		{
+			desc:       "Test if hash substitutes zero words",
+			bindingKey: "article.event.created.#",
+			routingKey: "article.event.created",
+			want:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := matchTopic(tt.bindingKey, tt.routingKey); got != tt.want {
+				t.Errorf("matchTopic():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package broker
+
+import rabbitmq "github.com/krixlion/dev_forum-lib/rabbitmq"
+
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(opts *options)
+
+func (fn optionFunc) apply(opts *options) {
+	fn(opts)
+}
+
+type options struct {
+	codec  Codec
+	codecs map[rabbitmq.ContentType]Codec // Codecs used for consuming keyed by their content type.
+	// Exchanges bound to by patterns with a wildcard noun.
+	exchanges []string
+}
+
+func defaultOptions() options {
+	return options{
+		codec:  JSONCodec{},
+		codecs: defaultCodecs(),
+	}
+}
+
+// WithCodec sets the codec used to serialize published events. Defaults to JSONCodec.
+// The codec is also used to deserialize consumed messages of its content type,
+// which allows to override the built-in codecs or to add new ones.
+// Consumed messages are always decoded based on their content type,
+// regardless of the codec used for publishing.
+func WithCodec(codec Codec) Option {
+	return optionFunc(func(opts *options) {
+		opts.codec = codec
+		opts.codecs[codec.ContentType()] = codec
+	})
+}
+
+// WithExchanges sets the exchanges, ie. the nouns of event types, to which queues
+// consuming patterns with a wildcard noun, eg. "*-deleted", are bound.
+// Since every noun has its own exchange, events of nouns which are not listed are not consumed.
+// Consuming such patterns returns ErrNoExchanges unless the exchanges are set.
+func WithExchanges(exchanges ...string) Option {
+	return optionFunc(func(opts *options) {
+		opts.exchanges = exchanges
+	})
+}
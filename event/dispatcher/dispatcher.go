@@ -2,10 +2,12 @@ package dispatcher
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"sync"
 
-	"github.com/krixlion/dev_forum-lib/chans"
 	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/event/middleware"
 )
 
 type Listener interface {
@@ -16,66 +18,362 @@ type Listener interface {
 	EventHandlers() map[event.EventType][]event.Handler
 }
 
+var ErrDispatcherClosed = errors.New("dispatcher is shut down")
+
+// workerKey marks contexts passed to handlers with the dispatcher running them.
+type workerKey struct{}
+
 type Dispatcher struct {
-	maxWorkers int
-	events     <-chan event.Event
-	mu         sync.Mutex
-	handlers   map[event.EventType][]event.Handler
+	mu            sync.Mutex
+	subscriptions map[event.EventType][]*subscription
+	matchers      []*subscription // Subscriptions made using SubscribeMatching.
+	providers     map[*Provider]struct{}
+	pool          *pool
+	opts          options
+
+	runCtx     context.Context // Non-nil while Run is running. Guarded by mu.
+	forwarders sync.WaitGroup  // Goroutines receiving from providers.
+
+	closed   bool          // Set once Shutdown is called. Guarded by mu.
+	stopping chan struct{} // Closed once Shutdown is called.
+
+	// ctx is passed to handlers. It is cancelled when Shutdown gives up on waiting for them.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func NewDispatcher(maxWorkers int) *Dispatcher {
-	return &Dispatcher{
-		maxWorkers: maxWorkers,
-		handlers:   make(map[event.EventType][]event.Handler),
+// NewDispatcher returns a dispatcher invoking handlers using up to maxWorkers
+// goroutines shared by all dispatched events.
+func NewDispatcher(maxWorkers int, opts ...Option) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		subscriptions: make(map[event.EventType][]*subscription),
+		providers:     make(map[*Provider]struct{}),
+		opts:          defaultOptions(),
+		stopping:      make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
-}
 
-// AddEventProviders registers provided channels as an event source.
-// Events from these providers will be parsed by the subscribed handlers
-func (d *Dispatcher) AddEventProviders(providers ...<-chan event.Event) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	d.events = chans.FanIn(providers...)
+	for _, opt := range opts {
+		opt.apply(&d.opts)
+	}
+
+	d.pool = newPool(maxWorkers, d.opts.queueDepth, d.opts.backpressure, d.opts.meter, d.opts.logger, d.run)
+
+	return d
 }
 
-// Run blocks until the context is cancelled.
+// Run blocks until the context is cancelled or the dispatcher is shut down.
 // Run starts the dispatcher to listen for events from its event providers and dispatch those events.
+// Events which were not received from the providers when Run returns are left in their channels.
+// Handlers which are in flight when Run returns are not interrupted, use Shutdown to wait for them.
 func (d *Dispatcher) Run(ctx context.Context) {
-	for {
-		select {
-		case event := <-d.events:
-			d.Dispatch(event)
-		case <-ctx.Done():
-			return
-		}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	d.mu.Lock()
+	d.runCtx = ctx
+	for p := range d.providers {
+		d.forward(ctx, p)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.stopping:
+	case <-ctx.Done():
+	}
+	cancel()
+
+	d.mu.Lock()
+	d.runCtx = nil
+	d.mu.Unlock()
+
+	d.forwarders.Wait()
+}
+
+// Shutdown stops the dispatcher from taking new events and waits for in-flight handlers
+// to finish. If the context expires first, the context passed to the handlers is cancelled
+// and the number of abandoned handlers is returned together with the context's error.
+// Handlers which were dispatched and did not start yet are abandoned as well.
+// Calling Shutdown more than once is safe.
+//
+// Events left in the providers' channels are not dispatched. Providers should be closed,
+// eg. by cancelling the context of their consumers, so that they stop consuming.
+func (d *Dispatcher) Shutdown(ctx context.Context) (abandoned int, err error) {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.stopping)
+	}
+	d.mu.Unlock()
+
+	d.pool.close()
+
+	select {
+	case <-d.idle():
+		d.cancel()
+		return 0, nil
+	case <-ctx.Done():
+		abandoned = d.pool.inFlight()
+		d.cancel()
+		return abandoned, ctx.Err()
 	}
 }
 
+// Wait blocks until all in-flight handlers finish.
+// Unlike Shutdown, it does not stop the dispatcher from taking new events.
+func (d *Dispatcher) Wait() {
+	d.pool.wait()
+}
+
+// Backpressure returns the policy applied to dispatched events when the queue is full.
+func (d *Dispatcher) Backpressure() BackpressurePolicy {
+	return d.opts.backpressure
+}
+
+// idle returns a channel which is closed once there are no handlers in flight.
+func (d *Dispatcher) idle() <-chan struct{} {
+	idle := make(chan struct{})
+	go func() {
+		d.pool.wait()
+		close(idle)
+	}()
+	return idle
+}
+
 // Subscribe registers handlers for specified event type.
 // They will be invoked when an according event is dispatched.
-func (d *Dispatcher) Subscribe(eType event.EventType, handlers ...event.Handler) {
+// In order to configure the subscription, eg. to add middlewares only to the subscribed handler,
+// use SubscribeContext together with event.AsContextHandler.
+// The returned Subscription allows to unsubscribe all given handlers at once.
+func (d *Dispatcher) Subscribe(eType event.EventType, handlers ...event.Handler) *Subscription {
+	sub := &Subscription{d: d, eType: eType}
+	for _, handler := range handlers {
+		s := d.SubscribeContext(eType, event.AsContextHandler(handler))
+		sub.subscriptions = append(sub.subscriptions, s.subscriptions...)
+	}
+	return sub
+}
+
+// SubscribeContext registers a handler for specified event type.
+// It will be invoked when an according event is dispatched and retried
+// according to the subscription's retry policy when it fails.
+// Panics in handlers are recovered and treated as failures.
+// Events which the handler failed to handle are passed to the subscription's failure sink.
+//
+//	d.SubscribeContext(event.ArticleCreated, handler,
+//		dispatcher.WithRetryPolicy(dispatcher.ExponentialRetry(5)),
+//		dispatcher.WithFailureSink(sink),
+//	)
+func (d *Dispatcher) SubscribeContext(eType event.EventType, handler event.ContextHandler, opts ...SubscribeOption) *Subscription {
+	s := d.newSubscription(handler, opts)
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.handlers[eType] = append(d.handlers[eType], handlers...)
+	d.subscriptions[eType] = append(d.subscriptions[eType], s)
+
+	return &Subscription{d: d, eType: eType, subscriptions: []*subscription{s}}
+}
+
+// SubscribeMatching registers a handler for all events matched by the matcher,
+// eg. an event.Pattern or an event.Predicate. The subscription behaves the same way
+// as one made using SubscribeContext.
+//
+//	d.SubscribeMatching(event.Pattern("*-deleted"), handler)
+func (d *Dispatcher) SubscribeMatching(m event.Matcher, handler event.ContextHandler, opts ...SubscribeOption) *Subscription {
+	s := d.newSubscription(handler, opts)
+	s.matcher = m
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.matchers = append(d.matchers, s)
+
+	return &Subscription{d: d, matcher: m, subscriptions: []*subscription{s}}
+}
+
+func (d *Dispatcher) newSubscription(handler event.ContextHandler, opts []SubscribeOption) *subscription {
+	s := &subscription{
+		handler: handler,
+		retry:   NoRetry,
+	}
+
+	for _, opt := range opts {
+		opt.applySubscription(s)
+	}
+
+	// Panics are always recovered, so that a single handler can't crash the service.
+	middlewares := append([]event.Middleware{middleware.Recover()}, d.opts.middlewares...)
+	middlewares = append(middlewares, s.middlewares...)
+	s.chain = event.AsContextHandler(event.Chain(middlewares...)(event.AsHandler(handler)))
+
+	return s
 }
 
 // Register is a helper method allowing to subscribe multiple event listeners at once.
-func (d *Dispatcher) Register(h ...Listener) {
+// Returns a Subscription per subscribed event type.
+func (d *Dispatcher) Register(h ...Listener) []*Subscription {
+	var subscriptions []*Subscription
 	for _, v := range h {
 		for eType, handlers := range v.EventHandlers() {
-			d.Subscribe(eType, handlers...)
+			subscriptions = append(subscriptions, d.Subscribe(eType, handlers...))
 		}
 	}
+	return subscriptions
 }
 
+// Dispatch queues invocations of all handlers subscribed to the event's type
+// and of handlers whose matchers match the event
+// in the dispatcher's worker pool. When the queue is full the dispatcher's
+// backpressure policy applies. Handler invocations dropped due to the policy
+// are passed to the subscriptions' failure sinks with ErrQueueFull.
+// Events dispatched after the dispatcher was shut down are dropped.
+// Handlers must use DispatchContext instead, see Block.
+//
+// If ordering is enabled, all handlers of an event are invoked one after another
+// by a single worker, after all handlers of the previous event with the same key finished.
 func (d *Dispatcher) Dispatch(e event.Event) {
-	limit := make(chan struct{}, d.maxWorkers)
+	d.dispatch(e, nil, false) //nolint:errcheck // Errors are logged or passed to the failure sinks.
+}
+
+// DispatchContext works like Dispatch and returns ErrDispatcherClosed if the event was dropped
+// because the dispatcher is shut down. When called with the context passed to a handler of this
+// dispatcher it returns ErrReentrantDispatch instead of waiting for space in the queue, see Block.
+// Handlers of the event which were queued before the error occurred are invoked anyway.
+func (d *Dispatcher) DispatchContext(ctx context.Context, e event.Event) error {
+	return d.dispatch(e, nil, ctx.Value(workerKey{}) == d)
+}
+
+// DispatchDelivery works like Dispatch and settles the delivery once all handlers
+// of its event finished, see AddDeliveryProvider.
+func (d *Dispatcher) DispatchDelivery(delivery event.Delivery) {
+	d.dispatch(delivery.Event, delivery.Acknowledger, false) //nolint:errcheck // Errors are logged or passed to the failure sinks.
+}
+
+func (d *Dispatcher) dispatch(e event.Event, ack event.Acknowledger, fromWorker bool) error {
+	d.mu.Lock()
+	subscriptions := slices.Clone(d.subscriptions[e.Type])
+	matchers := slices.Clone(d.matchers)
+	d.mu.Unlock()
+
+	for _, s := range matchers {
+		if s.matcher.Match(e) {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+
+	var jobs []job
+	key := ""
+	if d.opts.key != nil {
+		key = d.opts.key(e)
+	}
+
+	if key != "" && len(subscriptions) > 0 {
+		jobs = append(jobs, job{subscriptions: subscriptions, event: e, key: key})
+	} else {
+		for _, s := range subscriptions {
+			jobs = append(jobs, job{subscriptions: []*subscription{s}, event: e})
+		}
+	}
+
+	var st *settlement
+	if ack != nil {
+		st = newSettlement(ack, len(jobs))
+		if len(jobs) == 0 {
+			d.settle(st, false)
+			return nil
+		}
+	}
+
+	for i, j := range jobs {
+		j.settlement = st
+		if err := d.submit(j, fromWorker); err != nil {
+			// Handlers of jobs which were not submitted are not invoked.
+			for range jobs[i:] {
+				d.settle(st, true)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// submit queues the job and reports jobs dropped due to backpressure to the failure sinks.
+// Returns ErrDispatcherClosed if the dispatcher is shut down and ErrReentrantDispatch
+// if the job was submitted by a worker and would have to wait.
+func (d *Dispatcher) submit(j job, fromWorker bool) error {
+	dropped, err := d.pool.submit(j, fromWorker)
+	if dropped != nil {
+		d.failAll(dropped.subscriptions, Failure{Event: dropped.event, Err: ErrQueueFull})
+		d.settle(dropped.settlement, false)
+	}
 
-	for _, handler := range d.handlers[e.Type] {
-		limit <- struct{}{}
-		go func(handler event.Handler) {
-			handler.Handle(e)
-			<-limit
-		}(handler)
+	if errors.Is(err, ErrDispatcherClosed) {
+		d.opts.logger.Log(d.ctx, "Dropped event dispatched after shutdown", "err", err, "type", j.event.Type, "id", j.event.Id)
+		return err
 	}
+
+	if errors.Is(err, ErrReentrantDispatch) {
+		return err
+	}
+
+	if err != nil {
+		d.failAll(j.subscriptions, Failure{Event: j.event, Err: err})
+		d.settle(j.settlement, false)
+	}
+
+	return nil
+}
+
+// run is invoked by the pool's workers.
+func (d *Dispatcher) run(j job) {
+	ctx := context.WithValue(d.ctx, workerKey{}, d)
+
+	for _, s := range j.subscriptions {
+		// Do not start handlers abandoned by Shutdown.
+		if d.ctx.Err() != nil {
+			break
+		}
+
+		if s.removed.Load() {
+			continue
+		}
+
+		d.handle(ctx, s, j.event)
+	}
+
+	// Handlers abandoned by Shutdown might not have finished.
+	d.settle(j.settlement, d.ctx.Err() != nil)
+}
+
+func (d *Dispatcher) failAll(subscriptions []*subscription, f Failure) {
+	for _, s := range subscriptions {
+		d.fail(d.ctx, s, f)
+	}
+}
+
+// handle invokes the subscribed handler according to its retry policy
+// and passes the event to the failure sink if it keeps failing.
+func (d *Dispatcher) handle(ctx context.Context, s *subscription, e event.Event) {
+	attempts, err := s.retry.retry(ctx, s.chain, e)
+	if err == nil {
+		return
+	}
+
+	d.fail(ctx, s, Failure{
+		Event:    e,
+		Err:      err,
+		Attempts: attempts,
+	})
+}
+
+// fail passes the failure to the subscription's failure sink.
+func (d *Dispatcher) fail(ctx context.Context, s *subscription, f Failure) {
+	sink := s.sink
+	if sink == nil {
+		sink = LogSink(d.opts.logger)
+	}
+
+	sink.HandleFailure(ctx, f)
 }
@@ -2,11 +2,16 @@ package dispatcher
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"slices"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/event/middleware"
 	"github.com/krixlion/dev_forum-lib/mocks"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/sync/errgroup"
@@ -30,7 +35,7 @@ func TestDispatcher_Subscribe(t *testing.T) {
 			dispatcher.Subscribe(tt.eType, tt.handlers...)
 
 			for _, handler := range tt.handlers {
-				if !slices.Contains(dispatcher.handlers[tt.eType], handler) {
+				if !slices.ContainsFunc(dispatcher.subscriptions[tt.eType], func(s *subscription) bool { return s.handler == event.AsContextHandler(handler) }) {
 					t.Errorf("event.Handler was not registered succesfully")
 				}
 			}
@@ -74,6 +79,31 @@ func TestDispatcher_Dispatch(t *testing.T) {
 }
 
 func TestDispatcher_Run(t *testing.T) {
+	t.Run("Test if Run() returns on shutdown", func(t *testing.T) {
+		provider := make(chan event.Event)
+		defer close(provider)
+
+		d := NewDispatcher(20)
+		d.AddEventProviders(provider)
+
+		stopped := make(chan struct{})
+		go func() {
+			d.Run(context.Background())
+			close(stopped)
+		}()
+
+		if _, err := d.Shutdown(context.Background()); err != nil {
+			t.Errorf("Dispatcher.Shutdown() error = %v", err)
+			return
+		}
+
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Errorf("Run did not return on shutdown")
+		}
+	})
+
 	t.Run("Test if Run() returns on context cancellation", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		errg, ctx := errgroup.WithContext(ctx)
@@ -98,3 +128,539 @@ func TestDispatcher_Run(t *testing.T) {
 		}
 	})
 }
+
+func TestDispatcher_SubscribeContext(t *testing.T) {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+
+	tests := []struct {
+		desc         string
+		policy       RetryPolicy
+		errs         []error // Errors returned by consecutive invocations of the handler.
+		wantAttempts int
+		wantFailure  error
+	}{
+		{
+			desc:         "Test if successful handler is invoked once",
+			policy:       ExponentialRetry(3),
+			errs:         []error{nil},
+			wantAttempts: 1,
+		},
+		{
+			desc:         "Test if failed handler is retried until it succeeds",
+			policy:       ExponentialRetry(3),
+			errs:         []error{errTransient, errTransient, nil},
+			wantAttempts: 3,
+		},
+		{
+			desc:         "Test if handler is not retried by default",
+			policy:       NoRetry,
+			errs:         []error{errTransient},
+			wantAttempts: 1,
+			wantFailure:  errTransient,
+		},
+		{
+			desc:         "Test if failure is reported when attempts run out",
+			policy:       ExponentialRetry(2),
+			errs:         []error{errTransient, errTransient},
+			wantAttempts: 2,
+			wantFailure:  errTransient,
+		},
+		{
+			desc:         "Test if permanent errors are not retried",
+			policy:       ExponentialRetry(3),
+			errs:         []error{Permanent(errFatal)},
+			wantAttempts: 1,
+			wantFailure:  errFatal,
+		},
+		{
+			desc: "Test if errors are classified using the policy",
+			policy: func() RetryPolicy {
+				p := ExponentialRetry(3)
+				p.Retryable = func(err error) bool { return !errors.Is(err, errFatal) }
+				return p
+			}(),
+			errs:         []error{errTransient, errFatal},
+			wantAttempts: 2,
+			wantFailure:  errFatal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.policy.InitialInterval = time.Millisecond
+			e := event.Event{Type: event.ArticleCreated, Id: "1"}
+
+			attempts := 0
+			done := make(chan struct{})
+			handler := event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+				err := tt.errs[attempts]
+				attempts++
+				if err == nil {
+					close(done)
+				}
+				return err
+			})
+
+			var got *Failure
+			sink := FailureSinkFunc(func(ctx context.Context, f Failure) {
+				got = &f
+				close(done)
+			})
+
+			d := NewDispatcher(1)
+			d.SubscribeContext(e.Type, handler, WithRetryPolicy(tt.policy), WithFailureSink(sink))
+			d.Dispatch(e)
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Errorf("Handler did not finish")
+				return
+			}
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("Handler attempts:\n got = %v\n want = %v", attempts, tt.wantAttempts)
+				return
+			}
+
+			if tt.wantFailure == nil {
+				if got != nil {
+					t.Errorf("Unexpected failure = %+v", got)
+				}
+				return
+			}
+
+			if got == nil || !errors.Is(got.Err, tt.wantFailure) || got.Attempts != tt.wantAttempts || got.Event.Id != e.Id {
+				t.Errorf("Failure:\n got = %+v\n want err = %v\n want attempts = %v", got, tt.wantFailure, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{
+			desc: "Test if plain errors are retryable",
+			err:  errors.New("test"),
+			want: true,
+		},
+		{
+			desc: "Test if permanent errors are not retryable",
+			err:  fmt.Errorf("wrapped: %w", Permanent(errors.New("test"))),
+			want: false,
+		},
+		{
+			desc: "Test if invalid bodies are not retryable",
+			err:  fmt.Errorf("%w: test", event.ErrInvalidBody),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcher_Shutdown(t *testing.T) {
+	tests := []struct {
+		desc          string
+		handlerTime   time.Duration // How long the handler runs unless its context is cancelled.
+		timeout       time.Duration
+		wantAbandoned int
+		wantErr       error
+	}{
+		{
+			desc:        "Test if waits for in-flight handlers",
+			handlerTime: time.Millisecond * 20,
+			timeout:     time.Second,
+		},
+		{
+			desc:          "Test if reports abandoned handlers when deadline is exceeded",
+			handlerTime:   time.Second * 10,
+			timeout:       time.Millisecond * 20,
+			wantAbandoned: 2,
+			wantErr:       context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			started := make(chan struct{}, 2)
+			finished := make(chan struct{}, 2)
+			handler := event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+				defer func() { finished <- struct{}{} }()
+				started <- struct{}{}
+
+				select {
+				case <-time.After(tt.handlerTime):
+				case <-ctx.Done():
+				}
+				return nil
+			})
+
+			d := NewDispatcher(2)
+			d.SubscribeContext(event.ArticleCreated, handler)
+			d.SubscribeContext(event.ArticleCreated, handler)
+			d.Dispatch(event.Event{Type: event.ArticleCreated})
+			<-started
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			abandoned, err := d.Shutdown(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Dispatcher.Shutdown():\n error = %v\n wantErr = %v", err, tt.wantErr)
+				return
+			}
+
+			if abandoned != tt.wantAbandoned {
+				t.Errorf("Dispatcher.Shutdown():\n abandoned = %v\n want = %v", abandoned, tt.wantAbandoned)
+				return
+			}
+
+			// Abandoned handlers should get their context cancelled.
+			d.Wait()
+			if len(finished) != 2 {
+				t.Errorf("Handlers did not finish after shutdown")
+			}
+		})
+	}
+}
+
+func TestDispatcher_DispatchAfterShutdown(t *testing.T) {
+	t.Run("Test if events dispatched after shutdown are dropped", func(t *testing.T) {
+		handler := mocks.NewHandler()
+		handler.On("Handle", mock.AnythingOfType("event.Event")).Return()
+
+		d := NewDispatcher(2)
+		d.Subscribe(event.ArticleCreated, handler)
+
+		if _, err := d.Shutdown(context.Background()); err != nil {
+			t.Errorf("Dispatcher.Shutdown() error = %v", err)
+			return
+		}
+
+		d.Dispatch(event.Event{Type: event.ArticleCreated})
+		d.Wait()
+
+		handler.AssertNotCalled(t, "Handle", mock.Anything)
+	})
+}
+
+func TestDispatcher_Middleware(t *testing.T) {
+	t.Run("Test if global and subscription middlewares wrap the handler in order", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := []string{}
+		record := func(name string) event.Middleware {
+			return func(next event.Handler) event.Handler {
+				return event.HandlerFunc(func(e event.Event) {
+					mu.Lock()
+					calls = append(calls, name)
+					mu.Unlock()
+					next.Handle(e)
+				})
+			}
+		}
+
+		handler := mocks.NewHandler()
+		handler.On("Handle", mock.AnythingOfType("event.Event")).Return()
+
+		d := NewDispatcher(1, WithMiddleware(record("global")))
+		d.SubscribeContext(event.ArticleCreated, event.AsContextHandler(handler), WithMiddleware(record("subscription")))
+		d.Dispatch(event.Event{Type: event.ArticleCreated})
+		d.Wait()
+
+		handler.AssertNumberOfCalls(t, "Handle", 1)
+
+		want := []string{"global", "subscription"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Middlewares:\n got = %v\n want = %v", calls, want)
+		}
+	})
+
+	t.Run("Test if panicking handler is reported as a failure", func(t *testing.T) {
+		failures := make(chan Failure, 1)
+		sink := FailureSinkFunc(func(ctx context.Context, f Failure) {
+			failures <- f
+		})
+
+		d := NewDispatcher(1)
+		d.SubscribeContext(event.ArticleCreated, event.AsContextHandler(event.HandlerFunc(func(e event.Event) {
+			panic("test")
+		})), WithFailureSink(sink))
+		d.Dispatch(event.Event{Type: event.ArticleCreated})
+		d.Wait()
+
+		select {
+		case f := <-failures:
+			if !errors.Is(f.Err, middleware.ErrPanic) {
+				t.Errorf("Failure:\n error = %v\n want = %v", f.Err, middleware.ErrPanic)
+			}
+		default:
+			t.Errorf("Panic was not reported to the failure sink")
+		}
+	})
+}
+
+func TestSubscription_Unsubscribe(t *testing.T) {
+	t.Run("Test if unsubscribed handlers are not invoked", func(t *testing.T) {
+		removed := mocks.NewHandler()
+		removed.On("Handle", mock.AnythingOfType("event.Event")).Return()
+
+		kept := mocks.NewHandler()
+		kept.On("Handle", mock.AnythingOfType("event.Event")).Return()
+
+		d := NewDispatcher(2)
+		sub := d.Subscribe(event.ArticleCreated, removed)
+		d.Subscribe(event.ArticleCreated, kept)
+
+		sub.Unsubscribe()
+		sub.Unsubscribe()
+
+		d.Dispatch(event.Event{Type: event.ArticleCreated})
+		d.Wait()
+
+		removed.AssertNotCalled(t, "Handle", mock.Anything)
+		kept.AssertNumberOfCalls(t, "Handle", 1)
+	})
+
+	t.Run("Test if already dispatched events are not handled after unsubscribing", func(t *testing.T) {
+		release := make(chan struct{})
+		handled := make(chan struct{}, 1)
+
+		d := NewDispatcher(1)
+		d.SubscribeContext(event.ArticleUpdated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			<-release
+			return nil
+		}))
+		sub := d.SubscribeContext(event.ArticleCreated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			handled <- struct{}{}
+			return nil
+		}))
+
+		// Occupy the only worker, so that the next event waits in the queue.
+		d.Dispatch(event.Event{Type: event.ArticleUpdated})
+		d.Dispatch(event.Event{Type: event.ArticleCreated})
+
+		sub.Unsubscribe()
+		close(release)
+		d.Wait()
+
+		if len(handled) != 0 {
+			t.Errorf("Unsubscribed handler was invoked")
+		}
+	})
+}
+
+func TestDispatcher_Handlers(t *testing.T) {
+	t.Run("Test if subscribed handlers are returned per event type", func(t *testing.T) {
+		created, deleted := mocks.NewHandler(), mocks.NewHandler()
+
+		d := NewDispatcher(1)
+		d.Subscribe(event.ArticleCreated, created)
+		sub := d.Subscribe(event.ArticleDeleted, deleted)
+
+		got := d.Handlers()
+		want := map[event.EventType][]event.Handler{
+			event.ArticleCreated: {created},
+			event.ArticleDeleted: {deleted},
+		}
+		if !maps.EqualFunc(got, want, slices.Equal) {
+			t.Errorf("Dispatcher.Handlers():\n got = %v\n want = %v", got, want)
+			return
+		}
+
+		sub.Unsubscribe()
+
+		got = d.Handlers()
+		delete(want, event.ArticleDeleted)
+		if !maps.EqualFunc(got, want, slices.Equal) {
+			t.Errorf("Dispatcher.Handlers() after Unsubscribe():\n got = %v\n want = %v", got, want)
+		}
+	})
+}
+
+func TestDispatcher_Providers(t *testing.T) {
+	t.Run("Test if providers can be added and removed while running without losing events", func(t *testing.T) {
+		const n = 100
+
+		var mu sync.Mutex
+		handled := 0
+
+		d := NewDispatcher(4)
+		d.SubscribeContext(event.ArticleCreated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled++
+			return nil
+		}))
+
+		first := make(chan event.Event)
+		provider := d.AddEventProvider(first)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		go func() {
+			d.Run(ctx)
+			close(stopped)
+		}()
+
+		// Events sent to a removed provider stay in its channel.
+		for i := 0; i < n; i++ {
+			first <- event.Event{Type: event.ArticleCreated}
+		}
+		provider.Remove()
+
+		second := make(chan event.Event, n)
+		d.AddEventProvider(second)
+		for i := 0; i < n; i++ {
+			second <- event.Event{Type: event.ArticleCreated}
+		}
+		close(second)
+
+		// Wait for the closed provider to be detached.
+		for {
+			d.mu.Lock()
+			remaining := len(d.providers)
+			d.mu.Unlock()
+			if remaining == 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		cancel()
+		<-stopped
+		d.Wait()
+
+		if handled != 2*n {
+			t.Errorf("Events were lost:\n got = %v\n want = %v", handled, 2*n)
+		}
+	})
+}
+
+func TestDispatcher_SubscribeMatching(t *testing.T) {
+	t.Run("Test if handlers are invoked for events matched by patterns and predicates", func(t *testing.T) {
+		var mu sync.Mutex
+		handled := make(map[string][]event.EventType)
+		record := func(name string) event.ContextHandler {
+			return event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+				mu.Lock()
+				defer mu.Unlock()
+				handled[name] = append(handled[name], e.Type)
+				return nil
+			})
+		}
+
+		d := NewDispatcher(1)
+		d.SubscribeMatching(event.Pattern("article-*"), record("articles"))
+		deleted := d.SubscribeMatching(event.Pattern("*-deleted"), record("deleted"))
+		d.SubscribeMatching(event.Predicate(func(e event.Event) bool {
+			return e.Metadata["tenant"] == "test"
+		}), record("tenant"))
+
+		d.Dispatch(event.Event{Type: event.ArticleCreated})
+		d.Dispatch(event.Event{Type: event.UserDeleted, Metadata: map[string]string{"tenant": "test"}})
+		d.Wait()
+
+		deleted.Unsubscribe()
+		d.Dispatch(event.Event{Type: event.ArticleDeleted})
+		d.Wait()
+
+		want := map[string][]event.EventType{
+			"articles": {event.ArticleCreated, event.ArticleDeleted},
+			"deleted":  {event.UserDeleted},
+			"tenant":   {event.UserDeleted},
+		}
+		if !maps.EqualFunc(handled, want, slices.Equal) {
+			t.Errorf("Dispatcher.SubscribeMatching():\n got = %v\n want = %v", handled, want)
+		}
+	})
+}
+
+// acknowledger records how a delivery was settled.
+type acknowledger struct {
+	settled chan string
+}
+
+func (a acknowledger) Ack() error {
+	a.settled <- "ack"
+	return nil
+}
+
+func (a acknowledger) Nack(requeue bool) error {
+	a.settled <- fmt.Sprintf("nack requeue=%v", requeue)
+	return nil
+}
+
+func (a acknowledger) Reject(requeue bool) error {
+	a.settled <- fmt.Sprintf("reject requeue=%v", requeue)
+	return nil
+}
+
+func TestDispatcher_DispatchDelivery(t *testing.T) {
+	t.Run("Test if delivery is acknowledged once all handlers finished", func(t *testing.T) {
+		release := make(chan struct{})
+		d := NewDispatcher(2)
+		d.SubscribeContext(event.ArticleCreated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			<-release
+			return nil
+		}))
+		d.SubscribeContext(event.ArticleCreated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			return errors.New("test err")
+		}), WithRetryPolicy(NoRetry))
+
+		ack := acknowledger{settled: make(chan string, 1)}
+		d.DispatchDelivery(event.Delivery{Event: event.Event{Type: event.ArticleCreated}, Acknowledger: ack})
+
+		select {
+		case got := <-ack.settled:
+			t.Errorf("Delivery was settled before the handlers finished: %v", got)
+			return
+		case <-time.After(time.Millisecond * 20):
+		}
+
+		close(release)
+
+		if got := <-ack.settled; got != "ack" {
+			t.Errorf("Dispatcher.DispatchDelivery():\n got = %v\n want = %v", got, "ack")
+		}
+	})
+
+	t.Run("Test if delivery without handlers is acknowledged", func(t *testing.T) {
+		d := NewDispatcher(1)
+
+		ack := acknowledger{settled: make(chan string, 1)}
+		d.DispatchDelivery(event.Delivery{Event: event.Event{Type: event.ArticleCreated}, Acknowledger: ack})
+
+		if got := <-ack.settled; got != "ack" {
+			t.Errorf("Dispatcher.DispatchDelivery():\n got = %v\n want = %v", got, "ack")
+		}
+	})
+
+	t.Run("Test if delivery dispatched after shutdown is requeued", func(t *testing.T) {
+		d := NewDispatcher(1)
+		d.SubscribeContext(event.ArticleCreated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			return nil
+		}))
+
+		if _, err := d.Shutdown(context.Background()); err != nil {
+			t.Errorf("Dispatcher.Shutdown() error = %v", err)
+			return
+		}
+
+		ack := acknowledger{settled: make(chan string, 1)}
+		d.DispatchDelivery(event.Delivery{Event: event.Event{Type: event.ArticleCreated}, Acknowledger: ack})
+
+		if got, want := <-ack.settled, "nack requeue=true"; got != want {
+			t.Errorf("Dispatcher.DispatchDelivery():\n got = %v\n want = %v", got, want)
+		}
+	})
+}
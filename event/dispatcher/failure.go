@@ -0,0 +1,35 @@
+package dispatcher
+
+import (
+	"context"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+)
+
+// Failure describes an event which a handler failed to handle.
+type Failure struct {
+	Event    event.Event
+	Err      error // Last error returned by the handler.
+	Attempts int   // Number of times the handler was invoked.
+}
+
+// FailureSink receives events which handlers failed to handle despite retries,
+// eg. in order to store them for inspection or to publish them to a dead-letter queue.
+type FailureSink interface {
+	HandleFailure(context.Context, Failure)
+}
+
+type FailureSinkFunc func(context.Context, Failure)
+
+func (fn FailureSinkFunc) HandleFailure(ctx context.Context, f Failure) {
+	fn(ctx, f)
+}
+
+// LogSink returns a FailureSink logging failures using given logger.
+// It is the default sink of the Dispatcher.
+func LogSink(logger logging.Logger) FailureSink {
+	return FailureSinkFunc(func(ctx context.Context, f Failure) {
+		logger.Log(ctx, "Failed to handle event", "err", f.Err, "type", f.Event.Type, "id", f.Event.Id, "attempts", f.Attempts)
+	})
+}
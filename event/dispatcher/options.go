@@ -0,0 +1,131 @@
+package dispatcher
+
+import (
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(opts *options)
+
+func (fn optionFunc) apply(opts *options) {
+	fn(opts)
+}
+
+type options struct {
+	logger       logging.Logger
+	meter        metric.Meter
+	queueDepth   int
+	backpressure BackpressurePolicy
+	key          KeyFunc // Nil unless events are ordered.
+	middlewares  []event.Middleware
+}
+
+func defaultOptions() options {
+	return options{
+		logger:       nulls.NullLogger{},
+		meter:        noop.NewMeterProvider().Meter(""),
+		queueDepth:   100,
+		backpressure: Block,
+	}
+}
+
+func WithLogger(logger logging.Logger) Option {
+	return optionFunc(func(opts *options) {
+		opts.logger = logger
+	})
+}
+
+// WithMeter sets the meter used to record the queue depth, the number of busy workers,
+// the number of dropped handler invocations and the handlers' latency.
+func WithMeter(meter metric.Meter) Option {
+	return optionFunc(func(opts *options) {
+		opts.meter = meter
+	})
+}
+
+// WithQueueDepth sets the max number of handler invocations waiting for a worker.
+// Defaults to 100.
+func WithQueueDepth(depth int) Option {
+	return optionFunc(func(opts *options) {
+		opts.queueDepth = depth
+	})
+}
+
+// WithBackpressure sets the policy applied to dispatched events when the queue is full.
+// Defaults to Block.
+func WithBackpressure(policy BackpressurePolicy) Option {
+	return optionFunc(func(opts *options) {
+		opts.backpressure = policy
+	})
+}
+
+// WithOrdering makes the dispatcher partition events using given KeyFunc
+// and handle events of each partition one at a time, in the order they were dispatched.
+// All handlers of an event finish before the next event of the same partition is handled.
+// Different partitions are still handled in parallel.
+//
+//	d := dispatcher.NewDispatcher(10, dispatcher.WithOrdering(dispatcher.AggregateKey))
+func WithOrdering(key KeyFunc) Option {
+	return optionFunc(func(opts *options) {
+		opts.key = key
+	})
+}
+
+// SubscribeOption configures a single subscription.
+type SubscribeOption interface {
+	applySubscription(*subscription)
+}
+
+type subscribeOptionFunc func(s *subscription)
+
+func (fn subscribeOptionFunc) applySubscription(s *subscription) {
+	fn(s)
+}
+
+// WithRetryPolicy sets the policy used to retry failed invocations of the handler.
+// Defaults to NoRetry.
+func WithRetryPolicy(policy RetryPolicy) SubscribeOption {
+	return subscribeOptionFunc(func(s *subscription) {
+		s.retry = policy
+	})
+}
+
+// WithFailureSink sets the sink receiving events which the handler failed to handle.
+// Defaults to LogSink using the dispatcher's logger.
+func WithFailureSink(sink FailureSink) SubscribeOption {
+	return subscribeOptionFunc(func(s *subscription) {
+		s.sink = sink
+	})
+}
+
+// MiddlewareOption is both an Option and a SubscribeOption.
+type MiddlewareOption interface {
+	Option
+	SubscribeOption
+}
+
+type middlewareOption []event.Middleware
+
+func (mws middlewareOption) apply(opts *options) {
+	opts.middlewares = append(opts.middlewares, mws...)
+}
+
+func (mws middlewareOption) applySubscription(s *subscription) {
+	s.middlewares = append(s.middlewares, mws...)
+}
+
+// WithMiddleware wraps handlers with given middlewares, the first one being the outermost.
+// When passed to NewDispatcher the middlewares wrap all handlers subscribed to the dispatcher.
+// When passed to SubscribeContext they wrap only the subscribed handler, inside the global ones.
+//
+//	d.SubscribeContext(event.ArticleCreated, handler, dispatcher.WithMiddleware(middleware.Timeout(time.Second)))
+func WithMiddleware(middlewares ...event.Middleware) MiddlewareOption {
+	return middlewareOption(middlewares)
+}
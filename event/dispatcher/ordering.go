@@ -0,0 +1,21 @@
+package dispatcher
+
+import "github.com/krixlion/dev_forum-lib/event"
+
+// KeyFunc returns a partition key of the event. Events with the same key are handled in order.
+// Events with an empty key are not ordered.
+type KeyFunc func(event.Event) string
+
+// AggregateKey orders events per aggregate instance, eg. per article.
+// Events with no aggregate instance ID are not ordered. See event.AggregateKey.
+func AggregateKey(e event.Event) string {
+	return event.AggregateKey(e)
+}
+
+// MetadataKey returns a KeyFunc ordering events by the value of the given metadata key.
+// Events without the key are not ordered.
+func MetadataKey(key string) KeyFunc {
+	return func(e event.Event) string {
+		return e.Metadata[key]
+	}
+}
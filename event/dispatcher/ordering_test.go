@@ -0,0 +1,115 @@
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+func TestKeyFuncs(t *testing.T) {
+	tests := []struct {
+		desc string
+		key  KeyFunc
+		arg  event.Event
+		want string
+	}{
+		{
+			desc: "Test if AggregateKey combines aggregate and its instance",
+			key:  AggregateKey,
+			arg:  event.Event{AggregateId: event.ArticleAggregate, AggregateInstanceId: "1"},
+			want: "article/1",
+		},
+		{
+			desc: "Test if AggregateKey does not order events without aggregate instance",
+			key:  AggregateKey,
+			arg:  event.Event{AggregateId: event.ArticleAggregate},
+			want: "",
+		},
+		{
+			desc: "Test if MetadataKey returns the metadata value",
+			key:  MetadataKey("tenant"),
+			arg:  event.Event{Metadata: map[string]string{"tenant": "test"}},
+			want: "test",
+		},
+		{
+			desc: "Test if MetadataKey does not order events without the metadata key",
+			key:  MetadataKey("tenant"),
+			arg:  event.Event{},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := tt.key(tt.arg); got != tt.want {
+				t.Errorf("KeyFunc():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcher_Ordering(t *testing.T) {
+	t.Run("Test if events of the same aggregate are handled in order one at a time", func(t *testing.T) {
+		const aggregates, eventsPerAggregate = 3, 20
+
+		var mu sync.Mutex
+		handled := make(map[string][]int)
+		active := make(map[string]int)
+		overlapped := false
+
+		// Each event is handled by two handlers, both have to finish before the next event of the aggregate.
+		handler := event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			mu.Lock()
+			active[e.AggregateInstanceId]++
+			overlapped = overlapped || active[e.AggregateInstanceId] > 1
+			mu.Unlock()
+
+			time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+
+			seq, err := strconv.Atoi(e.Id)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			active[e.AggregateInstanceId]--
+			handled[e.AggregateInstanceId] = append(handled[e.AggregateInstanceId], seq)
+			mu.Unlock()
+			return nil
+		})
+
+		d := NewDispatcher(aggregates, WithOrdering(AggregateKey))
+		d.SubscribeContext(event.ArticleUpdated, handler)
+		d.SubscribeContext(event.ArticleUpdated, handler)
+
+		for i := 0; i < eventsPerAggregate; i++ {
+			for a := 0; a < aggregates; a++ {
+				d.Dispatch(event.Event{
+					Id:                  strconv.Itoa(i),
+					Type:                event.ArticleUpdated,
+					AggregateId:         event.ArticleAggregate,
+					AggregateInstanceId: fmt.Sprint(a),
+				})
+			}
+		}
+		d.Wait()
+
+		if overlapped {
+			t.Errorf("Events of the same aggregate were handled concurrently")
+			return
+		}
+
+		for a := 0; a < aggregates; a++ {
+			got := handled[fmt.Sprint(a)]
+			if len(got) != 2*eventsPerAggregate || !slices.IsSorted(got) {
+				t.Errorf("Events of aggregate %d were not handled in order:\n got = %v", a, got)
+			}
+		}
+	})
+}
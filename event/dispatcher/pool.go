@@ -0,0 +1,302 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	ErrQueueFull         = errors.New("dispatcher queue is full")
+	ErrReentrantDispatch = errors.New("dispatching from a handler would block until the handler returns")
+)
+
+// BackpressurePolicy determines what happens to dispatched events when the queue is full.
+type BackpressurePolicy int
+
+const (
+	// Block makes Dispatch wait until there is space in the queue. It is the default policy.
+	//
+	// Handlers dispatching follow-up events to the same dispatcher must use DispatchContext
+	// with the context passed to them. If all workers were blocked dispatching while the queue
+	// is full, none of them could make room in the queue and the dispatcher would deadlock,
+	// so DispatchContext returns ErrReentrantDispatch instead of waiting.
+	Block BackpressurePolicy = iota
+
+	// DropOldest makes room in the queue by dropping the handler invocation waiting the longest.
+	DropOldest
+
+	// Reject drops the handler invocations which do not fit into the queue.
+	Reject
+)
+
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop_oldest"
+	case Reject:
+		return "reject"
+	default:
+		return "unknown"
+	}
+}
+
+// job is an invocation of subscribed handlers.
+type job struct {
+	subscriptions []*subscription
+	event         event.Event
+	key           string      // Jobs with the same non-empty key are run one at a time in order.
+	settlement    *settlement // Nil unless the event was dispatched from a delivery.
+}
+
+// pool runs jobs using up to maxWorkers goroutines shared by all dispatched events.
+// Workers are started on demand and exit once the queue is empty.
+// A worker taking a job whose key is already being run by another worker
+// leaves the job in the key's backlog, which is run by the other worker afterwards.
+type pool struct {
+	mu         sync.Mutex
+	space      *sync.Cond // Signalled when there might be space in the queue or the pool is closed.
+	drained    *sync.Cond // Broadcast when there are no pending handler invocations.
+	queue      []job
+	backlogs   map[string][]job // Jobs waiting for a job with the same key to finish, keyed by the key being run.
+	backlogged int              // Number of jobs in all backlogs.
+	queueDepth int
+	maxWorkers int
+	workers    int // Number of running workers.
+	idle       int // Number of running workers which are not running a job.
+	policy     BackpressurePolicy
+	closed     bool
+	pending    int // Number of handler invocations queued or running.
+	run        func(job)
+
+	queued   metric.Int64UpDownCounter
+	busy     metric.Int64UpDownCounter
+	dropped  metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newPool(maxWorkers, queueDepth int, policy BackpressurePolicy, meter metric.Meter, logger logging.Logger, run func(job)) *pool {
+	p := &pool{
+		queueDepth: max(queueDepth, 0),
+		maxWorkers: max(maxWorkers, 1),
+		policy:     policy,
+		run:        run,
+		backlogs:   make(map[string][]job),
+	}
+	p.space = sync.NewCond(&p.mu)
+	p.drained = sync.NewCond(&p.mu)
+
+	errs := make([]error, 4)
+	p.queued, errs[0] = meter.Int64UpDownCounter("dispatcher.queue.depth", metric.WithDescription("Number of handler invocations waiting for a worker."))
+	p.busy, errs[1] = meter.Int64UpDownCounter("dispatcher.workers.busy", metric.WithDescription("Number of workers running handlers."))
+	p.dropped, errs[2] = meter.Int64Counter("dispatcher.dropped", metric.WithDescription("Number of handler invocations dropped because the queue was full."))
+	p.duration, errs[3] = meter.Float64Histogram("dispatcher.handler.duration", metric.WithDescription("Time taken by handlers, including retries."), metric.WithUnit("s"))
+
+	if err := errors.Join(errs...); err != nil {
+		logger.Log(context.Background(), "Failed to create dispatcher metrics", "err", err)
+	}
+
+	return p
+}
+
+// submit queues the job, starting a worker if there are less than maxWorkers running.
+// When the queue is full the job is handled according to the pool's backpressure policy.
+// Jobs submitted by workers are not waited with under the Block policy.
+// Returns the job which was dropped to make room for the submitted one, if any,
+// ErrQueueFull if the job was rejected, ErrReentrantDispatch if a worker would have to wait
+// and ErrDispatcherClosed if the pool is closed.
+func (p *pool) submit(j job, fromWorker bool) (dropped *job, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for !p.closed && p.full() {
+		switch p.policy {
+		case DropOldest:
+			if len(p.queue) == 0 {
+				// There is no queue to make room in, reject instead.
+				p.reportDropped(j)
+				return nil, ErrQueueFull
+			}
+
+			oldest := p.queue[0]
+			p.queue = p.queue[1:]
+			p.finish(oldest)
+			p.queued.Add(context.Background(), -1)
+			p.reportDropped(oldest)
+			dropped = &oldest
+		case Reject:
+			p.reportDropped(j)
+			return nil, ErrQueueFull
+		default:
+			if fromWorker {
+				return nil, ErrReentrantDispatch
+			}
+			p.space.Wait()
+		}
+	}
+
+	if p.closed {
+		return dropped, ErrDispatcherClosed
+	}
+
+	p.queue = append(p.queue, j)
+	p.pending += len(j.subscriptions)
+	p.queued.Add(context.Background(), 1)
+
+	if len(p.queue) > p.idle && p.workers < p.maxWorkers {
+		p.workers++
+		p.idle++
+		go p.work()
+	}
+
+	return dropped, nil
+}
+
+// full must be called with the mutex held.
+// Jobs about to be taken by idle workers or by workers which can still be started
+// are not counted against the queue depth.
+func (p *pool) full() bool {
+	return len(p.queue)+p.backlogged-p.idle-(p.maxWorkers-p.workers) >= p.queueDepth
+}
+
+func (p *pool) work() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		j, ok := p.take()
+		if !ok {
+			p.workers--
+			p.idle--
+			p.space.Signal()
+			return
+		}
+
+		p.idle--
+		p.space.Signal()
+
+		// Run the job and then the jobs which were backlogged behind it.
+		for ok {
+			p.mu.Unlock()
+			p.runJob(j)
+			p.mu.Lock()
+
+			p.finish(j)
+			j, ok = p.takeBacklogged(j.key)
+			p.space.Signal()
+		}
+
+		p.idle++
+		p.space.Signal()
+	}
+}
+
+// take returns the next job from the queue which can be run right away.
+// Jobs whose keys are being run are moved to their backlogs.
+// take must be called with the mutex held.
+func (p *pool) take() (job, bool) {
+	for len(p.queue) > 0 {
+		j := p.queue[0]
+		p.queue = p.queue[1:]
+
+		if j.key == "" {
+			return j, true
+		}
+
+		if backlog, running := p.backlogs[j.key]; running {
+			p.backlogs[j.key] = append(backlog, j)
+			p.backlogged++
+			continue
+		}
+
+		p.backlogs[j.key] = nil
+		return j, true
+	}
+
+	return job{}, false
+}
+
+// takeBacklogged returns the next job waiting for the job with the given key to finish
+// or marks the key as no longer run if there are none.
+// takeBacklogged must be called with the mutex held.
+func (p *pool) takeBacklogged(key string) (job, bool) {
+	if key == "" {
+		return job{}, false
+	}
+
+	backlog := p.backlogs[key]
+	if len(backlog) == 0 {
+		delete(p.backlogs, key)
+		return job{}, false
+	}
+
+	p.backlogs[key] = backlog[1:]
+	p.backlogged--
+	return backlog[0], true
+}
+
+func (p *pool) runJob(j job) {
+	ctx := context.Background()
+	attrs := metric.WithAttributes(attribute.String("event.type", string(j.event.Type)))
+
+	p.queued.Add(ctx, -1)
+	p.busy.Add(ctx, 1)
+	start := time.Now()
+
+	p.run(j)
+
+	p.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	p.busy.Add(ctx, -1)
+}
+
+// finish must be called with the mutex held.
+func (p *pool) finish(j job) {
+	p.pending -= len(j.subscriptions)
+	if p.pending == 0 {
+		p.drained.Broadcast()
+	}
+}
+
+// reportDropped must be called with the mutex held.
+func (p *pool) reportDropped(j job) {
+	p.dropped.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("event.type", string(j.event.Type)),
+		attribute.String("policy", p.policy.String()),
+	))
+}
+
+// close makes the pool reject new jobs and wakes up the blocked submitters.
+// Queued jobs are still run.
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.space.Broadcast()
+}
+
+// inFlight returns the number of queued and running handler invocations.
+func (p *pool) inFlight() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pending
+}
+
+// wait blocks until there are no queued or running handler invocations.
+// Jobs may be submitted while waiting.
+func (p *pool) wait() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.pending > 0 {
+		p.drained.Wait()
+	}
+}
@@ -0,0 +1,257 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/event"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// blockingHandler records handled events and blocks until released.
+type blockingHandler struct {
+	mu      sync.Mutex
+	handled []string
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{
+		started: make(chan struct{}, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (h *blockingHandler) HandleContext(ctx context.Context, e event.Event) error {
+	h.started <- struct{}{}
+	<-h.release
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handled = append(h.handled, e.Id)
+	return nil
+}
+
+func TestDispatcher_Backpressure(t *testing.T) {
+	tests := []struct {
+		desc        string
+		policy      BackpressurePolicy
+		wantHandled []string
+		wantFailed  []string
+	}{
+		{
+			desc:        "Test if oldest queued event is dropped",
+			policy:      DropOldest,
+			wantHandled: []string{"1", "3"},
+			wantFailed:  []string{"2"},
+		},
+		{
+			desc:        "Test if new event is rejected",
+			policy:      Reject,
+			wantHandled: []string{"1", "2"},
+			wantFailed:  []string{"3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			reader := sdkmetric.NewManualReader()
+			meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+			var failed []string
+			sink := FailureSinkFunc(func(ctx context.Context, f Failure) {
+				if errors.Is(f.Err, ErrQueueFull) {
+					failed = append(failed, f.Event.Id)
+				}
+			})
+
+			handler := newBlockingHandler()
+			d := NewDispatcher(1, WithQueueDepth(1), WithBackpressure(tt.policy), WithMeter(meter))
+			d.SubscribeContext(event.ArticleCreated, handler, WithFailureSink(sink))
+
+			d.Dispatch(event.Event{Type: event.ArticleCreated, Id: "1"})
+			<-handler.started
+			d.Dispatch(event.Event{Type: event.ArticleCreated, Id: "2"})
+			d.Dispatch(event.Event{Type: event.ArticleCreated, Id: "3"})
+
+			close(handler.release)
+			d.Wait()
+
+			if !cmp.Equal(handler.handled, tt.wantHandled) || !cmp.Equal(failed, tt.wantFailed) {
+				t.Errorf("Dispatcher.Dispatch():\n handled = %v\n want = %v\n failed = %v\n want = %v", handler.handled, tt.wantHandled, failed, tt.wantFailed)
+				return
+			}
+
+			rm := metricdata.ResourceMetrics{}
+			if err := reader.Collect(context.Background(), &rm); err != nil {
+				t.Errorf("Reader.Collect() error = %v", err)
+				return
+			}
+
+			var dropped int64
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == "dispatcher.dropped" {
+						for _, dp := range sum.DataPoints {
+							dropped += dp.Value
+						}
+					}
+				}
+			}
+
+			if dropped != int64(len(tt.wantFailed)) {
+				t.Errorf("Dropped metric:\n got = %v\n want = %v", dropped, len(tt.wantFailed))
+			}
+		})
+	}
+}
+
+func TestDispatcher_BackpressureBlock(t *testing.T) {
+	t.Run("Test if Dispatch blocks until there is space in the queue", func(t *testing.T) {
+		handler := newBlockingHandler()
+		d := NewDispatcher(1, WithQueueDepth(1), WithBackpressure(Block))
+		d.SubscribeContext(event.ArticleCreated, handler)
+
+		d.Dispatch(event.Event{Type: event.ArticleCreated, Id: "1"})
+		<-handler.started
+		d.Dispatch(event.Event{Type: event.ArticleCreated, Id: "2"})
+
+		dispatched := make(chan struct{})
+		go func() {
+			d.Dispatch(event.Event{Type: event.ArticleCreated, Id: "3"})
+			close(dispatched)
+		}()
+
+		select {
+		case <-dispatched:
+			t.Errorf("Dispatch did not block when the queue was full")
+			return
+		case <-time.After(time.Millisecond * 20):
+		}
+
+		close(handler.release)
+		<-dispatched
+		d.Wait()
+
+		if want := []string{"1", "2", "3"}; !cmp.Equal(handler.handled, want) {
+			t.Errorf("Dispatcher.Dispatch():\n handled = %v\n want = %v", handler.handled, want)
+		}
+	})
+}
+
+func TestDispatcher_MaxWorkers(t *testing.T) {
+	t.Run("Test if concurrency is limited across events", func(t *testing.T) {
+		var running, maxRunning atomic.Int64
+		handler := event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+
+			for {
+				current := maxRunning.Load()
+				if n <= current || maxRunning.CompareAndSwap(current, n) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond * 5)
+			return nil
+		})
+
+		d := NewDispatcher(3)
+		d.SubscribeContext(event.ArticleCreated, handler)
+
+		for i := 0; i < 20; i++ {
+			d.Dispatch(event.Event{Type: event.ArticleCreated})
+		}
+		d.Wait()
+
+		if got := maxRunning.Load(); got != 3 {
+			t.Errorf("Max concurrent handlers:\n got = %v\n want = %v", got, 3)
+		}
+	})
+}
+
+func TestDispatcher_WaitWhileDispatching(t *testing.T) {
+	t.Run("Test if Wait can be called while events are dispatched", func(t *testing.T) {
+		var handled atomic.Int64
+		d := NewDispatcher(2)
+		d.SubscribeContext(event.ArticleCreated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			handled.Add(1)
+			return nil
+		}))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					d.Dispatch(event.Event{Type: event.ArticleCreated})
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					d.Wait()
+				}
+			}()
+		}
+		wg.Wait()
+		d.Wait()
+
+		if got := handled.Load(); got != 400 {
+			t.Errorf("Handled events:\n got = %v\n want = %v", got, 400)
+		}
+	})
+}
+
+func TestDispatcher_DispatchContext(t *testing.T) {
+	t.Run("Test if dispatching from a handler returns an error instead of blocking", func(t *testing.T) {
+		d := NewDispatcher(1, WithQueueDepth(1), WithBackpressure(Block))
+		d.SubscribeContext(event.ArticleDeleted, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			return nil
+		}))
+
+		errs := make(chan error, 2)
+		d.SubscribeContext(event.ArticleCreated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			for i := 0; i < 2; i++ {
+				errs <- d.DispatchContext(ctx, event.Event{Type: event.ArticleDeleted})
+			}
+			return nil
+		}))
+
+		d.Dispatch(event.Event{Type: event.ArticleCreated})
+		d.Wait()
+
+		if err := <-errs; err != nil {
+			t.Errorf("Dispatcher.DispatchContext() with space in the queue error = %v", err)
+			return
+		}
+
+		if err := <-errs; !errors.Is(err, ErrReentrantDispatch) {
+			t.Errorf("Dispatcher.DispatchContext() with full queue:\n got = %v\n want = %v", err, ErrReentrantDispatch)
+		}
+	})
+
+	t.Run("Test if dispatching after shutdown returns an error", func(t *testing.T) {
+		d := NewDispatcher(1)
+		d.SubscribeContext(event.ArticleCreated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			return nil
+		}))
+
+		if _, err := d.Shutdown(context.Background()); err != nil {
+			t.Errorf("Dispatcher.Shutdown() error = %v", err)
+			return
+		}
+
+		if err := d.DispatchContext(context.Background(), event.Event{Type: event.ArticleCreated}); !errors.Is(err, ErrDispatcherClosed) {
+			t.Errorf("Dispatcher.DispatchContext():\n got = %v\n want = %v", err, ErrDispatcherClosed)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+// Provider is an event source added to the dispatcher.
+type Provider struct {
+	d          *Dispatcher
+	events     <-chan event.Event    // Nil for delivery providers.
+	deliveries <-chan event.Delivery // Nil for event providers.
+	once       sync.Once
+	removed    chan struct{}
+}
+
+// Remove detaches the provider from the dispatcher.
+// An event is never lost during removal, it is either dispatched or left in the provider's channel.
+// Calling Remove more than once is safe.
+func (p *Provider) Remove() {
+	p.once.Do(func() {
+		p.d.mu.Lock()
+		defer p.d.mu.Unlock()
+
+		delete(p.d.providers, p)
+		close(p.removed)
+	})
+}
+
+// AddEventProvider registers provided channel as an event source.
+// Events from the provider will be dispatched while the dispatcher is running,
+// until the channel is closed or the provider is removed.
+// Providers can be added and removed while the dispatcher is running.
+func (d *Dispatcher) AddEventProvider(events <-chan event.Event) *Provider {
+	return d.addProvider(&Provider{
+		d:       d,
+		events:  events,
+		removed: make(chan struct{}),
+	})
+}
+
+// AddDeliveryProvider registers provided channel, eg. returned by an event.DeliveryConsumer,
+// as an event source. Deliveries are dispatched the same way as events of AddEventProvider.
+// Every delivery is acknowledged once all handlers of its event finished, either successfully
+// or by passing the event to their failure sinks, so that events which are being handled
+// when the process stops are redelivered. Deliveries whose handlers were not invoked
+// or were abandoned because the dispatcher was shut down are requeued.
+func (d *Dispatcher) AddDeliveryProvider(deliveries <-chan event.Delivery) *Provider {
+	return d.addProvider(&Provider{
+		d:          d,
+		deliveries: deliveries,
+		removed:    make(chan struct{}),
+	})
+}
+
+func (d *Dispatcher) addProvider(p *Provider) *Provider {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.providers[p] = struct{}{}
+	if d.runCtx != nil {
+		d.forward(d.runCtx, p)
+	}
+
+	return p
+}
+
+// AddEventProviders registers provided channels as an event source.
+// Events from these providers will be parsed by the subscribed handlers.
+// See AddEventProvider.
+func (d *Dispatcher) AddEventProviders(providers ...<-chan event.Event) []*Provider {
+	added := make([]*Provider, 0, len(providers))
+	for _, events := range providers {
+		added = append(added, d.AddEventProvider(events))
+	}
+	return added
+}
+
+// forward dispatches events received from the provider until it is closed or removed,
+// or the context is cancelled. Should be called with d.mu locked.
+func (d *Dispatcher) forward(ctx context.Context, p *Provider) {
+	d.forwarders.Add(1)
+	go func() {
+		defer d.forwarders.Done()
+		for {
+			select {
+			case e, ok := <-p.events:
+				if !ok {
+					p.Remove()
+					return
+				}
+				d.Dispatch(e)
+			case delivery, ok := <-p.deliveries:
+				if !ok {
+					p.Remove()
+					return
+				}
+				d.DispatchDelivery(delivery)
+			case <-p.removed:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,113 @@
+package dispatcher
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+// RetryPolicy determines how failed handler invocations are retried.
+// Retries are delayed using exponential backoff with jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of times the handler is invoked for a single event.
+	// Values lower than 1 are treated as 1, ie. no retries.
+	MaxAttempts int
+
+	// InitialInterval is the delay before the first retry.
+	InitialInterval time.Duration
+
+	// MaxInterval caps the delay between retries.
+	MaxInterval time.Duration
+
+	// Multiplier is the factor the delay is multiplied by after each retry.
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to given fraction of it, eg. 0.5 means ±50%.
+	Jitter float64
+
+	// Retryable reports whether the handler should be retried after failing with given error.
+	// Defaults to IsRetryable.
+	Retryable func(error) bool
+}
+
+// NoRetry is the default policy. Handlers are invoked only once.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// ExponentialRetry returns a policy invoking the handler up to maxAttempts times,
+// starting with 100ms delay between attempts and doubling it up to 10s, with a 50% jitter.
+func ExponentialRetry(maxAttempts int) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     maxAttempts,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.5,
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps the error returned by a handler to indicate it should not be retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsRetryable reports whether the error is transient. Errors wrapped using Permanent
+// and errors caused by events not matching their registered payload types are not.
+func IsRetryable(err error) bool {
+	return !errors.As(err, &permanentError{}) &&
+		!errors.Is(err, event.ErrInvalidBody) &&
+		!errors.Is(err, event.ErrTypeMismatch)
+}
+
+// retry invokes the handler until it succeeds, fails with a non-retryable error,
+// runs out of attempts or the context is cancelled.
+// Returns the last error and the number of attempts made.
+func (p RetryPolicy) retry(ctx context.Context, handler event.ContextHandler, e event.Event) (attempts int, err error) {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	b := backoff.NewExponentialBackOff(
+		backoff.WithInitialInterval(p.InitialInterval),
+		backoff.WithMaxInterval(p.MaxInterval),
+		backoff.WithMultiplier(p.Multiplier),
+		backoff.WithRandomizationFactor(p.Jitter),
+		backoff.WithMaxElapsedTime(0),
+	)
+
+	var lastErr error
+	operation := func() error {
+		attempts++
+		lastErr = handler.HandleContext(ctx, e)
+		if lastErr != nil && !retryable(lastErr) {
+			return backoff.Permanent(lastErr)
+		}
+		return lastErr
+	}
+
+	if err := backoff.Retry(operation, backoff.WithContext(backoff.WithMaxRetries(b, uint64(max(p.MaxAttempts-1, 0))), ctx)); err != nil {
+		// Report the handler's error rather than the context's if retrying was interrupted.
+		return attempts, cmp.Or(lastErr, err)
+	}
+
+	return attempts, nil
+}
@@ -0,0 +1,48 @@
+package dispatcher
+
+import (
+	"sync/atomic"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+// settlement settles a dispatched delivery once all jobs of its event finished.
+type settlement struct {
+	ack     event.Acknowledger
+	jobs    atomic.Int64 // Number of jobs which did not finish yet.
+	requeue atomic.Bool  // Set when a job's handlers were not invoked or might not have finished.
+}
+
+func newSettlement(ack event.Acknowledger, jobs int) *settlement {
+	s := &settlement{ack: ack}
+	s.jobs.Store(int64(jobs))
+	return s
+}
+
+// finish marks a job as finished and reports whether it was the last one.
+func (s *settlement) finish(requeue bool) (last bool) {
+	if requeue {
+		s.requeue.Store(true)
+	}
+	return s.jobs.Add(-1) <= 0
+}
+
+// settle marks a job of the settlement as finished and acknowledges the delivery
+// once all of its jobs finished or requeues it if any of them requested so.
+// Jobs of events which were not dispatched from a delivery have a nil settlement.
+func (d *Dispatcher) settle(s *settlement, requeue bool) {
+	if s == nil || !s.finish(requeue) {
+		return
+	}
+
+	if s.requeue.Load() {
+		if err := s.ack.Nack(true); err != nil {
+			d.opts.logger.Log(d.ctx, "Failed to requeue event", "err", err)
+		}
+		return
+	}
+
+	if err := s.ack.Ack(); err != nil {
+		d.opts.logger.Log(d.ctx, "Failed to acknowledge event", "err", err)
+	}
+}
@@ -0,0 +1,107 @@
+package dispatcher
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+type subscription struct {
+	handler     event.ContextHandler // Handler as it was subscribed.
+	chain       event.ContextHandler // Handler wrapped with middlewares.
+	middlewares []event.Middleware
+	retry       RetryPolicy
+	sink        FailureSink   // Nil means the dispatcher's default sink.
+	matcher     event.Matcher // Nil unless subscribed using SubscribeMatching.
+	removed     atomic.Bool
+}
+
+// Subscription is a handle to handlers subscribed to an event type or using a matcher.
+type Subscription struct {
+	d             *Dispatcher
+	eType         event.EventType
+	matcher       event.Matcher
+	subscriptions []*subscription
+	once          sync.Once
+}
+
+// EventType returns the event type the handlers are subscribed to.
+// Returns an empty string if the handlers were subscribed using a matcher.
+func (s *Subscription) EventType() event.EventType {
+	return s.eType
+}
+
+// Matcher returns the matcher the handler was subscribed with using SubscribeMatching.
+func (s *Subscription) Matcher() event.Matcher {
+	return s.matcher
+}
+
+// Unsubscribe removes the subscribed handlers from the dispatcher.
+// They are not invoked for events dispatched afterwards, nor for already dispatched
+// events which did not start being handled yet. Handlers in flight are not interrupted.
+// Calling Unsubscribe more than once is safe.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.d.mu.Lock()
+		defer s.d.mu.Unlock()
+
+		for _, sub := range s.subscriptions {
+			sub.removed.Store(true)
+		}
+
+		isRemoved := func(sub *subscription) bool {
+			return sub.removed.Load()
+		}
+
+		if s.matcher != nil {
+			s.d.matchers = slices.DeleteFunc(slices.Clone(s.d.matchers), isRemoved)
+			return
+		}
+
+		remaining := slices.DeleteFunc(slices.Clone(s.d.subscriptions[s.eType]), isRemoved)
+
+		if len(remaining) == 0 {
+			delete(s.d.subscriptions, s.eType)
+			return
+		}
+		s.d.subscriptions[s.eType] = remaining
+	})
+}
+
+// Handlers returns handlers currently subscribed to each event type, in the order they were subscribed.
+// Handlers subscribed using SubscribeMatching are not included, see MatchingHandlers.
+func (d *Dispatcher) Handlers() map[event.EventType][]event.Handler {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	handlers := make(map[event.EventType][]event.Handler, len(d.subscriptions))
+	for eType, subscriptions := range d.subscriptions {
+		for _, s := range subscriptions {
+			handlers[eType] = append(handlers[eType], event.AsHandler(s.handler))
+		}
+	}
+
+	return handlers
+}
+
+// MatchingHandlers returns handlers currently subscribed using SubscribeMatching
+// together with their matchers, in the order they were subscribed.
+func (d *Dispatcher) MatchingHandlers() []MatchingHandler {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	handlers := make([]MatchingHandler, 0, len(d.matchers))
+	for _, s := range d.matchers {
+		handlers = append(handlers, MatchingHandler{Matcher: s.matcher, Handler: event.AsHandler(s.handler)})
+	}
+
+	return handlers
+}
+
+// MatchingHandler is a handler subscribed using SubscribeMatching.
+type MatchingHandler struct {
+	Matcher event.Matcher
+	Handler event.Handler
+}
@@ -2,31 +2,118 @@ package event
 
 import (
 	"encoding/json"
+	"reflect"
 	"time"
+
+	"github.com/gofrs/uuid"
 )
 
 // Events are sent to the queue in JSON format.
 type Event struct {
-	AggregateId AggregateId       `json:"aggregate_id,omitempty"`
-	Type        EventType         `json:"type,omitempty"`
-	Body        []byte            `json:"body,omitempty"` // Must be marshaled to JSON.
-	Timestamp   time.Time         `json:"timestamp,omitempty"`
-	Metadata    map[string]string // TraceID etc.
+	Id                  string            `json:"id,omitempty"` // Unique ID of the event, a UUID v7.
+	AggregateId         AggregateId       `json:"aggregate_id,omitempty"`
+	AggregateInstanceId string            `json:"aggregate_instance_id,omitempty"` // ID of the entity the event is about, eg. an article's ID.
+	CorrelationId       string            `json:"correlation_id,omitempty"`        // ID of the first event of the causal chain.
+	CausationId         string            `json:"causation_id,omitempty"`          // ID of the event which caused this one.
+	Type                EventType         `json:"type,omitempty"`
+	Body                []byte            `json:"body,omitempty"` // Must be marshaled to JSON.
+	Timestamp           time.Time         `json:"timestamp,omitempty"`
+	Version             int               `json:"version,omitempty"` // Schema version of the body.
+	Metadata            map[string]string // TraceID etc.
+}
+
+type Option interface {
+	apply(*Event)
+}
+
+type optionFunc func(e *Event)
+
+func (fn optionFunc) apply(e *Event) {
+	fn(e)
+}
+
+// WithAggregateInstanceId sets the ID of the entity the event is about.
+func WithAggregateInstanceId(id string) Option {
+	return optionFunc(func(e *Event) {
+		e.AggregateInstanceId = id
+	})
+}
+
+// WithCorrelationId overrides the correlation ID, which defaults to the event's own ID.
+func WithCorrelationId(id string) Option {
+	return optionFunc(func(e *Event) {
+		e.CorrelationId = id
+	})
+}
+
+// WithCausationId sets the ID of the event which caused the made event.
+func WithCausationId(id string) Option {
+	return optionFunc(func(e *Event) {
+		e.CausationId = id
+	})
+}
+
+// CausedBy marks the made event as a follow-up of the given event.
+// The made event joins the cause's correlation and its causation ID is set to the cause's ID.
+func CausedBy(cause Event) Option {
+	return optionFunc(func(e *Event) {
+		e.CausationId = cause.Id
+		e.CorrelationId = cause.CorrelationId
+		if e.CorrelationId == "" {
+			e.CorrelationId = cause.Id
+		}
+	})
 }
 
 // MakeEvent returns an event serialized for general use.
-// Returns an error when given body cannot be marshaled into json.
-func MakeEvent(aggregateId AggregateId, eType EventType, body interface{}, metadata map[string]string) (Event, error) {
+// The event is given a new UUID v7 as its ID and stamped with the latest schema version of its type.
+// Unless overridden by the options the event starts a new causal chain, ie. its correlation ID is its own ID.
+// Returns an error when given body cannot be marshaled into json or
+// when its type does not match the payload type registered for the event type.
+func MakeEvent(aggregateId AggregateId, eType EventType, body interface{}, metadata map[string]string, opts ...Option) (Event, error) {
+	if err := checkPayloadType(eType, reflect.TypeOf(body)); err != nil {
+		return Event{}, err
+	}
+
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return Event{}, err
 	}
 
-	return Event{
-		AggregateId: aggregateId,
-		Type:        eType,
-		Body:        jsonBody,
-		Metadata:    metadata,
-		Timestamp:   time.Now(),
-	}, nil
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Event{}, err
+	}
+
+	e := Event{
+		Id:            id.String(),
+		AggregateId:   aggregateId,
+		CorrelationId: id.String(),
+		Type:          eType,
+		Body:          jsonBody,
+		Metadata:      metadata,
+		Timestamp:     time.Now(),
+		Version:       LatestVersion(eType),
+	}
+
+	for _, opt := range opts {
+		opt.apply(&e)
+	}
+
+	return e, nil
+}
+
+// MakeFollowUpEvent returns an event made in reaction to the cause.
+// It is equivalent to calling MakeEvent with the CausedBy option.
+func MakeFollowUpEvent(cause Event, aggregateId AggregateId, eType EventType, body interface{}, metadata map[string]string, opts ...Option) (Event, error) {
+	return MakeEvent(aggregateId, eType, body, metadata, append([]Option{CausedBy(cause)}, opts...)...)
+}
+
+// AggregateKey returns the aggregate and the ID of the entity the event is about, eg. "article/1",
+// used to order events per aggregate instance. Events with no aggregate instance ID return an empty key.
+func AggregateKey(e Event) string {
+	if e.AggregateInstanceId == "" {
+		return ""
+	}
+	return string(e.AggregateId) + "/" + e.AggregateInstanceId
 }
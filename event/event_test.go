@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/krixlion/dev_forum-lib/internal/gentest"
@@ -18,6 +19,7 @@ func Test_MakeEvent(t *testing.T) {
 		eType       EventType
 		body        interface{}
 		metadata    map[string]string
+		opts        []Option
 	}
 	tests := []struct {
 		name string
@@ -44,6 +46,7 @@ func Test_MakeEvent(t *testing.T) {
 				}(),
 				Metadata:  map[string]string{"test": randString},
 				Timestamp: time.Now(),
+				Version:   1,
 			},
 		},
 		{
@@ -63,21 +66,149 @@ func Test_MakeEvent(t *testing.T) {
 					}
 					return data
 				}(),
-				Timestamp: time.Now(),
+				Version: 1,
+			},
+		},
+		{
+			name: "Test if options are applied",
+			args: args{
+				aggregateId: ArticleAggregate,
+				eType:       ArticleDeleted,
+				body:        randString,
+				opts:        []Option{WithAggregateInstanceId(randString), WithCausationId("cause")},
+			},
+			want: Event{
+				AggregateId:         ArticleAggregate,
+				AggregateInstanceId: randString,
+				CausationId:         "cause",
+				Type:                ArticleDeleted,
+				Body: func() []byte {
+					data, err := json.Marshal(randString)
+					if err != nil {
+						panic(err)
+					}
+					return data
+				}(),
+				Version: 1,
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := MakeEvent(tt.args.aggregateId, tt.args.eType, tt.args.body, tt.args.metadata)
+			before := time.Now()
+			got, err := MakeEvent(tt.args.aggregateId, tt.args.eType, tt.args.body, tt.args.metadata, tt.args.opts...)
 			if err != nil {
 				t.Errorf("MakeEvent(): error = %v", err)
 				return
 			}
 
-			if !cmp.Equal(got, tt.want, cmpopts.EquateApproxTime(time.Millisecond)) {
+			if got.Timestamp.Before(before) || got.Timestamp.After(time.Now()) {
+				t.Errorf("MakeEvent(): timestamp %v is not the time of the call", got.Timestamp)
+				return
+			}
+
+			id, err := uuid.FromString(got.Id)
+			if err != nil || id.Version() != uuid.V7 {
+				t.Errorf("MakeEvent(): ID is not a valid UUID v7, id = %q, err = %v", got.Id, err)
+				return
+			}
+
+			if got.CorrelationId != got.Id {
+				t.Errorf("MakeEvent(): event does not start a new correlation:\n correlation ID = %v\n ID = %v", got.CorrelationId, got.Id)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want, cmpopts.IgnoreFields(Event{}, "Id", "CorrelationId", "Timestamp")) {
 				t.Errorf("MakeEvent():\n got = %+v\n want = %+v\n diff = %+v\n", got, tt.want, cmp.Diff(got, tt.want))
 			}
 		})
 	}
 }
+
+func Test_MakeFollowUpEvent(t *testing.T) {
+	root, err := MakeEvent(ArticleAggregate, ArticleCreated, gentest.RandomArticle(1, 2), nil)
+	if err != nil {
+		t.Fatalf("MakeEvent(): error = %v", err)
+	}
+
+	child, err := MakeFollowUpEvent(root, ArticleAggregate, ArticleDeleted, gentest.RandomString(5), nil)
+	if err != nil {
+		t.Fatalf("MakeFollowUpEvent(): error = %v", err)
+	}
+
+	grandchild, err := MakeFollowUpEvent(child, UserAggregate, UserDeleted, gentest.RandomString(5), nil)
+	if err != nil {
+		t.Fatalf("MakeFollowUpEvent(): error = %v", err)
+	}
+
+	tests := []struct {
+		desc            string
+		got             Event
+		wantCausationId string
+	}{
+		{
+			desc:            "Test if follow-up of a root event joins its correlation",
+			got:             child,
+			wantCausationId: root.Id,
+		},
+		{
+			desc:            "Test if follow-up of a follow-up keeps the root correlation",
+			got:             grandchild,
+			wantCausationId: child.Id,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if tt.got.Id == root.Id || tt.got.Id == "" {
+				t.Errorf("MakeFollowUpEvent(): event was not given a new ID, id = %q", tt.got.Id)
+				return
+			}
+
+			if tt.got.CorrelationId != root.Id {
+				t.Errorf("MakeFollowUpEvent():\n correlation ID = %v\n want = %v", tt.got.CorrelationId, root.Id)
+				return
+			}
+
+			if tt.got.CausationId != tt.wantCausationId {
+				t.Errorf("MakeFollowUpEvent():\n causation ID = %v\n want = %v", tt.got.CausationId, tt.wantCausationId)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Run("Test if middlewares are applied in order with the first one being the outermost", func(t *testing.T) {
+		calls := []string{}
+		record := func(name string) Middleware {
+			return func(next Handler) Handler {
+				return HandlerFunc(func(e Event) {
+					calls = append(calls, name)
+					next.Handle(e)
+				})
+			}
+		}
+
+		h := Chain(record("first"), record("second"))(HandlerFunc(func(e Event) {
+			calls = append(calls, "handler")
+		}))
+		h.Handle(Event{})
+
+		want := []string{"first", "second", "handler"}
+		if !cmp.Equal(calls, want) {
+			t.Errorf("Chain():\n got = %v\n want = %v", calls, want)
+		}
+	})
+}
+
+func TestAsHandler(t *testing.T) {
+	t.Run("Test if handlers converted using AsContextHandler are unwrapped", func(t *testing.T) {
+		h := &testHandler{}
+		if got := AsHandler(AsContextHandler(h)); got != Handler(h) {
+			t.Errorf("AsHandler():\n got = %v\n want = %v", got, h)
+		}
+	})
+}
+
+type testHandler struct{}
+
+func (*testHandler) Handle(Event) {}
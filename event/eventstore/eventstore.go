@@ -0,0 +1,152 @@
+// Package eventstore persists events of aggregates, allowing to rebuild their state
+// and read models from scratch by replaying the stored events.
+//
+// Events of every aggregate instance form a stream. Appending to a stream is guarded
+// by optimistic concurrency: the writer states the version of the stream it based its
+// decision on and the append fails with ErrConcurrency if other events were appended meanwhile.
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+var (
+	ErrConcurrency    = errors.New("stream was modified concurrently")
+	ErrStreamMismatch = errors.New("event does not belong to the stream")
+)
+
+// Expected versions with a special meaning.
+const (
+	NoStream   = 0  // The stream must not exist yet.
+	AnyVersion = -1 // The stream may be at any version.
+)
+
+// StreamId identifies the stream of events of a single aggregate instance.
+type StreamId struct {
+	AggregateId event.AggregateId `json:"aggregate_id"`
+	InstanceId  string            `json:"instance_id"`
+}
+
+// StreamOf returns the ID of the stream the event belongs to.
+func StreamOf(e event.Event) StreamId {
+	return StreamId{AggregateId: e.AggregateId, InstanceId: e.AggregateInstanceId}
+}
+
+func (id StreamId) String() string {
+	return string(id.AggregateId) + "/" + id.InstanceId
+}
+
+// Record is a stored event.
+type Record struct {
+	Event         event.Event `json:"event"`
+	StreamVersion int         `json:"stream_version"` // Position of the event in its stream, starting from 1.
+	Position      int64       `json:"position"`       // Position of the event in the store, starting from 1.
+}
+
+// EventStore persists streams of events.
+type EventStore interface {
+	// Append appends the events to the stream if the stream is at the expected version,
+	// and returns the stream's new version. Returns ErrConcurrency otherwise.
+	// Events with no aggregate instance set are assigned to the stream unless their aggregate
+	// differs from the stream's one. Events of other streams are rejected with ErrStreamMismatch.
+	Append(ctx context.Context, stream StreamId, expectedVersion int, events ...event.Event) (version int, err error)
+
+	// ReadStream returns events of the stream following the given version, in the order they were appended.
+	ReadStream(ctx context.Context, stream StreamId, afterVersion int) ([]Record, error)
+
+	// ReadAll returns up to limit events of all streams following the given position,
+	// in the order they were appended. A non-positive limit returns no events.
+	ReadAll(ctx context.Context, afterPosition int64, limit int) ([]Record, error)
+}
+
+// index keeps records in memory. It is not safe for concurrent use.
+type index struct {
+	records []Record
+	streams map[StreamId][]int // Indices of records keyed by their stream.
+}
+
+func newIndex() index {
+	return index{
+		streams: make(map[StreamId][]int),
+	}
+}
+
+// prepare returns records of the events appended to the stream
+// or an error if they cannot be appended.
+func (idx *index) prepare(stream StreamId, expectedVersion int, events []event.Event) ([]Record, error) {
+	version := len(idx.streams[stream])
+	if expectedVersion != AnyVersion && expectedVersion != version {
+		return nil, fmt.Errorf("%w: stream %s is at version %d, expected %d", ErrConcurrency, stream, version, expectedVersion)
+	}
+
+	records := make([]Record, 0, len(events))
+	for i, e := range events {
+		// Events made using event.MakeEvent have the aggregate set but no instance.
+		if e.AggregateInstanceId == "" && (e.AggregateId == "" || e.AggregateId == stream.AggregateId) {
+			e.AggregateId, e.AggregateInstanceId = stream.AggregateId, stream.InstanceId
+		}
+
+		if StreamOf(e) != stream {
+			return nil, fmt.Errorf("%w: event of stream %s appended to %s", ErrStreamMismatch, StreamOf(e), stream)
+		}
+
+		records = append(records, Record{
+			Event:         e,
+			StreamVersion: version + i + 1,
+			Position:      int64(len(idx.records) + i + 1),
+		})
+	}
+
+	return records, nil
+}
+
+func (idx *index) add(records ...Record) {
+	for _, r := range records {
+		stream := StreamOf(r.Event)
+		idx.streams[stream] = append(idx.streams[stream], len(idx.records))
+		idx.records = append(idx.records, r)
+	}
+}
+
+func (idx *index) version(stream StreamId) int {
+	return len(idx.streams[stream])
+}
+
+func (idx *index) readStream(stream StreamId, afterVersion int) []Record {
+	indices := idx.streams[stream]
+	afterVersion = max(0, min(afterVersion, len(indices)))
+
+	records := make([]Record, 0, len(indices)-afterVersion)
+	for _, i := range indices[afterVersion:] {
+		records = append(records, idx.records[i])
+	}
+
+	return records
+}
+
+func (idx *index) readAll(afterPosition int64, limit int) []Record {
+	from := int(max(0, min(afterPosition, int64(len(idx.records)))))
+	to := from + max(0, min(limit, len(idx.records)-from))
+
+	return slices.Clone(idx.records[from:to])
+}
+
+// cloneEvent returns a deep copy of the event so that readers do not share its body and metadata.
+func cloneEvent(e event.Event) event.Event {
+	e.Body = slices.Clone(e.Body)
+	e.Metadata = maps.Clone(e.Metadata)
+	return e
+}
+
+func cloneRecords(records []Record) []Record {
+	for i := range records {
+		records[i].Event = cloneEvent(records[i].Event)
+	}
+	return records
+}
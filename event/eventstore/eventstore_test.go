@@ -0,0 +1,217 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/fs"
+	"github.com/spf13/afero"
+)
+
+var (
+	article = StreamId{AggregateId: event.ArticleAggregate, InstanceId: "1"}
+	user    = StreamId{AggregateId: event.UserAggregate, InstanceId: "1"}
+)
+
+func setUpFileStore(t *testing.T, path string) *FileStore {
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func stores(t *testing.T) map[string]EventStore {
+	fs.SetGlobalFileSystem(afero.NewMemMapFs())
+
+	return map[string]EventStore{
+		"MemoryStore": NewMemoryStore(),
+		"FileStore":   setUpFileStore(t, "events.log"),
+	}
+}
+
+func types(records []Record) []event.EventType {
+	types := []event.EventType{}
+	for _, r := range records {
+		types = append(types, r.Event.Type)
+	}
+	return types
+}
+
+func TestEventStore_Append(t *testing.T) {
+	tests := []struct {
+		desc            string
+		stream          StreamId
+		expectedVersion int
+		events          []event.Event
+		wantVersion     int
+		wantErr         error
+	}{
+		{
+			desc:            "Test if events are appended at the expected version",
+			stream:          article,
+			expectedVersion: 1,
+			events:          []event.Event{{Type: event.ArticleUpdated}, {Type: event.ArticleDeleted}},
+			wantVersion:     3,
+		},
+		{
+			desc:            "Test if any version allows to append",
+			stream:          article,
+			expectedVersion: AnyVersion,
+			events:          []event.Event{{Type: event.ArticleUpdated}},
+			wantVersion:     2,
+		},
+		{
+			desc:            "Test if new stream is created",
+			stream:          user,
+			expectedVersion: NoStream,
+			events:          []event.Event{{Type: event.UserCreated}},
+			wantVersion:     1,
+		},
+		{
+			desc:            "Test if returns ErrConcurrency on version mismatch",
+			stream:          article,
+			expectedVersion: NoStream,
+			events:          []event.Event{{Type: event.ArticleCreated}},
+			wantErr:         ErrConcurrency,
+		},
+		{
+			desc:            "Test if events with no aggregate instance are assigned to the stream",
+			stream:          article,
+			expectedVersion: 1,
+			events:          []event.Event{{Type: event.ArticleUpdated, AggregateId: event.ArticleAggregate}},
+			wantVersion:     2,
+		},
+		{
+			desc:            "Test if returns ErrStreamMismatch on event of another aggregate",
+			stream:          article,
+			expectedVersion: 1,
+			events:          []event.Event{{Type: event.UserCreated, AggregateId: event.UserAggregate}},
+			wantErr:         ErrStreamMismatch,
+		},
+		{
+			desc:            "Test if returns ErrStreamMismatch on event of another stream",
+			stream:          article,
+			expectedVersion: 1,
+			events:          []event.Event{{Type: event.UserCreated, AggregateId: event.UserAggregate, AggregateInstanceId: "1"}},
+			wantErr:         ErrStreamMismatch,
+		},
+	}
+	for _, tt := range tests {
+		for name, store := range stores(t) {
+			t.Run(name+": "+tt.desc, func(t *testing.T) {
+				ctx := context.Background()
+
+				if _, err := store.Append(ctx, article, NoStream, event.Event{Type: event.ArticleCreated}); err != nil {
+					t.Errorf("EventStore.Append() error = %v", err)
+					return
+				}
+
+				version, err := store.Append(ctx, tt.stream, tt.expectedVersion, tt.events...)
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("EventStore.Append():\n error = %v\n wantErr = %v", err, tt.wantErr)
+					return
+				}
+
+				if version != tt.wantVersion {
+					t.Errorf("EventStore.Append():\n version = %v\n want = %v", version, tt.wantVersion)
+				}
+			})
+		}
+	}
+}
+
+func TestEventStore_Read(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name+": Test if streams and all events are read in order", func(t *testing.T) {
+			ctx := context.Background()
+
+			appends := []struct {
+				stream StreamId
+				eType  event.EventType
+			}{
+				{article, event.ArticleCreated},
+				{user, event.UserCreated},
+				{article, event.ArticleUpdated},
+				{article, event.ArticleDeleted},
+			}
+			for _, a := range appends {
+				if _, err := store.Append(ctx, a.stream, AnyVersion, event.Event{Type: a.eType}); err != nil {
+					t.Errorf("EventStore.Append() error = %v", err)
+					return
+				}
+			}
+
+			stream, err := store.ReadStream(ctx, article, 1)
+			if err != nil {
+				t.Errorf("EventStore.ReadStream() error = %v", err)
+				return
+			}
+
+			if got, want := types(stream), []event.EventType{event.ArticleUpdated, event.ArticleDeleted}; !cmp.Equal(got, want) {
+				t.Errorf("EventStore.ReadStream():\n got = %v\n want = %v", got, want)
+				return
+			}
+
+			if stream[0].StreamVersion != 2 || stream[0].Position != 3 || StreamOf(stream[0].Event) != article {
+				t.Errorf("EventStore.ReadStream(): invalid record = %+v", stream[0])
+				return
+			}
+
+			all, err := store.ReadAll(ctx, 1, 2)
+			if err != nil {
+				t.Errorf("EventStore.ReadAll() error = %v", err)
+				return
+			}
+
+			if got, want := types(all), []event.EventType{event.UserCreated, event.ArticleUpdated}; !cmp.Equal(got, want) {
+				t.Errorf("EventStore.ReadAll():\n got = %v\n want = %v", got, want)
+				return
+			}
+
+			none, err := store.ReadAll(ctx, 1, -1)
+			if err != nil {
+				t.Errorf("EventStore.ReadAll() error = %v", err)
+				return
+			}
+
+			if len(none) != 0 {
+				t.Errorf("EventStore.ReadAll(): non-positive limit returned %v", types(none))
+			}
+		})
+	}
+}
+
+func TestFileStore_reopen(t *testing.T) {
+	t.Run("Test if appended events are loaded after reopening the store", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+
+		store := setUpFileStore(t, "events.log")
+		if _, err := store.Append(ctx, article, NoStream, event.Event{Type: event.ArticleCreated}, event.Event{Type: event.ArticleUpdated}); err != nil {
+			t.Errorf("FileStore.Append() error = %v", err)
+			return
+		}
+		store.Close()
+
+		reopened := setUpFileStore(t, "events.log")
+		if _, err := reopened.Append(ctx, article, 1, event.Event{Type: event.ArticleDeleted}); !errors.Is(err, ErrConcurrency) {
+			t.Errorf("FileStore.Append():\n error = %v\n wantErr = %v", err, ErrConcurrency)
+			return
+		}
+
+		got, err := reopened.ReadStream(ctx, article, 0)
+		if err != nil {
+			t.Errorf("FileStore.ReadStream() error = %v", err)
+			return
+		}
+
+		if want := []event.EventType{event.ArticleCreated, event.ArticleUpdated}; !cmp.Equal(types(got), want) {
+			t.Errorf("FileStore.ReadStream():\n got = %v\n want = %v", types(got), want)
+		}
+	})
+}
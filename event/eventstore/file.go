@@ -0,0 +1,95 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/internal/jsonlog"
+)
+
+var _ EventStore = (*FileStore)(nil)
+
+// FileStore is an EventStore keeping records in an append-only log file.
+// Every append is written to the file in a single write and synced to disk.
+// An append which fails to be written or synced is removed from the file, so that the file matches the index.
+// All records are indexed in memory, which makes the store suitable for a single process only.
+type FileStore struct {
+	mu    sync.RWMutex
+	log   *jsonlog.Log[Record]
+	index index
+}
+
+// NewFileStore opens the log under the given path, creating it if it does not exist,
+// and loads all records from it. The store should be closed in order to release the file.
+func NewFileStore(path string) (*FileStore, error) {
+	idx := newIndex()
+	log, err := jsonlog.Open(path, func(r Record) error {
+		if r.Position != int64(len(idx.records)+1) {
+			return fmt.Errorf("event log is corrupted: expected position %d, got %d", len(idx.records)+1, r.Position)
+		}
+
+		idx.add(r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{
+		log:   log,
+		index: idx,
+	}, nil
+}
+
+// Append writes the events to the log if the stream is at the expected version.
+func (s *FileStore) Append(ctx context.Context, stream StreamId, expectedVersion int, events ...event.Event) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.index.prepare(stream, expectedVersion, events)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.log.Append(records...); err != nil {
+		return 0, err
+	}
+
+	s.index.add(cloneRecords(records)...)
+	return s.index.version(stream), nil
+}
+
+func (s *FileStore) ReadStream(ctx context.Context, stream StreamId, afterVersion int) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return cloneRecords(s.index.readStream(stream, afterVersion)), nil
+}
+
+func (s *FileStore) ReadAll(ctx context.Context, afterPosition int64, limit int) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return cloneRecords(s.index.readAll(afterPosition, limit)), nil
+}
+
+// Close closes the underlying log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
@@ -0,0 +1,61 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+var _ EventStore = (*MemoryStore)(nil)
+
+// MemoryStore is an EventStore keeping events in memory, meant for tests and local development.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	index index
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		index: newIndex(),
+	}
+}
+
+func (s *MemoryStore) Append(ctx context.Context, stream StreamId, expectedVersion int, events ...event.Event) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.index.prepare(stream, expectedVersion, events)
+	if err != nil {
+		return 0, err
+	}
+
+	s.index.add(cloneRecords(records)...)
+	return s.index.version(stream), nil
+}
+
+func (s *MemoryStore) ReadStream(ctx context.Context, stream StreamId, afterVersion int) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return cloneRecords(s.index.readStream(stream, afterVersion)), nil
+}
+
+func (s *MemoryStore) ReadAll(ctx context.Context, afterPosition int64, limit int) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return cloneRecords(s.index.readAll(afterPosition, limit)), nil
+}
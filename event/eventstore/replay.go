@@ -0,0 +1,112 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/event/dispatcher"
+)
+
+var ErrDroppingDispatcher = errors.New("replay requires a dispatcher using the Block backpressure policy")
+
+type ReplayOption interface {
+	apply(*replayOptions)
+}
+
+type replayOptionFunc func(opts *replayOptions)
+
+func (fn replayOptionFunc) apply(opts *replayOptions) {
+	fn(opts)
+}
+
+type replayOptions struct {
+	after     int64
+	batchSize int
+	matcher   event.Matcher // Nil means all events are replayed.
+}
+
+func defaultReplayOptions() replayOptions {
+	return replayOptions{
+		batchSize: 100,
+	}
+}
+
+// After makes Replay start with the event following the given position,
+// eg. the position returned by a previous replay. Defaults to 0, ie. the first event.
+func After(position int64) ReplayOption {
+	return replayOptionFunc(func(opts *replayOptions) {
+		opts.after = position
+	})
+}
+
+// WithBatchSize sets the max number of events read from the store at once. Defaults to 100.
+// Non-positive sizes are ignored.
+func WithBatchSize(size int) ReplayOption {
+	return replayOptionFunc(func(opts *replayOptions) {
+		if size > 0 {
+			opts.batchSize = size
+		}
+	})
+}
+
+// Matching makes Replay dispatch only events matched by the matcher, eg. an event.Pattern.
+func Matching(m event.Matcher) ReplayOption {
+	return replayOptionFunc(func(opts *replayOptions) {
+		opts.matcher = m
+	})
+}
+
+// Replay dispatches stored events in the order they were appended and waits for
+// the dispatcher's handlers to finish. It returns the position of the last replayed
+// event, which can be used to resume the replay later on using After.
+// If the dispatcher is shut down during the replay, the position of the last event
+// handed off to it is returned together with dispatcher.ErrDispatcherClosed.
+//
+// Handlers are invoked concurrently according to the dispatcher's configuration.
+// The dispatcher has to use the dispatcher.Block backpressure policy, since events dropped
+// by other policies would be missing from the rebuilt state. ErrDroppingDispatcher is returned otherwise.
+// Use dispatcher.WithOrdering with dispatcher.AggregateKey in order to handle events
+// of every aggregate instance in order, eg. when rebuilding read models from scratch.
+//
+//	d := dispatcher.NewDispatcher(10, dispatcher.WithOrdering(dispatcher.AggregateKey))
+//	d.Register(projection)
+//
+//	position, err := eventstore.Replay(ctx, store, d)
+func Replay(ctx context.Context, store EventStore, d *dispatcher.Dispatcher, opts ...ReplayOption) (position int64, err error) {
+	if d.Backpressure() != dispatcher.Block {
+		return 0, ErrDroppingDispatcher
+	}
+
+	o := defaultReplayOptions()
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+
+	position = o.after
+	defer d.Wait()
+
+	for {
+		records, err := store.ReadAll(ctx, position, o.batchSize)
+		if err != nil {
+			return position, err
+		}
+
+		for _, r := range records {
+			if err := ctx.Err(); err != nil {
+				return position, err
+			}
+
+			if o.matcher == nil || o.matcher.Match(r.Event) {
+				if err := d.DispatchContext(ctx, r.Event); err != nil {
+					return position, err
+				}
+			}
+			position = r.Position
+		}
+
+		if len(records) == 0 || len(records) < o.batchSize {
+			return position, nil
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/event/dispatcher"
+)
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		desc         string
+		opts         []ReplayOption
+		want         []event.EventType
+		wantPosition int64
+	}{
+		{
+			desc:         "Test if all events are replayed in order",
+			opts:         []ReplayOption{WithBatchSize(2)},
+			want:         []event.EventType{event.ArticleCreated, event.ArticleUpdated, event.ArticleDeleted},
+			wantPosition: 3,
+		},
+		{
+			desc:         "Test if replay resumes after the given position",
+			opts:         []ReplayOption{After(1)},
+			want:         []event.EventType{event.ArticleUpdated, event.ArticleDeleted},
+			wantPosition: 3,
+		},
+		{
+			desc:         "Test if only matching events are replayed",
+			opts:         []ReplayOption{Matching(event.Pattern("*-deleted"))},
+			want:         []event.EventType{event.ArticleDeleted},
+			wantPosition: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx := context.Background()
+
+			store := NewMemoryStore()
+			if _, err := store.Append(ctx, article, NoStream,
+				event.Event{Type: event.ArticleCreated},
+				event.Event{Type: event.ArticleUpdated},
+				event.Event{Type: event.ArticleDeleted},
+			); err != nil {
+				t.Errorf("MemoryStore.Append() error = %v", err)
+				return
+			}
+
+			var mu sync.Mutex
+			got := []event.EventType{}
+
+			d := dispatcher.NewDispatcher(1, dispatcher.WithOrdering(dispatcher.AggregateKey))
+			d.SubscribeMatching(event.AnyEvent, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, e.Type)
+				return nil
+			}))
+
+			position, err := Replay(ctx, store, d, tt.opts...)
+			if err != nil {
+				t.Errorf("Replay() error = %v", err)
+				return
+			}
+
+			if position != tt.wantPosition {
+				t.Errorf("Replay():\n position = %v\n want = %v", position, tt.wantPosition)
+				return
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("Replay():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplay_shutdown(t *testing.T) {
+	t.Run("Test if replay stops at the last event handed off to the dispatcher", func(t *testing.T) {
+		ctx := context.Background()
+
+		store := NewMemoryStore()
+		if _, err := store.Append(ctx, article, NoStream,
+			event.Event{Type: event.ArticleCreated},
+			event.Event{Type: event.ArticleUpdated},
+		); err != nil {
+			t.Errorf("MemoryStore.Append() error = %v", err)
+			return
+		}
+
+		d := dispatcher.NewDispatcher(1)
+		d.SubscribeMatching(event.AnyEvent, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			return nil
+		}))
+
+		if _, err := d.Shutdown(ctx); err != nil {
+			t.Errorf("Dispatcher.Shutdown() error = %v", err)
+			return
+		}
+
+		position, err := Replay(ctx, store, d, After(1))
+		if !errors.Is(err, dispatcher.ErrDispatcherClosed) {
+			t.Errorf("Replay():\n got = %v\n want = %v", err, dispatcher.ErrDispatcherClosed)
+			return
+		}
+
+		if position != 1 {
+			t.Errorf("Replay():\n position = %v\n want = %v", position, 1)
+		}
+	})
+}
+
+func TestReplay_backpressure(t *testing.T) {
+	t.Run("Test if dispatchers which drop events are rejected", func(t *testing.T) {
+		d := dispatcher.NewDispatcher(1, dispatcher.WithBackpressure(dispatcher.DropOldest))
+
+		if _, err := Replay(context.Background(), NewMemoryStore(), d); !errors.Is(err, ErrDroppingDispatcher) {
+			t.Errorf("Replay():\n got = %v\n want = %v", err, ErrDroppingDispatcher)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/internal/jsonlog"
+)
+
+var _ Store = (*FileStore)(nil)
+
+// FileStore is a Store keeping IDs in an append-only log file, so that they survive restarts.
+// Every added ID is appended to the file and synced to disk. IDs are indexed in memory,
+// which makes the store suitable for a single process only.
+// IDs older than the TTL are forgotten. A TTL of 0 means IDs never expire.
+type FileStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	log     *jsonlog.Log[fileEntry]
+	entries map[string]time.Time // Times the IDs were added at.
+	now     func() time.Time
+}
+
+// fileEntry is a single line of the log.
+type fileEntry struct {
+	Id      string    `json:"id"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// NewFileStore opens the log under the given path, creating it if it does not exist,
+// and loads all IDs from it. The store should be closed in order to release the file.
+func NewFileStore(path string, ttl time.Duration) (*FileStore, error) {
+	entries := make(map[string]time.Time)
+	log, err := jsonlog.Open(path, func(entry fileEntry) error {
+		entries[entry.Id] = entry.AddedAt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{
+		ttl:     ttl,
+		log:     log,
+		entries: entries,
+		now:     time.Now,
+	}, nil
+}
+
+func (s *FileStore) Contains(ctx context.Context, id string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	addedAt, ok := s.entries[id]
+	return ok && !s.expired(addedAt), nil
+}
+
+func (s *FileStore) Add(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := fileEntry{Id: id, AddedAt: s.now()}
+	if err := s.log.Append(entry); err != nil {
+		return err
+	}
+
+	s.entries[id] = entry.AddedAt
+	return nil
+}
+
+// Compact rewrites the log so that it contains only IDs which did not expire.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]fileEntry, 0, len(s.entries))
+	for id, addedAt := range s.entries {
+		if s.expired(addedAt) {
+			delete(s.entries, id)
+			continue
+		}
+		entries = append(entries, fileEntry{Id: id, AddedAt: addedAt})
+	}
+
+	return s.log.Rewrite(entries...)
+}
+
+// Close closes the underlying log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
+
+// expired must be called with the mutex held.
+func (s *FileStore) expired(addedAt time.Time) bool {
+	return s.ttl > 0 && s.now().Sub(addedAt) > s.ttl
+}
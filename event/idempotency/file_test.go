@@ -0,0 +1,82 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/fs"
+	"github.com/spf13/afero"
+)
+
+func setUpFileStore(t *testing.T, path string, ttl time.Duration) *FileStore {
+	store, err := NewFileStore(path, ttl)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestFileStore(t *testing.T) {
+	t.Run("Test if IDs survive reopening the store", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+
+		store := setUpFileStore(t, "dedup.log", 0)
+		if err := store.Add(ctx, "1"); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+		store.Close()
+
+		got, err := setUpFileStore(t, "dedup.log", 0).Contains(ctx, "1")
+		if err != nil {
+			t.Errorf("FileStore.Contains() error = %v", err)
+			return
+		}
+
+		if !got {
+			t.Errorf("FileStore.Contains(): ID was lost after reopening the store")
+		}
+	})
+
+	t.Run("Test if expired IDs are removed on compaction", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+		now := time.Now()
+
+		store := setUpFileStore(t, "dedup.log", time.Minute)
+		store.now = func() time.Time { return now.Add(-time.Hour) }
+		if err := store.Add(ctx, "expired"); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+
+		store.now = func() time.Time { return now }
+		if err := store.Add(ctx, "valid"); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+
+		if err := store.Compact(); err != nil {
+			t.Errorf("FileStore.Compact() error = %v", err)
+			return
+		}
+		store.Close()
+
+		reopened := setUpFileStore(t, "dedup.log", 0)
+		want := map[string]bool{"expired": false, "valid": true}
+		for id, want := range want {
+			got, err := reopened.Contains(ctx, id)
+			if err != nil {
+				t.Errorf("FileStore.Contains() error = %v", err)
+				return
+			}
+
+			if got != want {
+				t.Errorf("FileStore.Contains(%q):\n got = %v\n want = %v", id, got, want)
+			}
+		}
+	})
+}
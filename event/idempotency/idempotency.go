@@ -0,0 +1,153 @@
+// Package idempotency implements the idempotent consumer pattern.
+// A Handler records IDs of processed events in a Store and skips
+// events which were already processed, so that redelivered events
+// do not cause their effects to be applied more than once.
+package idempotency
+
+import (
+	"context"
+	"errors"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/internal/keylock"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+var (
+	_ event.Handler        = (*Handler)(nil)
+	_ event.ContextHandler = (*Handler)(nil)
+)
+
+// Store records IDs of processed events.
+type Store interface {
+	// Contains reports whether the event with given ID was processed.
+	Contains(ctx context.Context, id string) (bool, error)
+
+	// Add records the event with given ID as processed.
+	Add(ctx context.Context, id string) error
+}
+
+// Handler is an event.Handler decorator skipping events which were already processed.
+// Events are identified by their IDs. Events with no ID are always handled.
+// Only events which were handled successfully are recorded as processed,
+// so that failed events are handled again when redelivered.
+//
+//	d.Subscribe(event.ArticleCreated, idempotency.NewHandler(store, handler))
+type Handler struct {
+	store   Store
+	handler event.ContextHandler
+	opts    options
+
+	hits   metric.Int64Counter
+	misses metric.Int64Counter
+
+	inFlight keylock.Locks // Held by events being handled, keyed by their IDs.
+}
+
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(opts *options)
+
+func (fn optionFunc) apply(opts *options) {
+	fn(opts)
+}
+
+type options struct {
+	logger logging.Logger
+	meter  metric.Meter
+}
+
+func defaultOptions() options {
+	return options{
+		logger: nulls.NullLogger{},
+		meter:  noop.NewMeterProvider().Meter(""),
+	}
+}
+
+func WithLogger(logger logging.Logger) Option {
+	return optionFunc(func(opts *options) {
+		opts.logger = logger
+	})
+}
+
+// WithMeter sets the meter used to record the number of duplicates (hits)
+// and first deliveries (misses) of events.
+func WithMeter(meter metric.Meter) Option {
+	return optionFunc(func(opts *options) {
+		opts.meter = meter
+	})
+}
+
+// NewHandler returns a handler invoking the given handler only for events
+// whose IDs are not contained in the store.
+// Errors of handlers implementing event.ContextHandler are propagated.
+func NewHandler(store Store, handler event.Handler, opts ...Option) *Handler {
+	h := &Handler{
+		store:   store,
+		handler: event.AsContextHandler(handler),
+		opts:    defaultOptions(),
+	}
+
+	for _, opt := range opts {
+		opt.apply(&h.opts)
+	}
+
+	var hitsErr, missesErr error
+	h.hits, hitsErr = h.opts.meter.Int64Counter("event.dedup.hits", metric.WithDescription("Number of skipped duplicate events."))
+	h.misses, missesErr = h.opts.meter.Int64Counter("event.dedup.misses", metric.WithDescription("Number of events processed for the first time."))
+
+	if err := errors.Join(hitsErr, missesErr); err != nil {
+		h.opts.logger.Log(context.Background(), "Failed to create deduplication metrics", "err", err)
+	}
+
+	return h
+}
+
+// Handle invokes the decorated handler unless the event was already processed.
+// Errors returned by the decorated handler are discarded, use HandleContext to receive them.
+func (h *Handler) Handle(e event.Event) {
+	h.HandleContext(context.Background(), e) //nolint:errcheck // Handler can't report errors.
+}
+
+// HandleContext invokes the decorated handler unless the event was already processed
+// and returns its error, in which case the event is not recorded as processed.
+// Duplicates received while the event is being handled wait for it to finish.
+// When the store fails the event is handled anyway, since processing an event
+// twice is preferred over losing it.
+func (h *Handler) HandleContext(ctx context.Context, e event.Event) error {
+	if e.Id == "" {
+		return h.handler.HandleContext(ctx, e)
+	}
+
+	release := h.inFlight.Acquire(e.Id)
+	defer release()
+
+	attrs := metric.WithAttributes(attribute.String("event.type", string(e.Type)))
+
+	processed, err := h.store.Contains(ctx, e.Id)
+	if err != nil {
+		h.opts.logger.Log(ctx, "Failed to check if event was processed", "err", err, "id", e.Id)
+	}
+
+	if processed {
+		h.hits.Add(ctx, 1, attrs)
+		return nil
+	}
+
+	h.misses.Add(ctx, 1, attrs)
+	if err := h.handler.HandleContext(ctx, e); err != nil {
+		return err
+	}
+
+	if err := h.store.Add(ctx, e.Id); err != nil {
+		h.opts.logger.Log(ctx, "Failed to record processed event", "err", err, "id", e.Id)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/stretchr/testify/mock"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// counterValues returns the sums of all int64 counters keyed by their names.
+func counterValues(t *testing.T, reader sdkmetric.Reader) map[string]int64 {
+	rm := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Reader.Collect() error = %v", err)
+	}
+
+	values := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				values[m.Name] += dp.Value
+			}
+		}
+	}
+	return values
+}
+
+func TestHandler_Handle(t *testing.T) {
+	tests := []struct {
+		desc       string
+		events     []event.Event
+		wantCalls  int
+		wantHits   int64
+		wantMisses int64
+	}{
+		{
+			desc: "Test if distinct events are handled",
+			events: []event.Event{
+				{Id: "1", Type: event.ArticleCreated},
+				{Id: "2", Type: event.ArticleCreated},
+			},
+			wantCalls:  2,
+			wantMisses: 2,
+		},
+		{
+			desc: "Test if duplicates are skipped",
+			events: []event.Event{
+				{Id: "1", Type: event.ArticleCreated},
+				{Id: "1", Type: event.ArticleCreated},
+				{Id: "2", Type: event.ArticleCreated},
+				{Id: "1", Type: event.ArticleCreated},
+			},
+			wantCalls:  2,
+			wantHits:   2,
+			wantMisses: 2,
+		},
+		{
+			desc: "Test if events with no ID are always handled",
+			events: []event.Event{
+				{Type: event.ArticleCreated},
+				{Type: event.ArticleCreated},
+			},
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			handler := mocks.NewHandler()
+			handler.On("Handle", mock.AnythingOfType("event.Event")).Return()
+
+			reader := sdkmetric.NewManualReader()
+			meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+			store, err := NewMemoryStore(10, 0)
+			if err != nil {
+				t.Errorf("NewMemoryStore() error = %v", err)
+				return
+			}
+
+			h := NewHandler(store, handler, WithMeter(meter))
+			for _, e := range tt.events {
+				h.Handle(e)
+			}
+
+			handler.AssertNumberOfCalls(t, "Handle", tt.wantCalls)
+
+			values := counterValues(t, reader)
+			if values["event.dedup.hits"] != tt.wantHits || values["event.dedup.misses"] != tt.wantMisses {
+				t.Errorf("Handler.Handle() metrics:\n got = %+v\n want hits = %v\n want misses = %v", values, tt.wantHits, tt.wantMisses)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleConcurrently(t *testing.T) {
+	t.Run("Test if concurrent duplicates are handled once", func(t *testing.T) {
+		handler := mocks.NewHandler()
+		handler.On("Handle", mock.AnythingOfType("event.Event")).Return()
+
+		store, err := NewMemoryStore(10, 0)
+		if err != nil {
+			t.Errorf("NewMemoryStore() error = %v", err)
+			return
+		}
+
+		h := NewHandler(store, handler)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.Handle(event.Event{Id: "1", Type: event.ArticleCreated})
+			}()
+		}
+		wg.Wait()
+
+		handler.AssertNumberOfCalls(t, "Handle", 1)
+	})
+}
+
+func TestHandler_HandleContext(t *testing.T) {
+	t.Run("Test if failed events are not recorded as processed", func(t *testing.T) {
+		calls := 0
+		handler := event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			calls++
+			if calls == 1 {
+				return errors.New("test err")
+			}
+			return nil
+		})
+
+		store, err := NewMemoryStore(10, 0)
+		if err != nil {
+			t.Errorf("NewMemoryStore() error = %v", err)
+			return
+		}
+
+		h := NewHandler(store, event.AsHandler(handler))
+		e := event.Event{Id: "1", Type: event.ArticleCreated}
+
+		if err := h.HandleContext(context.Background(), e); err == nil {
+			t.Errorf("Handler.HandleContext() error = nil, want the handler's error")
+			return
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := h.HandleContext(context.Background(), e); err != nil {
+				t.Errorf("Handler.HandleContext() error = %v", err)
+				return
+			}
+		}
+
+		if calls != 2 {
+			t.Errorf("Handler.HandleContext() calls:\n got = %v\n want = %v", calls, 2)
+		}
+	})
+}
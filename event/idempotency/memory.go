@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	_ Store = (*MemoryStore)(nil)
+
+	ErrInvalidCapacity = errors.New("capacity must be positive")
+)
+
+// MemoryStore is a Store keeping IDs in memory. It remembers up to capacity
+// most recently used IDs, evicting the least recently used ones first.
+// An ID is used when it is added or found by Contains.
+// IDs older than the TTL are forgotten. A TTL of 0 means IDs never expire.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List               // Entries ordered from the most to the least recently used.
+	entries  map[string]*list.Element // Values are *memoryEntry.
+	now      func() time.Time
+}
+
+type memoryEntry struct {
+	id      string
+	addedAt time.Time
+}
+
+// NewMemoryStore returns ErrInvalidCapacity if the capacity is not positive.
+func NewMemoryStore(capacity int, ttl time.Duration) (*MemoryStore, error) {
+	if capacity <= 0 {
+		return nil, ErrInvalidCapacity
+	}
+
+	return &MemoryStore{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		now:      time.Now,
+	}, nil
+}
+
+func (s *MemoryStore) Contains(ctx context.Context, id string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[id]
+	if !ok {
+		return false, nil
+	}
+
+	if s.expired(elem.Value.(*memoryEntry)) {
+		s.remove(elem)
+		return false, nil
+	}
+
+	s.order.MoveToFront(elem)
+	return true, nil
+}
+
+func (s *MemoryStore) Add(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[id]; ok {
+		s.remove(elem)
+	}
+
+	s.entries[id] = s.order.PushFront(&memoryEntry{id: id, addedAt: s.now()})
+
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+
+	// Entries which were not used for the longest are usually the oldest ones.
+	// Expired entries used more recently are evicted once they are looked up.
+	for elem := s.order.Back(); elem != nil && s.expired(elem.Value.(*memoryEntry)); elem = s.order.Back() {
+		s.remove(elem)
+	}
+
+	return nil
+}
+
+// Len returns the number of remembered IDs, including expired ones which were not yet evicted.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// expired must be called with the mutex held.
+func (s *MemoryStore) expired(entry *memoryEntry) bool {
+	return s.ttl > 0 && s.now().Sub(entry.addedAt) > s.ttl
+}
+
+// remove must be called with the mutex held.
+func (s *MemoryStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*memoryEntry).id)
+}
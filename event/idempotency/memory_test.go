@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		desc     string
+		capacity int
+		ttl      time.Duration
+		add      []string
+		used     []string      // IDs looked up before adding the last ID.
+		elapsed  time.Duration // Time passed after adding the IDs.
+		want     map[string]bool
+	}{
+		{
+			desc:     "Test if added IDs are contained",
+			capacity: 10,
+			add:      []string{"1", "2"},
+			want:     map[string]bool{"1": true, "2": true, "3": false},
+		},
+		{
+			desc:     "Test if least recently added IDs are evicted when capacity is exceeded",
+			capacity: 2,
+			add:      []string{"1", "2", "3"},
+			want:     map[string]bool{"1": false, "2": true, "3": true},
+		},
+		{
+			desc:     "Test if adding an ID again refreshes it",
+			capacity: 2,
+			add:      []string{"1", "2", "1", "3"},
+			want:     map[string]bool{"1": true, "2": false, "3": true},
+		},
+		{
+			desc:     "Test if looking up an ID refreshes it",
+			capacity: 2,
+			add:      []string{"1", "2", "3"},
+			used:     []string{"1"},
+			want:     map[string]bool{"1": true, "2": false, "3": true},
+		},
+		{
+			desc:     "Test if IDs expire after the TTL",
+			capacity: 10,
+			ttl:      time.Minute,
+			add:      []string{"1"},
+			elapsed:  time.Minute + time.Second,
+			want:     map[string]bool{"1": false},
+		},
+		{
+			desc:     "Test if IDs do not expire before the TTL",
+			capacity: 10,
+			ttl:      time.Minute,
+			add:      []string{"1"},
+			elapsed:  time.Second,
+			want:     map[string]bool{"1": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := NewMemoryStore(tt.capacity, tt.ttl)
+			if err != nil {
+				t.Errorf("NewMemoryStore() error = %v", err)
+				return
+			}
+			store.now = func() time.Time { return now }
+
+			for i, id := range tt.add {
+				if i == len(tt.add)-1 {
+					for _, used := range tt.used {
+						if _, err := store.Contains(ctx, used); err != nil {
+							t.Errorf("MemoryStore.Contains() error = %v", err)
+							return
+						}
+					}
+				}
+
+				if err := store.Add(ctx, id); err != nil {
+					t.Errorf("MemoryStore.Add() error = %v", err)
+					return
+				}
+			}
+
+			store.now = func() time.Time { return now.Add(tt.elapsed) }
+
+			for id, want := range tt.want {
+				got, err := store.Contains(ctx, id)
+				if err != nil {
+					t.Errorf("MemoryStore.Contains() error = %v", err)
+					return
+				}
+
+				if got != want {
+					t.Errorf("MemoryStore.Contains(%q):\n got = %v\n want = %v", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewMemoryStore(t *testing.T) {
+	t.Run("Test if non-positive capacity is rejected", func(t *testing.T) {
+		for _, capacity := range []int{0, -1} {
+			if _, err := NewMemoryStore(capacity, 0); !errors.Is(err, ErrInvalidCapacity) {
+				t.Errorf("NewMemoryStore(%d):\n got = %v\n want = %v", capacity, err, ErrInvalidCapacity)
+			}
+		}
+	})
+}
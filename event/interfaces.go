@@ -2,6 +2,8 @@ package event
 
 import (
 	"context"
+	"errors"
+	"time"
 )
 
 type Broker interface {
@@ -13,6 +15,45 @@ type Consumer interface {
 	Consume(ctx context.Context, queue string, eventType EventType) (<-chan Event, error)
 }
 
+// DeliveryConsumer is a Consumer which lets the caller decide when
+// a consumed event is acknowledged, allowing for at-least-once processing,
+// eg. by adding the deliveries to a dispatcher using AddDeliveryProvider.
+type DeliveryConsumer interface {
+	Consumer
+
+	// ConsumeDeliveries works like Consume except that every received delivery
+	// has to be settled using its Ack, Nack or Reject methods.
+	ConsumeDeliveries(ctx context.Context, queue string, eventType EventType) (<-chan Delivery, error)
+}
+
+// PatternConsumer is a Consumer which is able to consume events
+// of all types matching a pattern from a single queue.
+type PatternConsumer interface {
+	Consumer
+
+	// ConsumePattern works like Consume except that the queue receives events of all types matching the pattern.
+	ConsumePattern(ctx context.Context, queue string, pattern Pattern) (<-chan Event, error)
+}
+
+// Delivery is a consumed event which has to be settled by the consumer.
+type Delivery struct {
+	Event
+	Acknowledger
+}
+
+type Acknowledger interface {
+	// Ack acknowledges that the event was processed and should not be redelivered.
+	Ack() error
+
+	// Nack negatively acknowledges the event.
+	// If requeue is true the event will be redelivered, otherwise it is discarded.
+	Nack(requeue bool) error
+
+	// Reject rejects the event.
+	// If requeue is true the event will be redelivered, otherwise it is discarded.
+	Reject(requeue bool) error
+}
+
 type Publisher interface {
 
 	// Exchanges and queues are maintained internally depending on the type of event.
@@ -22,6 +63,23 @@ type Publisher interface {
 	ResilientPublish(Event) error
 }
 
+var ErrNotScheduled = errors.New("event is not scheduled")
+
+// Scheduler publishes events at a later time, eg. reminders or deferred cleanups.
+// Scheduled events are identified by their IDs.
+type Scheduler interface {
+	// PublishAt publishes the event at given time. Events scheduled in the past are published immediately.
+	PublishAt(ctx context.Context, e Event, at time.Time) error
+
+	// PublishAfter publishes the event after given delay.
+	PublishAfter(ctx context.Context, e Event, delay time.Duration) error
+
+	// Cancel cancels publishing of the scheduled event with given ID.
+	// Returns ErrNotScheduled if no such event is waiting to be published, unless
+	// the scheduler cannot tell, see the documentation of the implementation.
+	Cancel(ctx context.Context, id string) error
+}
+
 type Subscriber interface {
 	// Subscribe registers an event handler for sepcified types of events.
 	Subscribe(Handler, ...EventType)
@@ -36,3 +94,15 @@ type HandlerFunc func(Event)
 func (fn HandlerFunc) Handle(event Event) {
 	fn(event)
 }
+
+// ContextHandler is a Handler which receives the context it is invoked in
+// and reports whether it failed, allowing the caller to retry the event.
+type ContextHandler interface {
+	HandleContext(context.Context, Event) error
+}
+
+type ContextHandlerFunc func(context.Context, Event) error
+
+func (fn ContextHandlerFunc) HandleContext(ctx context.Context, event Event) error {
+	return fn(ctx, event)
+}
@@ -0,0 +1,65 @@
+package event
+
+import "context"
+
+// Middleware wraps a Handler with additional behaviour, eg. tracing or logging.
+//
+// Middlewares which need the context the event is handled in or the handler's error
+// should return a Handler implementing ContextHandler as well and invoke the wrapped
+// handler using AsContextHandler, so that the context and errors are passed through the chain.
+// See the event/middleware package for built-in middlewares.
+type Middleware func(Handler) Handler
+
+// Chain returns a Middleware applying given middlewares in order,
+// ie. the first middleware is the outermost one.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(h Handler) Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+		return h
+	}
+}
+
+// AsContextHandler returns the handler as a ContextHandler.
+// Handlers which do not implement ContextHandler never fail.
+// Handlers previously converted using AsHandler are unwrapped.
+func AsContextHandler(h Handler) ContextHandler {
+	if adapter, ok := h.(handlerAdapter); ok {
+		return adapter.ContextHandler
+	}
+	if ch, ok := h.(ContextHandler); ok {
+		return ch
+	}
+	return contextHandlerAdapter{h}
+}
+
+// AsHandler returns the handler as a Handler which implements ContextHandler as well.
+// Errors are discarded when the returned handler is invoked using Handle.
+// Handlers previously converted using AsContextHandler are unwrapped.
+func AsHandler(h ContextHandler) Handler {
+	if adapter, ok := h.(contextHandlerAdapter); ok {
+		return adapter.Handler
+	}
+	if handler, ok := h.(Handler); ok {
+		return handler
+	}
+	return handlerAdapter{h}
+}
+
+type contextHandlerAdapter struct {
+	Handler
+}
+
+func (h contextHandlerAdapter) HandleContext(_ context.Context, e Event) error {
+	h.Handle(e)
+	return nil
+}
+
+type handlerAdapter struct {
+	ContextHandler
+}
+
+func (h handlerAdapter) Handle(e Event) {
+	h.HandleContext(context.Background(), e) //nolint:errcheck // Handler can't report errors.
+}
@@ -0,0 +1,126 @@
+// Package middleware implements event.Middleware commonly used with event handlers.
+// All middlewares in this package pass the context and errors of handlers implementing
+// event.ContextHandler through, and return handlers implementing it as well.
+//
+//	d := dispatcher.NewDispatcher(10, dispatcher.WithMiddleware(
+//		middleware.Tracing(tracer),
+//		middleware.Logging(logger),
+//		middleware.Timeout(time.Second*5),
+//	))
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrPanic = errors.New("handler panicked")
+
+// handlerFunc implements both event.Handler and event.ContextHandler.
+type handlerFunc func(context.Context, event.Event) error
+
+func (fn handlerFunc) Handle(e event.Event) {
+	fn(context.Background(), e) //nolint:errcheck // Handler can't report errors.
+}
+
+func (fn handlerFunc) HandleContext(ctx context.Context, e event.Event) error {
+	return fn(ctx, e)
+}
+
+// wrap returns a middleware invoking fn with the wrapped handler converted to a ContextHandler.
+func wrap(fn func(ctx context.Context, e event.Event, next event.ContextHandler) error) event.Middleware {
+	return func(h event.Handler) event.Handler {
+		next := event.AsContextHandler(h)
+		return handlerFunc(func(ctx context.Context, e event.Event) error {
+			return fn(ctx, e, next)
+		})
+	}
+}
+
+// Tracing starts a span for every handled event. The span is a child of the span
+// propagated in the event's metadata, so that traces continue across services.
+func Tracing(tracer trace.Tracer) event.Middleware {
+	return wrap(func(ctx context.Context, e event.Event, next event.ContextHandler) (err error) {
+		ctx = tracing.InjectMetadataIntoContext(ctx, e.Metadata)
+		ctx, span := tracer.Start(ctx, "event.Handle "+string(e.Type), trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+			attribute.String("event.type", string(e.Type)),
+			attribute.String("event.id", e.Id),
+		))
+		defer span.End()
+		defer func() { tracing.SetSpanErr(span, err) }()
+
+		return next.HandleContext(ctx, e)
+	})
+}
+
+// Logging logs every handled event together with the time it took and the handler's error.
+func Logging(logger logging.Logger) event.Middleware {
+	return wrap(func(ctx context.Context, e event.Event, next event.ContextHandler) error {
+		start := time.Now()
+		err := next.HandleContext(ctx, e)
+
+		if err != nil {
+			logger.Log(ctx, "Failed to handle event", "type", e.Type, "id", e.Id, "duration", time.Since(start), "err", err)
+			return err
+		}
+
+		logger.Log(ctx, "Handled event", "type", e.Type, "id", e.Id, "duration", time.Since(start))
+		return nil
+	})
+}
+
+// Recover recovers from panics in handlers and returns them as errors wrapping ErrPanic.
+func Recover() event.Middleware {
+	return wrap(func(ctx context.Context, e event.Event, next event.ContextHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%w: %v\n%s", ErrPanic, r, debug.Stack())
+			}
+		}()
+
+		return next.HandleContext(ctx, e)
+	})
+}
+
+// Metrics records the time handlers take in the "event.handler.duration" histogram,
+// with the event type and whether the handler failed as attributes.
+// Failing to create the histogram is reported to the global OpenTelemetry error handler.
+func Metrics(meter metric.Meter) event.Middleware {
+	duration, err := meter.Float64Histogram("event.handler.duration", metric.WithDescription("Time taken by event handlers."), metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return wrap(func(ctx context.Context, e event.Event, next event.ContextHandler) error {
+		start := time.Now()
+		err := next.HandleContext(ctx, e)
+
+		duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+			attribute.String("event.type", string(e.Type)),
+			attribute.Bool("error", err != nil),
+		))
+		return err
+	})
+}
+
+// Timeout cancels the context passed to handlers after the given time.
+// Handlers have to respect the context's cancellation in order to be interrupted.
+func Timeout(timeout time.Duration) event.Middleware {
+	return wrap(func(ctx context.Context, e event.Event, next event.ContextHandler) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return next.HandleContext(ctx, e)
+	})
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var errTest = errors.New("test")
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		desc    string
+		handler event.ContextHandlerFunc
+		wantErr error
+	}{
+		{
+			desc:    "Test if panic is returned as an error",
+			handler: func(ctx context.Context, e event.Event) error { panic("test") },
+			wantErr: ErrPanic,
+		},
+		{
+			desc:    "Test if errors are passed through",
+			handler: func(ctx context.Context, e event.Event) error { return errTest },
+			wantErr: errTest,
+		},
+		{
+			desc:    "Test if success is passed through",
+			handler: func(ctx context.Context, e event.Event) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			h := event.AsContextHandler(Recover()(event.AsHandler(tt.handler)))
+
+			if err := h.HandleContext(context.Background(), event.Event{}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Recover():\n error = %v\n wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	t.Run("Test if handler's context is cancelled after the timeout", func(t *testing.T) {
+		handler := event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		h := event.AsContextHandler(Timeout(time.Millisecond)(event.AsHandler(handler)))
+
+		if err := h.HandleContext(context.Background(), event.Event{}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Timeout():\n error = %v\n wantErr = %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestTracing(t *testing.T) {
+	t.Run("Test if span continues the trace propagated in the event's metadata", func(t *testing.T) {
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+
+		recorder := tracetest.NewSpanRecorder()
+		tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+		traceId := "0af7651916cd43dd8448eb211c80319c"
+		e := event.Event{
+			Type:     event.ArticleCreated,
+			Metadata: map[string]string{"traceparent": "00-" + traceId + "-b7ad6b7169203331-01"},
+		}
+
+		handler := event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+			return errTest
+		})
+
+		h := event.AsContextHandler(Tracing(tracer)(event.AsHandler(handler)))
+		if err := h.HandleContext(context.Background(), e); !errors.Is(err, errTest) {
+			t.Errorf("Tracing():\n error = %v\n wantErr = %v", err, errTest)
+			return
+		}
+
+		spans := recorder.Ended()
+		if len(spans) != 1 {
+			t.Errorf("Tracing(): expected 1 span, got %d", len(spans))
+			return
+		}
+
+		if got := spans[0].SpanContext().TraceID().String(); got != traceId {
+			t.Errorf("Tracing():\n trace ID = %v\n want = %v", got, traceId)
+			return
+		}
+
+		if len(spans[0].Events()) == 0 {
+			t.Errorf("Tracing(): handler's error was not recorded")
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("Test if handler duration is recorded", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+
+		handler := event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error { return nil })
+		h := event.AsContextHandler(Metrics(meter)(event.AsHandler(handler)))
+
+		for i := 0; i < 3; i++ {
+			if err := h.HandleContext(context.Background(), event.Event{Type: event.ArticleCreated}); err != nil {
+				t.Errorf("Metrics(): error = %v", err)
+				return
+			}
+		}
+
+		rm := metricdata.ResourceMetrics{}
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Errorf("Reader.Collect() error = %v", err)
+			return
+		}
+
+		var count uint64
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if hist, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == "event.handler.duration" {
+					for _, dp := range hist.DataPoints {
+						count += dp.Count
+					}
+				}
+			}
+		}
+
+		if count != 3 {
+			t.Errorf("Metrics():\n recorded = %v\n want = %v", count, 3)
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+)
+
+// Failure describes a record which the relay gave up publishing.
+type Failure struct {
+	Record   Record
+	Err      error // Last error returned by the publisher.
+	Attempts int   // Number of times the relay tried to publish the record.
+}
+
+// FailureSink receives records which the relay gave up publishing, eg. in order
+// to store them for inspection. The records are marked as delivered afterwards.
+type FailureSink interface {
+	HandleFailure(context.Context, Failure)
+}
+
+type FailureSinkFunc func(context.Context, Failure)
+
+func (fn FailureSinkFunc) HandleFailure(ctx context.Context, f Failure) {
+	fn(ctx, f)
+}
+
+// LogSink returns a FailureSink logging failures using given logger.
+// It is the default sink of the Relay.
+func LogSink(logger logging.Logger) FailureSink {
+	return FailureSinkFunc(func(ctx context.Context, f Failure) {
+		logger.Log(ctx, "Gave up publishing event from outbox", "err", f.Err, "id", f.Record.Id, "type", f.Record.Event.Type, "attempts", f.Attempts)
+	})
+}
+
+// IsRetryable reports whether publishing failed because of a transient error.
+// Errors caused by events not matching their registered payload types
+// or having types in invalid format are not.
+func IsRetryable(err error) bool {
+	return !errors.Is(err, event.ErrInvalidBody) &&
+		!errors.Is(err, event.ErrTypeMismatch) &&
+		!errors.Is(err, event.ErrInvalidType)
+}
@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/internal/jsonlog"
+)
+
+var _ Store = (*FileStore)(nil)
+
+// FileStore is a reference Store implementation keeping records in an append-only log file.
+// Every added record and every delivery is appended to the file and synced to disk.
+// Pending records are indexed in memory, which makes the store suitable for a single process only.
+type FileStore struct {
+	mu      sync.Mutex
+	log     *jsonlog.Log[logEntry]
+	pending []Record
+}
+
+// logEntry is a single line of the outbox log.
+type logEntry struct {
+	Record    *Record `json:"record,omitempty"`    // Set when a record was added.
+	Delivered string  `json:"delivered,omitempty"` // Set when a record was delivered.
+}
+
+// NewFileStore opens the outbox log under the given path, creating it if it does not exist,
+// and loads all pending records from it. The store should be closed in order to release the file.
+func NewFileStore(path string) (*FileStore, error) {
+	pending := []Record{}
+	log, err := jsonlog.Open(path, func(entry logEntry) error {
+		if entry.Record != nil {
+			pending = append(pending, *entry.Record)
+			return nil
+		}
+
+		pending = slices.DeleteFunc(pending, func(r Record) bool {
+			return r.Id == entry.Delivered
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{
+		log:     log,
+		pending: pending,
+	}, nil
+}
+
+// Add appends the events to the log as new records.
+func (s *FileStore) Add(ctx context.Context, events ...event.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	records := make([]Record, 0, len(events))
+	entries := make([]logEntry, 0, len(events))
+	for _, e := range events {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return err
+		}
+
+		records = append(records, Record{
+			Id:        id.String(),
+			Event:     e,
+			CreatedAt: time.Now(),
+		})
+		entries = append(entries, logEntry{Record: &records[len(records)-1]})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.log.Append(entries...); err != nil {
+		return err
+	}
+
+	s.pending = append(s.pending, records...)
+	return nil
+}
+
+// Pending returns up to limit undelivered records in the order they were added.
+func (s *FileStore) Pending(ctx context.Context, limit int) ([]Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.pending[:min(limit, len(s.pending))]), nil
+}
+
+// MarkDelivered appends the deliveries to the log and removes the records from the pending ones.
+func (s *FileStore) MarkDelivered(ctx context.Context, ids ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries := make([]logEntry, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, logEntry{Delivered: id})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.log.Append(entries...); err != nil {
+		return err
+	}
+
+	s.pending = slices.DeleteFunc(s.pending, func(r Record) bool {
+		return slices.Contains(ids, r.Id)
+	})
+	return nil
+}
+
+// Compact rewrites the log so that it contains only pending records.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]logEntry, 0, len(s.pending))
+	for i := range s.pending {
+		entries = append(entries, logEntry{Record: &s.pending[i]})
+	}
+
+	return s.log.Rewrite(entries...)
+}
+
+// Close closes the underlying log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
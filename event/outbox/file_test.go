@@ -0,0 +1,142 @@
+package outbox
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/fs"
+	"github.com/krixlion/dev_forum-lib/internal/gentest"
+	"github.com/spf13/afero"
+)
+
+func setUpFileStore(t *testing.T, path string) *FileStore {
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func randomEvents(n int) []event.Event {
+	events := make([]event.Event, 0, n)
+	for i := 0; i < n; i++ {
+		events = append(events, event.Event{
+			AggregateId: event.ArticleAggregate,
+			Type:        event.ArticleCreated,
+			Body:        gentest.RandomJSONArticle(2, 5),
+			Timestamp:   time.Now().Round(0),
+			Metadata:    map[string]string{},
+		})
+	}
+	return events
+}
+
+func pendingEvents(t *testing.T, store Store) []event.Event {
+	records, err := store.Pending(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Store.Pending() error = %v", err)
+	}
+
+	events := make([]event.Event, 0, len(records))
+	for _, r := range records {
+		events = append(events, r.Event)
+	}
+	return events
+}
+
+func TestFileStore(t *testing.T) {
+	t.Run("Test if pending records survive reopening the store", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+		want := randomEvents(3)
+
+		store := setUpFileStore(t, "outbox.log")
+		if err := store.Add(ctx, want...); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+		store.Close()
+
+		got := pendingEvents(t, setUpFileStore(t, "outbox.log"))
+		if !cmp.Equal(got, want, cmpopts.EquateEmpty()) {
+			t.Errorf("FileStore.Pending():\n got = %+v\n want = %+v\n diff = %+v\n", got, want, cmp.Diff(got, want))
+		}
+	})
+
+	t.Run("Test if delivered records are not returned after reopening the store", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+		events := randomEvents(3)
+
+		store := setUpFileStore(t, "outbox.log")
+		if err := store.Add(ctx, events...); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+
+		records, err := store.Pending(ctx, 2)
+		if err != nil {
+			t.Errorf("FileStore.Pending() error = %v", err)
+			return
+		}
+
+		if err := store.MarkDelivered(ctx, records[0].Id, records[1].Id); err != nil {
+			t.Errorf("FileStore.MarkDelivered() error = %v", err)
+			return
+		}
+
+		if err := store.Compact(); err != nil {
+			t.Errorf("FileStore.Compact() error = %v", err)
+			return
+		}
+		store.Close()
+
+		want := events[2:]
+		got := pendingEvents(t, setUpFileStore(t, "outbox.log"))
+		if !cmp.Equal(got, want, cmpopts.EquateEmpty()) {
+			t.Errorf("FileStore.Pending():\n got = %+v\n want = %+v\n diff = %+v\n", got, want, cmp.Diff(got, want))
+		}
+	})
+
+	t.Run("Test if an incomplete trailing record is dropped when reopening the store", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+		want := randomEvents(2)
+
+		store := setUpFileStore(t, "outbox.log")
+		if err := store.Add(ctx, want[0]); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+		store.Close()
+
+		file, err := fs.OpenFile("outbox.log", os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Errorf("fs.OpenFile() error = %v", err)
+			return
+		}
+		if _, err := file.Write([]byte(`{"record":{"id":"torn`)); err != nil {
+			t.Errorf("File.Write() error = %v", err)
+			return
+		}
+		file.Close()
+
+		store = setUpFileStore(t, "outbox.log")
+		if err := store.Add(ctx, want[1]); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+		store.Close()
+
+		got := pendingEvents(t, setUpFileStore(t, "outbox.log"))
+		if !cmp.Equal(got, want, cmpopts.EquateEmpty()) {
+			t.Errorf("FileStore.Pending():\n got = %+v\n want = %+v\n diff = %+v\n", got, want, cmp.Diff(got, want))
+		}
+	})
+}
@@ -0,0 +1,34 @@
+// Package outbox implements the transactional outbox pattern.
+// Services store events in an outbox together with their domain state
+// and a Relay publishes the stored events in the background,
+// so that no event is lost when the broker is unavailable or the service restarts.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+// Record is an event stored in the outbox.
+type Record struct {
+	Id        string      `json:"id"`
+	Event     event.Event `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Store persists events until they are published.
+// Implementations backed by the service's database should allow adding
+// records within the same transaction that changes the domain state.
+type Store interface {
+	// Add stores given events for publishing.
+	Add(ctx context.Context, events ...event.Event) error
+
+	// Pending returns up to limit undelivered records in the order they were added.
+	Pending(ctx context.Context, limit int) ([]Record, error)
+
+	// MarkDelivered marks records with given IDs as delivered.
+	// Delivered records are no longer returned by Pending.
+	MarkDelivered(ctx context.Context, ids ...string) error
+}
@@ -0,0 +1,209 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/nulls"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// KeyFunc returns a key of the event. Events with the same key are published in order.
+// Events with an empty key are not ordered.
+type KeyFunc func(event.Event) string
+
+// Relay publishes events stored in the outbox and marks them as delivered.
+// Records which fail with non-retryable errors or run out of attempts are passed
+// to the failure sink and marked as delivered, so that they do not block other records.
+type Relay struct {
+	store     Store
+	publisher event.Publisher
+	opts      options
+
+	mu       sync.Mutex
+	attempts map[string]int // Failed attempts keyed by record IDs.
+}
+
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(opts *options)
+
+func (fn optionFunc) apply(opts *options) {
+	fn(opts)
+}
+
+type options struct {
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	key         KeyFunc
+	sink        FailureSink // Nil means LogSink with the relay's logger.
+	logger      logging.Logger
+	tracer      trace.Tracer
+}
+
+func defaultOptions() options {
+	return options{
+		interval:  time.Second,
+		batchSize: 100,
+		key:       event.AggregateKey,
+		logger:    nulls.NullLogger{},
+		tracer:    nulls.NullTracer{},
+	}
+}
+
+// WithInterval sets the time between polls of the store. Defaults to 1s.
+func WithInterval(interval time.Duration) Option {
+	return optionFunc(func(opts *options) {
+		opts.interval = interval
+	})
+}
+
+// WithBatchSize sets the max number of records published during a single poll. Defaults to 100.
+func WithBatchSize(size int) Option {
+	return optionFunc(func(opts *options) {
+		opts.batchSize = size
+	})
+}
+
+// WithMaxAttempts sets the max number of times the relay tries to publish a record
+// before passing it to the failure sink. Attempts are counted by the relay, so they
+// start over when the process restarts. Defaults to 0, meaning records failing with
+// retryable errors are retried until they are published, eg. while the broker is unavailable.
+func WithMaxAttempts(attempts int) Option {
+	return optionFunc(func(opts *options) {
+		opts.maxAttempts = attempts
+	})
+}
+
+// WithFailureSink sets the sink receiving records which the relay gave up publishing.
+// Defaults to LogSink.
+func WithFailureSink(sink FailureSink) Option {
+	return optionFunc(func(opts *options) {
+		opts.sink = sink
+	})
+}
+
+// WithKey sets the func used to determine which events have to be published in order.
+// Defaults to event.AggregateKey, ordering events per aggregate instance, eg. per article.
+func WithKey(key KeyFunc) Option {
+	return optionFunc(func(opts *options) {
+		opts.key = key
+	})
+}
+
+func WithLogger(logger logging.Logger) Option {
+	return optionFunc(func(opts *options) {
+		opts.logger = logger
+	})
+}
+
+func WithTracer(tracer trace.Tracer) Option {
+	return optionFunc(func(opts *options) {
+		opts.tracer = tracer
+	})
+}
+
+func NewRelay(store Store, publisher event.Publisher, opts ...Option) *Relay {
+	r := &Relay{
+		store:     store,
+		publisher: publisher,
+		opts:      defaultOptions(),
+		attempts:  make(map[string]int),
+	}
+
+	for _, opt := range opts {
+		opt.apply(&r.opts)
+	}
+
+	if r.opts.sink == nil {
+		r.opts.sink = LogSink(r.opts.logger)
+	}
+
+	return r
+}
+
+// Run blocks until the context is cancelled.
+// Run polls the store for pending records and publishes them.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Flush(ctx); err != nil {
+			r.opts.logger.Log(ctx, "Failed to flush outbox", "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush publishes a single batch of pending records in the order they were added.
+// Once publishing an event fails, the following events with the same key are not
+// published until the next flush so that the order of these events is preserved.
+// Failed publishes are logged and do not cause Flush to return an error.
+// Records which the relay gives up publishing are passed to the failure sink instead
+// and do not block the following events.
+func (r *Relay) Flush(ctx context.Context) (err error) {
+	ctx, span := r.opts.tracer.Start(ctx, "outbox.Flush")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	records, err := r.store.Pending(ctx, r.opts.batchSize)
+	if err != nil {
+		return err
+	}
+
+	blocked := make(map[string]struct{})
+	for _, record := range records {
+		key := r.opts.key(record.Event)
+		if _, ok := blocked[key]; ok {
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, record.Event); err != nil {
+			attempts, giveUp := r.failed(record.Id, err)
+			if !giveUp {
+				if key != "" {
+					blocked[key] = struct{}{}
+				}
+				r.opts.logger.Log(ctx, "Failed to publish event from outbox", "err", err, "id", record.Id)
+				continue
+			}
+
+			r.opts.sink.HandleFailure(ctx, Failure{Record: record, Err: err, Attempts: attempts})
+		}
+
+		r.mu.Lock()
+		delete(r.attempts, record.Id)
+		r.mu.Unlock()
+
+		if err := r.store.MarkDelivered(ctx, record.Id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// failed counts the failed attempt to publish the record
+// and reports whether the relay should give up publishing it.
+func (r *Relay) failed(id string, err error) (attempts int, giveUp bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempts[id]++
+	attempts = r.attempts[id]
+
+	return attempts, !IsRetryable(err) || r.opts.maxAttempts > 0 && attempts >= r.opts.maxAttempts
+}
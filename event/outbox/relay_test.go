@@ -0,0 +1,157 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/fs"
+	"github.com/krixlion/dev_forum-lib/mocks"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRelay_Flush(t *testing.T) {
+	t.Run("Test if failed publish blocks only the following events with the same key", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+
+		failing := event.Event{AggregateId: event.ArticleAggregate, AggregateInstanceId: "1", Type: event.ArticleCreated, Body: []byte("1")}
+		blocked := event.Event{AggregateId: event.ArticleAggregate, AggregateInstanceId: "1", Type: event.ArticleUpdated, Body: []byte("2")}
+		other := event.Event{AggregateId: event.ArticleAggregate, AggregateInstanceId: "2", Type: event.ArticleUpdated, Body: []byte("3")}
+
+		store := setUpFileStore(t, "outbox.log")
+		if err := store.Add(ctx, failing, blocked, other); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+
+		broker := mocks.NewBroker()
+		broker.On("Publish", mock.Anything, failing).Return(errors.New("test err")).Once()
+		broker.On("Publish", mock.Anything, other).Return(nil).Once()
+
+		if err := NewRelay(store, broker).Flush(ctx); err != nil {
+			t.Errorf("Relay.Flush() error = %v", err)
+			return
+		}
+
+		broker.AssertExpectations(t)
+		broker.AssertNotCalled(t, "Publish", mock.Anything, blocked)
+
+		want := []event.Event{failing, blocked}
+		if got := pendingEvents(t, store); !cmp.Equal(got, want) {
+			t.Errorf("Relay.Flush():\n got = %+v\n want = %+v\n", got, want)
+		}
+	})
+
+	t.Run("Test if failed publish does not block events without aggregate instance", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+
+		failing := event.Event{AggregateId: event.UserAggregate, Type: event.UserCreated, Body: []byte("1")}
+		other := event.Event{AggregateId: event.UserAggregate, Type: event.UserDeleted, Body: []byte("2")}
+
+		store := setUpFileStore(t, "outbox.log")
+		if err := store.Add(ctx, failing, other); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+
+		broker := mocks.NewBroker()
+		broker.On("Publish", mock.Anything, failing).Return(errors.New("test err")).Once()
+		broker.On("Publish", mock.Anything, other).Return(nil).Once()
+
+		if err := NewRelay(store, broker).Flush(ctx); err != nil {
+			t.Errorf("Relay.Flush() error = %v", err)
+			return
+		}
+
+		broker.AssertExpectations(t)
+
+		want := []event.Event{failing}
+		if got := pendingEvents(t, store); !cmp.Equal(got, want) {
+			t.Errorf("Relay.Flush():\n got = %+v\n want = %+v\n", got, want)
+		}
+	})
+
+	t.Run("Test if record failing with a non-retryable error is passed to the sink and does not block its key", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+
+		poison := event.Event{AggregateId: event.ArticleAggregate, AggregateInstanceId: "1", Type: event.ArticleCreated, Body: []byte("1")}
+		next := event.Event{AggregateId: event.ArticleAggregate, AggregateInstanceId: "1", Type: event.ArticleUpdated, Body: []byte("2")}
+
+		store := setUpFileStore(t, "outbox.log")
+		if err := store.Add(ctx, poison, next); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+
+		broker := mocks.NewBroker()
+		broker.On("Publish", mock.Anything, poison).Return(event.ErrInvalidBody).Once()
+		broker.On("Publish", mock.Anything, next).Return(nil).Once()
+
+		failures := []Failure{}
+		sink := FailureSinkFunc(func(ctx context.Context, f Failure) {
+			failures = append(failures, f)
+		})
+
+		if err := NewRelay(store, broker, WithFailureSink(sink)).Flush(ctx); err != nil {
+			t.Errorf("Relay.Flush() error = %v", err)
+			return
+		}
+
+		broker.AssertExpectations(t)
+
+		if len(failures) != 1 || failures[0].Record.Event.Type != poison.Type || failures[0].Attempts != 1 {
+			t.Errorf("Relay.Flush() failures:\n got = %+v\n want = %+v", failures, poison)
+			return
+		}
+
+		if got := pendingEvents(t, store); len(got) != 0 {
+			t.Errorf("Relay.Flush():\n got = %+v\n want = %+v\n", got, []event.Event{})
+		}
+	})
+
+	t.Run("Test if record is passed to the sink once it runs out of attempts", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+		ctx := context.Background()
+
+		failing := event.Event{AggregateId: event.ArticleAggregate, AggregateInstanceId: "1", Type: event.ArticleCreated, Body: []byte("1")}
+
+		store := setUpFileStore(t, "outbox.log")
+		if err := store.Add(ctx, failing); err != nil {
+			t.Errorf("FileStore.Add() error = %v", err)
+			return
+		}
+
+		broker := mocks.NewBroker()
+		broker.On("Publish", mock.Anything, failing).Return(errors.New("test err")).Twice()
+
+		failures := 0
+		sink := FailureSinkFunc(func(ctx context.Context, f Failure) {
+			failures++
+		})
+
+		relay := NewRelay(store, broker, WithMaxAttempts(2), WithFailureSink(sink))
+		for i := 0; i < 2; i++ {
+			if got := pendingEvents(t, store); len(got) != 1 || failures != 0 {
+				t.Errorf("Record was given up before running out of attempts:\n pending = %+v\n failures = %d", got, failures)
+				return
+			}
+
+			if err := relay.Flush(ctx); err != nil {
+				t.Errorf("Relay.Flush() error = %v", err)
+				return
+			}
+		}
+
+		broker.AssertExpectations(t)
+
+		if got := pendingEvents(t, store); len(got) != 0 || failures != 1 {
+			t.Errorf("Record was not given up:\n pending = %+v\n failures = %d", got, failures)
+		}
+	})
+}
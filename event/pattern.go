@@ -0,0 +1,70 @@
+package event
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidPattern = errors.New("pattern does not follow {noun}-{action} format")
+	ErrInvalidType    = errors.New("event type does not follow {noun}-{action} format")
+)
+
+// Wildcard matches any noun or action of an event type.
+const Wildcard = "*"
+
+// AnyEvent matches events of all types.
+const AnyEvent Pattern = "*-*"
+
+// Matcher decides whether an event should be handled by a subscriber.
+type Matcher interface {
+	Match(Event) bool
+}
+
+// Pattern matches event types following the "{noun}-{action}" format.
+// Either the noun or the action can be replaced with a Wildcard.
+// Eg. "article-*" matches all article events and "*-deleted" matches all deletions.
+type Pattern string
+
+// Split returns the noun and action of the pattern.
+// Returns ErrInvalidPattern if the pattern does not follow the {noun}-{action} format.
+func (p Pattern) Split() (noun, action string, err error) {
+	noun, action, found := strings.Cut(string(p), "-")
+	if !found || noun == "" || action == "" || strings.Contains(action, "-") {
+		return "", "", ErrInvalidPattern
+	}
+
+	return noun, action, nil
+}
+
+// MatchType reports whether the event type matches the pattern.
+// Invalid patterns match no event types.
+func (p Pattern) MatchType(eType EventType) bool {
+	noun, action, err := p.Split()
+	if err != nil {
+		return false
+	}
+
+	typeNoun, typeAction, found := strings.Cut(string(eType), "-")
+	if !found {
+		return false
+	}
+
+	return (noun == Wildcard || noun == typeNoun) && (action == Wildcard || action == typeAction)
+}
+
+// Match reports whether the event's type matches the pattern.
+func (p Pattern) Match(e Event) bool {
+	return p.MatchType(e.Type)
+}
+
+// Predicate is an arbitrary Matcher, eg. matching events of a specific aggregate.
+//
+//	event.Predicate(func(e event.Event) bool {
+//		return e.AggregateId == event.ArticleAggregate && e.Metadata["tenant"] == "test"
+//	})
+type Predicate func(Event) bool
+
+func (fn Predicate) Match(e Event) bool {
+	return fn(e)
+}
@@ -0,0 +1,62 @@
+package event
+
+import "testing"
+
+func TestPattern_MatchType(t *testing.T) {
+	tests := []struct {
+		desc    string
+		pattern Pattern
+		arg     EventType
+		want    bool
+	}{
+		{
+			desc:    "Test if wildcard action matches any action of the noun",
+			pattern: "article-*",
+			arg:     ArticleDeleted,
+			want:    true,
+		},
+		{
+			desc:    "Test if wildcard action does not match other nouns",
+			pattern: "article-*",
+			arg:     UserDeleted,
+			want:    false,
+		},
+		{
+			desc:    "Test if wildcard noun matches the action of any noun",
+			pattern: "*-deleted",
+			arg:     UserDeleted,
+			want:    true,
+		},
+		{
+			desc:    "Test if wildcard noun does not match other actions",
+			pattern: "*-deleted",
+			arg:     UserCreated,
+			want:    false,
+		},
+		{
+			desc:    "Test if AnyEvent matches every event type",
+			pattern: AnyEvent,
+			arg:     KeySetUpdated,
+			want:    true,
+		},
+		{
+			desc:    "Test if pattern without wildcards matches only the same type",
+			pattern: "user-logged_in",
+			arg:     UserLoggedIn,
+			want:    true,
+		},
+		{
+			desc:    "Test if invalid pattern matches nothing",
+			pattern: "*",
+			arg:     ArticleCreated,
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := tt.pattern.MatchType(tt.arg); got != tt.want {
+				t.Errorf("Pattern.MatchType():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
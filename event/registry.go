@@ -0,0 +1,162 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+var (
+	ErrTypeMismatch = errors.New("payload type does not match the type registered for the event type")
+	ErrInvalidBody  = errors.New("event body does not match the type registered for the event type")
+)
+
+// registry maps event types to Go types of their payloads and upcasters of their schemas.
+var registry = struct {
+	mu        sync.RWMutex
+	types     map[EventType]reflect.Type
+	upcasters map[EventType]map[int]Upcaster // Upcasters keyed by the version they upcast from.
+}{
+	types:     make(map[EventType]reflect.Type),
+	upcasters: make(map[EventType]map[int]Upcaster),
+}
+
+// Register registers T as the payload type of the given event types.
+// Events of these types are then validated against T when they are made,
+// published or consumed. Registering a type again overrides the previous one.
+//
+//	func init() {
+//		event.Register[Article](event.ArticleCreated, event.ArticleUpdated)
+//		event.Register[string](event.ArticleDeleted)
+//	}
+func Register[T any](eTypes ...EventType) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for _, eType := range eTypes {
+		registry.types[eType] = reflect.TypeFor[T]()
+	}
+}
+
+// PayloadType returns the payload type registered for the event type
+// and false if there is no type registered.
+func PayloadType(eType EventType) (reflect.Type, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	t, ok := registry.types[eType]
+	return t, ok
+}
+
+// Decode unmarshals the body of the event into T.
+// Returns ErrTypeMismatch if T is not the payload type registered for the event's type.
+// Bodies of events with no registered type are decoded into T as they are.
+func Decode[T any](e Event) (T, error) {
+	var payload T
+
+	if err := checkPayloadType(e.Type, reflect.TypeFor[T]()); err != nil {
+		return payload, err
+	}
+
+	if err := json.Unmarshal(e.Body, &payload); err != nil {
+		return payload, fmt.Errorf("%w: failed to decode %q body into %s: %v", ErrInvalidBody, e.Type, reflect.TypeFor[T](), err)
+	}
+
+	return payload, nil
+}
+
+// Validate returns ErrInvalidBody if the body of the event cannot be decoded
+// into the payload type registered for the event's type or is followed by other data.
+// Fields the payload type does not have are ignored, so that consumers accept events
+// of producers which added new fields. Events with no registered type are always valid.
+func Validate(e Event) error {
+	return validate(e, false)
+}
+
+// ValidateStrict works like Validate, except that bodies containing fields
+// the payload type does not have are invalid as well. It is meant for published events.
+func ValidateStrict(e Event) error {
+	return validate(e, true)
+}
+
+func validate(e Event, strict bool) error {
+	t, ok := PayloadType(e.Type)
+	if !ok {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(e.Body))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(reflect.New(t).Interface()); err != nil {
+		return fmt.Errorf("%w: failed to decode %q body into %s: %v", ErrInvalidBody, e.Type, t, err)
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %q body has data following the %s value", ErrInvalidBody, e.Type, t)
+	}
+
+	return nil
+}
+
+// checkPayloadType returns ErrTypeMismatch if the given type is neither the payload type
+// registered for the event type nor a pointer to it.
+func checkPayloadType(eType EventType, t reflect.Type) error {
+	registered, ok := PayloadType(eType)
+	if !ok || t == registered || (t != nil && t.Kind() == reflect.Pointer && t.Elem() == registered) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q expects %s, got %s", ErrTypeMismatch, eType, registered, t)
+}
+
+// TypedHandlerFunc is an adapter allowing to use a func receiving
+// the event together with its decoded payload as a Handler.
+// It implements ContextHandler as well, failing with the decoding error on events
+// which cannot be decoded into T, so that dispatchers pass them to their failure sinks.
+// Such events are skipped when the handler is invoked using Handle.
+//
+//	d.Subscribe(event.ArticleCreated, event.TypedHandlerFunc[Article](func(e event.Event, article Article) {
+//		// ...
+//	}))
+type TypedHandlerFunc[T any] func(Event, T)
+
+func (fn TypedHandlerFunc[T]) Handle(e Event) {
+	payload, err := Decode[T](e)
+	if err != nil {
+		return
+	}
+
+	fn(e, payload)
+}
+
+func (fn TypedHandlerFunc[T]) HandleContext(ctx context.Context, e Event) error {
+	payload, err := Decode[T](e)
+	if err != nil {
+		return err
+	}
+
+	fn(e, payload)
+	return nil
+}
+
+// TypedContextHandlerFunc is an adapter allowing to use a func receiving
+// the event together with its decoded payload as a ContextHandler.
+// Events which cannot be decoded into T fail with the decoding error.
+type TypedContextHandlerFunc[T any] func(context.Context, Event, T) error
+
+func (fn TypedContextHandlerFunc[T]) HandleContext(ctx context.Context, e Event) error {
+	payload, err := Decode[T](e)
+	if err != nil {
+		return err
+	}
+
+	return fn(ctx, e, payload)
+}
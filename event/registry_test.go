@@ -0,0 +1,158 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/internal/gentest"
+	"github.com/krixlion/dev_forum-lib/internal/testtypes"
+)
+
+const (
+	testArticleRegistered EventType = "test_article-registered"
+	testStringRegistered  EventType = "test_string-registered"
+)
+
+func init() {
+	Register[testtypes.Article](testArticleRegistered)
+	Register[string](testStringRegistered)
+}
+
+func TestDecode(t *testing.T) {
+	article := gentest.RandomArticle(2, 5)
+	body, err := json.Marshal(article)
+	if err != nil {
+		panic(err)
+	}
+
+	t.Run("Test if body is decoded into registered type", func(t *testing.T) {
+		got, err := Decode[testtypes.Article](Event{Type: testArticleRegistered, Body: body})
+		if err != nil {
+			t.Errorf("Decode(): error = %v", err)
+			return
+		}
+
+		if !cmp.Equal(got, article) {
+			t.Errorf("Decode():\n got = %+v\n want = %+v\n", got, article)
+		}
+	})
+
+	t.Run("Test if returns ErrTypeMismatch on unregistered type", func(t *testing.T) {
+		if _, err := Decode[string](Event{Type: testArticleRegistered, Body: body}); !errors.Is(err, ErrTypeMismatch) {
+			t.Errorf("Decode():\n error = %v\n want = %v\n", err, ErrTypeMismatch)
+		}
+	})
+
+	t.Run("Test if returns ErrInvalidBody on body not matching the type", func(t *testing.T) {
+		if _, err := Decode[string](Event{Type: testStringRegistered, Body: body}); !errors.Is(err, ErrInvalidBody) {
+			t.Errorf("Decode():\n error = %v\n want = %v\n", err, ErrInvalidBody)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     Event
+		wantErr bool
+	}{
+		{
+			name:    "Test if event with valid body is valid",
+			arg:     Event{Type: testArticleRegistered, Body: gentest.RandomJSONArticle(2, 5)},
+			wantErr: false,
+		},
+		{
+			name:    "Test if event with body not matching the registered type is invalid",
+			arg:     Event{Type: testStringRegistered, Body: gentest.RandomJSONArticle(2, 5)},
+			wantErr: true,
+		},
+		{
+			name:    "Test if event with fields missing from the registered type is valid",
+			arg:     Event{Type: testArticleRegistered, Body: []byte(`{"id":"1","unknown":true}`)},
+			wantErr: false,
+		},
+		{
+			name:    "Test if event with data following the body is invalid",
+			arg:     Event{Type: testStringRegistered, Body: []byte(`"test" "test"`)},
+			wantErr: true,
+		},
+		{
+			name:    "Test if event with a closing delimiter following the body is invalid",
+			arg:     Event{Type: testArticleRegistered, Body: []byte(`{"id":"1"}}`)},
+			wantErr: true,
+		},
+		{
+			name:    "Test if event with whitespace following the body is valid",
+			arg:     Event{Type: testStringRegistered, Body: []byte("\"test\"\n")},
+			wantErr: false,
+		},
+		{
+			name:    "Test if event with no registered type is valid",
+			arg:     Event{Type: ArticleCreated, Body: []byte(`"test"`)},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.arg); (err != nil) != tt.wantErr {
+				t.Errorf("Validate():\n error = %v\n wantErr = %v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateStrict(t *testing.T) {
+	t.Run("Test if event with fields missing from the registered type is invalid", func(t *testing.T) {
+		e := Event{Type: testArticleRegistered, Body: []byte(`{"id":"1","unknown":true}`)}
+		if err := ValidateStrict(e); !errors.Is(err, ErrInvalidBody) {
+			t.Errorf("ValidateStrict():\n error = %v\n want = %v\n", err, ErrInvalidBody)
+		}
+	})
+}
+
+func TestMakeEvent_registeredType(t *testing.T) {
+	t.Run("Test if returns ErrTypeMismatch on body not matching the registered type", func(t *testing.T) {
+		if _, err := MakeEvent(ArticleAggregate, testStringRegistered, gentest.RandomArticle(2, 5), nil); !errors.Is(err, ErrTypeMismatch) {
+			t.Errorf("MakeEvent():\n error = %v\n want = %v\n", err, ErrTypeMismatch)
+		}
+	})
+
+	t.Run("Test if accepts a pointer to the registered type", func(t *testing.T) {
+		article := gentest.RandomArticle(2, 5)
+		if _, err := MakeEvent(ArticleAggregate, testArticleRegistered, &article, nil); err != nil {
+			t.Errorf("MakeEvent(): error = %v", err)
+		}
+	})
+}
+
+func TestTypedHandlerFunc(t *testing.T) {
+	t.Run("Test if handler receives decoded payload", func(t *testing.T) {
+		want := gentest.RandomArticle(2, 5)
+		body, err := json.Marshal(want)
+		if err != nil {
+			panic(err)
+		}
+
+		var got testtypes.Article
+		handler := TypedHandlerFunc[testtypes.Article](func(_ Event, article testtypes.Article) {
+			got = article
+		})
+		handler.Handle(Event{Type: testArticleRegistered, Body: body})
+
+		if !cmp.Equal(got, want) {
+			t.Errorf("TypedHandlerFunc.Handle():\n got = %+v\n want = %+v\n", got, want)
+		}
+	})
+	t.Run("Test if event which cannot be decoded fails with ErrInvalidBody", func(t *testing.T) {
+		handler := TypedHandlerFunc[testtypes.Article](func(_ Event, _ testtypes.Article) {
+			t.Errorf("TypedHandlerFunc.HandleContext(): handler was invoked with an invalid body")
+		})
+
+		if err := handler.HandleContext(context.Background(), Event{Type: testArticleRegistered, Body: []byte(`"test"`)}); !errors.Is(err, ErrInvalidBody) {
+			t.Errorf("TypedHandlerFunc.HandleContext():\n error = %v\n want = %v\n", err, ErrInvalidBody)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package saga
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore is a Store keeping instances in memory, meant for tests and single process deployments.
+type MemoryStore struct {
+	mu        sync.Mutex
+	instances map[instanceKey]Instance
+}
+
+type instanceKey struct {
+	saga          string
+	correlationId string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		instances: make(map[instanceKey]Instance),
+	}
+}
+
+func (s *MemoryStore) Load(ctx context.Context, saga, correlationId string) (Instance, error) {
+	if err := ctx.Err(); err != nil {
+		return Instance{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instance, ok := s.instances[instanceKey{saga, correlationId}]
+	if !ok {
+		return Instance{}, ErrNotFound
+	}
+
+	instance.State = slices.Clone(instance.State)
+	instance.Pending = slices.Clone(instance.Pending)
+	return instance, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, instance Instance) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instance.State = slices.Clone(instance.State)
+	instance.Pending = slices.Clone(instance.Pending)
+	s.instances[instanceKey{instance.Saga, instance.CorrelationId}] = instance
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, saga, correlationId string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.instances, instanceKey{saga, correlationId})
+	return nil
+}
+
+func (s *MemoryStore) Expired(ctx context.Context, saga string, now time.Time, limit int) ([]Instance, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := []Instance{}
+	for key, instance := range s.instances {
+		if key.saga == saga && !instance.Deadline.IsZero() && !instance.Deadline.After(now) {
+			instance.State = slices.Clone(instance.State)
+			instance.Pending = slices.Clone(instance.Pending)
+			expired = append(expired, instance)
+		}
+	}
+
+	slices.SortFunc(expired, func(a, b Instance) int {
+		return a.Deadline.Compare(b.Deadline)
+	})
+
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,43 @@
+package saga
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMemoryStore_Expired(t *testing.T) {
+	t.Run("Test if returns expired instances of the saga ordered by deadline", func(t *testing.T) {
+		ctx := context.Background()
+		now := time.Now()
+
+		instances := []Instance{
+			{Saga: "test", CorrelationId: "late", Deadline: now.Add(-time.Second)},
+			{Saga: "test", CorrelationId: "early", Deadline: now.Add(-time.Minute)},
+			{Saga: "test", CorrelationId: "pending", Deadline: now.Add(time.Minute)},
+			{Saga: "test", CorrelationId: "no_deadline"},
+			{Saga: "other", CorrelationId: "other", Deadline: now.Add(-time.Minute)},
+		}
+
+		s := NewMemoryStore()
+		for _, instance := range instances {
+			if err := s.Save(ctx, instance); err != nil {
+				t.Errorf("MemoryStore.Save() error = %v", err)
+				return
+			}
+		}
+
+		got, err := s.Expired(ctx, "test", now, 10)
+		if err != nil {
+			t.Errorf("MemoryStore.Expired() error = %v", err)
+			return
+		}
+
+		want := []Instance{instances[1], instances[0]}
+		if !cmp.Equal(got, want) {
+			t.Errorf("MemoryStore.Expired():\n got = %v\n want = %v\n diff = %v", got, want, cmp.Diff(got, want))
+		}
+	})
+}
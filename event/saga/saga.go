@@ -0,0 +1,424 @@
+// Package saga implements process managers coordinating long-running workflows
+// spanning multiple services, eg. cleaning up articles and comments of a deleted user.
+//
+// A Manager keeps the state of every run of a workflow, called an instance, in a Store
+// under the correlation ID of the events which belong to it. Incoming events move
+// the instance forward by invoking the steps declared for their types. Steps update
+// the state and emit commands or compensating events, which are saved together with
+// the new state and published afterwards. Events are kept with the instance until the publisher
+// confirms them and are published again before the instance handles its next event, so every step
+// is applied once and its events are not lost. Completed instances are kept in the store, so that
+// redelivered events of their correlation do not start them again. Instances which are not completed
+// before their deadline time out, which publishes a TimedOut event and invokes the saga's timeout step.
+//
+//	m := saga.New[State]("user_deletion", store, broker, saga.WithTimeout(time.Minute)).
+//		StartedBy(event.UserDeleted, func(c *saga.Context[State], e event.Event) error {
+//			c.State.UserId = e.AggregateInstanceId
+//			return c.Emit(event.ArticleAggregate, DeleteUserArticles, c.State.UserId)
+//		}).
+//		On(UserArticlesDeleted, func(c *saga.Context[State], e event.Event) error {
+//			c.Complete()
+//			return nil
+//		}).
+//		OnTimeout(func(c *saga.Context[State], e event.Event) error {
+//			c.Complete()
+//			return c.Emit(event.UserAggregate, RestoreUser, c.State.UserId)
+//		})
+//
+//	d.Register(m)
+//	go m.Run(ctx)
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/internal/keylock"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/nulls"
+)
+
+// TimedOut is published when an instance of a saga is not completed before its deadline.
+// Its body is a Timeout and its correlation ID is the one of the instance.
+const TimedOut event.EventType = "saga-timed_out"
+
+// Timeout is the body of TimedOut events.
+type Timeout struct {
+	Saga     string    `json:"saga"`
+	Deadline time.Time `json:"deadline"`
+}
+
+func init() {
+	event.Register[Timeout](TimedOut)
+}
+
+// Step handles an event belonging to an instance of a saga.
+// Changes to the state and emitted events are discarded if the step returns an error.
+type Step[S any] func(c *Context[S], e event.Event) error
+
+// Context is passed to steps. It gives access to the instance's state
+// and allows to emit events and to complete the instance.
+type Context[S any] struct {
+	context.Context
+
+	// State of the instance. It is saved after the step returns.
+	State         S
+	CorrelationId string
+
+	cause     event.Event
+	emitted   []event.Event
+	completed bool
+	deadline  time.Time
+	now       time.Time
+}
+
+// Emit makes a follow-up event of the handled event and publishes it once the state is saved.
+// The emitted event joins the instance's correlation and carries the handled event's metadata.
+func (c *Context[S]) Emit(aggregateId event.AggregateId, eType event.EventType, body any) error {
+	e, err := event.MakeFollowUpEvent(c.cause, aggregateId, eType, body, maps.Clone(c.cause.Metadata))
+	if err != nil {
+		return err
+	}
+
+	c.emitted = append(c.emitted, e)
+	return nil
+}
+
+// Complete marks the instance as completed. Completed instances are kept in the store
+// and events of their correlation are ignored afterwards, including events starting the saga.
+func (c *Context[S]) Complete() {
+	c.completed = true
+}
+
+// SetTimeout sets the instance's deadline to the given time from now.
+// A timeout of 0 removes the deadline.
+func (c *Context[S]) SetTimeout(timeout time.Duration) {
+	c.deadline = time.Time{}
+	if timeout > 0 {
+		c.deadline = c.now.Add(timeout)
+	}
+}
+
+// Manager manages instances of a single saga.
+// It implements dispatcher.Listener, subscribing itself to all event types of its steps.
+// Errors returned by steps and the store are returned to the dispatcher,
+// so that the event can be retried according to the subscription's retry policy.
+//
+// Events of an instance are handled one at a time within a single process.
+// Running multiple processes sharing the store requires the events of an instance
+// to be consumed by a single process, eg. using a single active consumer.
+type Manager[S any] struct {
+	name      string
+	store     Store
+	publisher event.Publisher
+	opts      options
+	steps     map[event.EventType]step[S]
+	onTimeout Step[S] // Nil means instances are completed once they time out.
+	now       func() time.Time
+
+	inFlight keylock.Locks // Held by instances being handled, keyed by their correlation IDs.
+}
+
+type step[S any] struct {
+	step  Step[S]
+	start bool // Whether the step starts new instances.
+}
+
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(opts *options)
+
+func (fn optionFunc) apply(opts *options) {
+	fn(opts)
+}
+
+type options struct {
+	timeout   time.Duration
+	interval  time.Duration
+	batchSize int
+	logger    logging.Logger
+}
+
+func defaultOptions() options {
+	return options{
+		interval:  time.Second,
+		batchSize: 100,
+		logger:    nulls.NullLogger{},
+	}
+}
+
+// WithTimeout sets the time new instances have to be completed in.
+// Steps can change an instance's deadline using Context.SetTimeout.
+// Defaults to 0, meaning instances never time out.
+func WithTimeout(timeout time.Duration) Option {
+	return optionFunc(func(opts *options) {
+		opts.timeout = timeout
+	})
+}
+
+// WithInterval sets the time between polls of the store for timed out instances. Defaults to 1s.
+func WithInterval(interval time.Duration) Option {
+	return optionFunc(func(opts *options) {
+		opts.interval = interval
+	})
+}
+
+// WithBatchSize sets the max number of timed out instances handled during a single poll. Defaults to 100.
+func WithBatchSize(size int) Option {
+	return optionFunc(func(opts *options) {
+		opts.batchSize = size
+	})
+}
+
+func WithLogger(logger logging.Logger) Option {
+	return optionFunc(func(opts *options) {
+		opts.logger = logger
+	})
+}
+
+// New returns a manager of the saga with given name. The name identifies
+// the saga's instances in the store, so it must not change between deployments.
+// Emitted events are published using the publisher's Publish.
+func New[S any](name string, store Store, publisher event.Publisher, opts ...Option) *Manager[S] {
+	m := &Manager[S]{
+		name:      name,
+		store:     store,
+		publisher: publisher,
+		opts:      defaultOptions(),
+		steps:     make(map[event.EventType]step[S]),
+		now:       time.Now,
+	}
+
+	for _, opt := range opts {
+		opt.apply(&m.opts)
+	}
+
+	return m
+}
+
+// StartedBy declares a step starting a new instance when an event of given type is received.
+// If the instance of the event's correlation already exists, the step is invoked on it instead.
+func (m *Manager[S]) StartedBy(eType event.EventType, s Step[S]) *Manager[S] {
+	m.steps[eType] = step[S]{step: s, start: true}
+	return m
+}
+
+// On declares a step moving an existing instance forward when an event of given type is received.
+// Events which do not belong to any instance are ignored.
+func (m *Manager[S]) On(eType event.EventType, s Step[S]) *Manager[S] {
+	m.steps[eType] = step[S]{step: s}
+	return m
+}
+
+// OnTimeout declares a step invoked with the TimedOut event when an instance times out,
+// eg. to emit compensating events. The instance's deadline is removed before the step
+// is invoked, so the step should either complete the instance or set a new timeout.
+// By default timed out instances are completed.
+func (m *Manager[S]) OnTimeout(s Step[S]) *Manager[S] {
+	m.onTimeout = s
+	return m
+}
+
+// EventHandlers returns the manager as the handler of all event types of its steps.
+func (m *Manager[S]) EventHandlers() map[event.EventType][]event.Handler {
+	handlers := make(map[event.EventType][]event.Handler, len(m.steps))
+	for eType := range m.steps {
+		handlers[eType] = []event.Handler{m}
+	}
+	return handlers
+}
+
+// Handle works like HandleContext, except that errors are logged.
+func (m *Manager[S]) Handle(e event.Event) {
+	if err := m.HandleContext(context.Background(), e); err != nil {
+		m.opts.logger.Log(context.Background(), "Failed to handle saga event", "err", err, "saga", m.name, "type", e.Type, "id", e.Id)
+	}
+}
+
+// HandleContext invokes the step declared for the event's type on the instance of the event's correlation.
+// Events without a correlation ID and events of types without a step are ignored.
+func (m *Manager[S]) HandleContext(ctx context.Context, e event.Event) error {
+	s, ok := m.steps[e.Type]
+	if !ok || e.CorrelationId == "" {
+		return nil
+	}
+
+	release := m.inFlight.Acquire(e.CorrelationId)
+	defer release()
+
+	now := m.now()
+	instance, err := m.store.Load(ctx, m.name, e.CorrelationId)
+	switch {
+	case errors.Is(err, ErrNotFound) && s.start:
+		instance = Instance{
+			Saga:          m.name,
+			CorrelationId: e.CorrelationId,
+			StartedAt:     now,
+		}
+		if m.opts.timeout > 0 {
+			instance.Deadline = now.Add(m.opts.timeout)
+		}
+	case errors.Is(err, ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	default:
+		if err := m.flush(ctx, &instance); err != nil || instance.Completed {
+			return err
+		}
+
+		if e.Id != "" && e.Id == instance.LastEventId {
+			return nil
+		}
+	}
+
+	return m.advance(ctx, instance, e, s.step, now)
+}
+
+// Run blocks until the context is cancelled.
+// Run polls the store for timed out instances and handles their timeouts.
+func (m *Manager[S]) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.HandleTimeouts(ctx); err != nil {
+			m.opts.logger.Log(ctx, "Failed to handle saga timeouts", "err", err, "saga", m.name)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// HandleTimeouts handles a single batch of timed out instances.
+// For every instance a TimedOut event is published and the timeout step is invoked.
+// Instances whose deadline was moved by a concurrently handled event are skipped.
+func (m *Manager[S]) HandleTimeouts(ctx context.Context) error {
+	expired, err := m.store.Expired(ctx, m.name, m.now(), m.opts.batchSize)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, instance := range expired {
+		errs = append(errs, m.timeout(ctx, instance.CorrelationId))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Manager[S]) timeout(ctx context.Context, correlationId string) error {
+	release := m.inFlight.Acquire(correlationId)
+	defer release()
+
+	now := m.now()
+	instance, err := m.store.Load(ctx, m.name, correlationId)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := m.flush(ctx, &instance); err != nil || instance.Completed {
+		return err
+	}
+
+	if instance.Deadline.IsZero() || instance.Deadline.After(now) {
+		return nil
+	}
+
+	e, err := event.MakeEvent("", TimedOut, Timeout{Saga: m.name, Deadline: instance.Deadline}, nil, event.WithCorrelationId(correlationId))
+	if err != nil {
+		return err
+	}
+
+	s := m.onTimeout
+	if s == nil {
+		s = func(c *Context[S], e event.Event) error {
+			c.Complete()
+			return nil
+		}
+	}
+
+	instance.Deadline = time.Time{}
+	return m.advance(ctx, instance, e, func(c *Context[S], e event.Event) error {
+		// Let other services know about the timeout before they receive compensating events.
+		c.emitted = append(c.emitted, e)
+		return s(c, e)
+	}, now)
+}
+
+// advance invokes the step on the instance, saves the instance's new state together
+// with emitted events and publishes them. Must be called with the instance acquired.
+func (m *Manager[S]) advance(ctx context.Context, instance Instance, e event.Event, s Step[S], now time.Time) error {
+	c := &Context[S]{
+		Context:       ctx,
+		CorrelationId: instance.CorrelationId,
+		cause:         e,
+		deadline:      instance.Deadline,
+		now:           now,
+	}
+
+	if len(instance.State) > 0 {
+		if err := json.Unmarshal(instance.State, &c.State); err != nil {
+			return err
+		}
+	}
+
+	if err := s(c, e); err != nil {
+		return err
+	}
+
+	state, err := json.Marshal(c.State)
+	if err != nil {
+		return err
+	}
+
+	instance.State = state
+	instance.Deadline = c.deadline
+	instance.UpdatedAt = now
+	instance.Pending = c.emitted
+	instance.LastEventId = e.Id
+	instance.Completed = c.completed
+
+	if c.completed {
+		// Completed instances never time out.
+		instance.Deadline = time.Time{}
+	}
+
+	if err := m.store.Save(ctx, instance); err != nil {
+		return err
+	}
+
+	return m.flush(ctx, &instance)
+}
+
+// flush publishes pending events of the instance. Events which were published are removed
+// from the instance, so that only the rest of them is published again if publishing fails.
+// Must be called with the instance acquired.
+func (m *Manager[S]) flush(ctx context.Context, instance *Instance) error {
+	if len(instance.Pending) == 0 {
+		return nil
+	}
+
+	for i, e := range instance.Pending {
+		if err := m.publisher.Publish(ctx, e); err != nil {
+			instance.Pending = instance.Pending[i:]
+			return errors.Join(err, m.store.Save(ctx, *instance))
+		}
+	}
+	instance.Pending = nil
+
+	return m.store.Save(ctx, *instance)
+}
@@ -0,0 +1,256 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+var errTest = errors.New("test")
+
+// publisher records published events.
+type publisher struct {
+	mu     sync.Mutex
+	events []event.Event
+	fail   int // Number of publishes to fail.
+}
+
+func (p *publisher) Publish(ctx context.Context, e event.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.fail > 0 {
+		p.fail--
+		return errTest
+	}
+
+	p.events = append(p.events, e)
+	return nil
+}
+
+func (p *publisher) ResilientPublish(e event.Event) error {
+	return p.Publish(context.Background(), e)
+}
+
+func (p *publisher) types() []event.EventType {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	types := []event.EventType{}
+	for _, e := range p.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+type deletionState struct {
+	UserId   string `json:"user_id"`
+	Articles bool   `json:"articles"`
+}
+
+func newDeletionSaga(store Store, pub event.Publisher, opts ...Option) *Manager[deletionState] {
+	return New[deletionState]("user_deletion", store, pub, opts...).
+		StartedBy(event.UserDeleted, func(c *Context[deletionState], e event.Event) error {
+			c.State.UserId = e.AggregateInstanceId
+			return c.Emit(event.ArticleAggregate, event.ArticleDeleted, c.State.UserId)
+		}).
+		On(event.ArticleDeleted, func(c *Context[deletionState], e event.Event) error {
+			c.State.Articles = true
+			if e.Metadata["fail"] != "" {
+				return errTest
+			}
+			c.Complete()
+			return nil
+		}).
+		OnTimeout(func(c *Context[deletionState], e event.Event) error {
+			c.Complete()
+			return c.Emit(event.UserAggregate, event.UserCreated, c.State.UserId)
+		})
+}
+
+func TestManager_HandleContext(t *testing.T) {
+	ctx := context.Background()
+	start := event.Event{Id: "1", CorrelationId: "1", Type: event.UserDeleted, AggregateInstanceId: "user"}
+
+	t.Run("Test if instance is started, moved forward and completed", func(t *testing.T) {
+		store, pub := NewMemoryStore(), &publisher{}
+		m := newDeletionSaga(store, pub)
+
+		if err := m.HandleContext(ctx, start); err != nil {
+			t.Errorf("Manager.HandleContext() error = %v", err)
+			return
+		}
+
+		instance, err := store.Load(ctx, "user_deletion", "1")
+		if err != nil {
+			t.Errorf("Store.Load() error = %v", err)
+			return
+		}
+
+		if want := `{"user_id":"user","articles":false}`; string(instance.State) != want {
+			t.Errorf("Saved state:\n got = %s\n want = %s", instance.State, want)
+			return
+		}
+
+		if len(pub.events) != 1 || pub.events[0].CorrelationId != "1" || pub.events[0].CausationId != "1" {
+			t.Errorf("Emitted events do not follow the handled event:\n got = %+v", pub.events)
+			return
+		}
+
+		if err := m.HandleContext(ctx, event.Event{Id: "2", CorrelationId: "1", Type: event.ArticleDeleted}); err != nil {
+			t.Errorf("Manager.HandleContext() error = %v", err)
+			return
+		}
+
+		instance, err = store.Load(ctx, "user_deletion", "1")
+		if err != nil || !instance.Completed {
+			t.Errorf("Instance was not completed:\n got = %+v\n error = %v", instance, err)
+			return
+		}
+
+		redelivered := start
+		redelivered.Id = "3"
+		if err := m.HandleContext(ctx, redelivered); err != nil {
+			t.Errorf("Manager.HandleContext() error = %v", err)
+			return
+		}
+
+		if got, want := pub.types(), []event.EventType{event.ArticleDeleted}; !slices.Equal(got, want) {
+			t.Errorf("Completed instance was started again:\n got = %v\n want = %v", got, want)
+		}
+	})
+
+	t.Run("Test if state is not saved when step fails", func(t *testing.T) {
+		store, pub := NewMemoryStore(), &publisher{}
+		m := newDeletionSaga(store, pub)
+
+		if err := m.HandleContext(ctx, start); err != nil {
+			t.Errorf("Manager.HandleContext() error = %v", err)
+			return
+		}
+
+		failing := event.Event{CorrelationId: "1", Type: event.ArticleDeleted, Metadata: map[string]string{"fail": "true"}}
+		if err := m.HandleContext(ctx, failing); !errors.Is(err, errTest) {
+			t.Errorf("Manager.HandleContext():\n error = %v\n want = %v", err, errTest)
+			return
+		}
+
+		instance, err := store.Load(ctx, "user_deletion", "1")
+		if err != nil {
+			t.Errorf("Store.Load() error = %v", err)
+			return
+		}
+
+		if want := `{"user_id":"user","articles":false}`; string(instance.State) != want {
+			t.Errorf("State of failed step was saved:\n got = %s\n want = %s", instance.State, want)
+		}
+	})
+
+	t.Run("Test if step is not applied again when its events failed to be published", func(t *testing.T) {
+		store, pub := NewMemoryStore(), &publisher{fail: 1}
+		m := newDeletionSaga(store, pub)
+
+		if err := m.HandleContext(ctx, start); !errors.Is(err, errTest) {
+			t.Errorf("Manager.HandleContext():\n error = %v\n want = %v", err, errTest)
+			return
+		}
+
+		instance, err := store.Load(ctx, "user_deletion", "1")
+		if err != nil {
+			t.Errorf("Store.Load() error = %v", err)
+			return
+		}
+
+		if len(instance.Pending) != 1 {
+			t.Errorf("Emitted events were not saved with the state:\n got = %v\n want = %v", len(instance.Pending), 1)
+			return
+		}
+
+		// Retry of the event.
+		if err := m.HandleContext(ctx, start); err != nil {
+			t.Errorf("Manager.HandleContext() error = %v", err)
+			return
+		}
+
+		if got, want := pub.types(), []event.EventType{event.ArticleDeleted}; !slices.Equal(got, want) {
+			t.Errorf("Published events:\n got = %v\n want = %v", got, want)
+			return
+		}
+
+		instance, err = store.Load(ctx, "user_deletion", "1")
+		if err != nil {
+			t.Errorf("Store.Load() error = %v", err)
+			return
+		}
+
+		if len(instance.Pending) != 0 {
+			t.Errorf("Published events were not removed from the instance: %v", instance.Pending)
+		}
+	})
+
+	t.Run("Test if events not belonging to any instance are ignored", func(t *testing.T) {
+		store, pub := NewMemoryStore(), &publisher{}
+		m := newDeletionSaga(store, pub)
+
+		if err := m.HandleContext(ctx, event.Event{CorrelationId: "2", Type: event.ArticleDeleted}); err != nil {
+			t.Errorf("Manager.HandleContext() error = %v", err)
+			return
+		}
+
+		if _, err := store.Load(ctx, "user_deletion", "2"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Instance was started by a non-starting event:\n error = %v\n want = %v", err, ErrNotFound)
+		}
+	})
+}
+
+func TestManager_HandleTimeouts(t *testing.T) {
+	t.Run("Test if timed out instances publish timeout and compensating events", func(t *testing.T) {
+		ctx := context.Background()
+		store, pub := NewMemoryStore(), &publisher{}
+
+		now := time.Now()
+		m := newDeletionSaga(store, pub, WithTimeout(time.Minute))
+		m.now = func() time.Time { return now }
+
+		if err := m.HandleContext(ctx, event.Event{Id: "1", CorrelationId: "1", Type: event.UserDeleted, AggregateInstanceId: "user"}); err != nil {
+			t.Errorf("Manager.HandleContext() error = %v", err)
+			return
+		}
+
+		if err := m.HandleTimeouts(ctx); err != nil {
+			t.Errorf("Manager.HandleTimeouts() error = %v", err)
+			return
+		}
+
+		if got, want := pub.types(), []event.EventType{event.ArticleDeleted}; !slices.Equal(got, want) {
+			t.Errorf("Instance timed out before its deadline:\n got = %v\n want = %v", got, want)
+			return
+		}
+
+		now = now.Add(time.Minute)
+		if err := m.HandleTimeouts(ctx); err != nil {
+			t.Errorf("Manager.HandleTimeouts() error = %v", err)
+			return
+		}
+
+		if got, want := pub.types(), []event.EventType{event.ArticleDeleted, TimedOut, event.UserCreated}; !slices.Equal(got, want) {
+			t.Errorf("Published events:\n got = %v\n want = %v", got, want)
+			return
+		}
+
+		timeout, err := event.Decode[Timeout](pub.events[1])
+		if err != nil || timeout.Saga != "user_deletion" || pub.events[1].CorrelationId != "1" {
+			t.Errorf("Invalid timeout event:\n got = %+v\n error = %v", pub.events[1], err)
+			return
+		}
+
+		if instance, err := store.Load(ctx, "user_deletion", "1"); err != nil || !instance.Completed {
+			t.Errorf("Timed out instance was not completed:\n got = %+v\n error = %v", instance, err)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+var ErrNotFound = errors.New("saga instance not found")
+
+// Instance is the persisted state of a single run of a saga.
+type Instance struct {
+	Saga          string          `json:"saga"`
+	CorrelationId string          `json:"correlation_id"`
+	State         json.RawMessage `json:"state,omitempty"`
+	Deadline      time.Time       `json:"deadline,omitempty"` // Zero means the instance never times out.
+	StartedAt     time.Time       `json:"started_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+
+	// Pending are events emitted by the last step which were not published yet.
+	// They are saved together with the state, so that they are published even if publishing fails.
+	Pending []event.Event `json:"pending,omitempty"`
+	// LastEventId is the ID of the event handled by the last step.
+	// Redeliveries of the event are not handled again.
+	LastEventId string `json:"last_event_id,omitempty"`
+	// Completed is set once the instance is completed. Events of completed instances are ignored.
+	Completed bool `json:"completed,omitempty"`
+}
+
+// Store persists instances of sagas. Completed instances are kept, so that redelivered events
+// of their correlation are ignored, and may be removed using Delete once redeliveries are no longer expected.
+type Store interface {
+	// Load returns the instance of the saga with given correlation ID
+	// and ErrNotFound if there is no such instance.
+	Load(ctx context.Context, saga, correlationId string) (Instance, error)
+
+	// Save creates or replaces the instance.
+	Save(ctx context.Context, instance Instance) error
+
+	// Delete removes the instance of the saga with given correlation ID.
+	// Deleting an instance which does not exist is not an error.
+	Delete(ctx context.Context, saga, correlationId string) error
+
+	// Expired returns up to limit instances of the saga whose deadline is not after given time,
+	// ordered from the earliest deadline.
+	Expired(ctx context.Context, saga string, now time.Time, limit int) ([]Instance, error)
+}
@@ -0,0 +1,225 @@
+// Package scheduler implements an in-process event.Scheduler,
+// meant for tests and for services running without RabbitMQ.
+// Scheduled events are kept in memory and are lost when the process exits.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/logging"
+	"github.com/krixlion/dev_forum-lib/nulls"
+)
+
+var _ event.Scheduler = (*TimerWheel)(nil)
+
+var ErrNoId = errors.New("scheduled event has no ID")
+
+// TimerWheel is an event.Scheduler publishing events using a hashed timer wheel.
+// Every tick the wheel advances by one slot and publishes the due events stored in it.
+// Events are published at most one tick late, scheduling and cancelling take constant time.
+//
+//	s := scheduler.NewTimerWheel(broker.NewMemoryBroker())
+//	go s.Run(ctx)
+//
+//	s.PublishAfter(ctx, e, time.Hour*24)
+type TimerWheel struct {
+	publisher event.Publisher
+	opts      options
+
+	mu     sync.Mutex
+	slots  []map[string]*timer // Timers keyed by event IDs.
+	timers map[string]*timer   // All timers keyed by event IDs.
+	cursor int                 // Index of the slot visited during the last tick.
+}
+
+type timer struct {
+	event  event.Event
+	slot   int
+	rounds int // Number of full turns of the wheel left until the event is due.
+}
+
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(opts *options)
+
+func (fn optionFunc) apply(opts *options) {
+	fn(opts)
+}
+
+type options struct {
+	tick   time.Duration
+	slots  int
+	logger logging.Logger
+}
+
+func defaultOptions() options {
+	return options{
+		tick:   time.Millisecond * 100,
+		slots:  512,
+		logger: nulls.NullLogger{},
+	}
+}
+
+// WithTick sets the precision of the wheel. Defaults to 100ms.
+// Non-positive ticks are ignored.
+func WithTick(tick time.Duration) Option {
+	return optionFunc(func(opts *options) {
+		if tick > 0 {
+			opts.tick = tick
+		}
+	})
+}
+
+// WithSlots sets the number of slots of the wheel. Defaults to 512.
+// Events scheduled further than the number of slots times the tick
+// stay in their slot for more than one turn of the wheel.
+// Non-positive numbers are ignored.
+func WithSlots(slots int) Option {
+	return optionFunc(func(opts *options) {
+		if slots > 0 {
+			opts.slots = slots
+		}
+	})
+}
+
+func WithLogger(logger logging.Logger) Option {
+	return optionFunc(func(opts *options) {
+		opts.logger = logger
+	})
+}
+
+// NewTimerWheel returns a scheduler publishing due events using the publisher.
+// The wheel does not advance until Run is called.
+func NewTimerWheel(publisher event.Publisher, opts ...Option) *TimerWheel {
+	w := &TimerWheel{
+		publisher: publisher,
+		opts:      defaultOptions(),
+		timers:    make(map[string]*timer),
+	}
+
+	for _, opt := range opts {
+		opt.apply(&w.opts)
+	}
+
+	w.slots = make([]map[string]*timer, w.opts.slots)
+	for i := range w.slots {
+		w.slots[i] = make(map[string]*timer)
+	}
+
+	return w
+}
+
+// Run blocks until the context is cancelled.
+// Run advances the wheel every tick and publishes due events.
+func (w *TimerWheel) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.advance(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// PublishAt schedules the event to be published at given time.
+// Events scheduled in the past are published immediately.
+// Scheduling an event with the ID of an already scheduled event replaces it.
+func (w *TimerWheel) PublishAt(ctx context.Context, e event.Event, at time.Time) error {
+	return w.PublishAfter(ctx, e, time.Until(at))
+}
+
+// PublishAfter schedules the event to be published after given delay, rounded up to the wheel's tick.
+func (w *TimerWheel) PublishAfter(ctx context.Context, e event.Event, delay time.Duration) error {
+	if e.Id == "" {
+		return ErrNoId
+	}
+
+	if delay <= 0 {
+		return w.publisher.Publish(ctx, e)
+	}
+
+	ticks := int((delay + w.opts.tick - 1) / w.opts.tick)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.remove(e.Id)
+
+	t := &timer{
+		event:  e,
+		slot:   (w.cursor + ticks) % len(w.slots),
+		rounds: (ticks - 1) / len(w.slots),
+	}
+	w.timers[e.Id] = t
+	w.slots[t.slot][e.Id] = t
+
+	return nil
+}
+
+// Cancel cancels publishing of the event with given ID.
+// Returns event.ErrNotScheduled if the event is not waiting to be published.
+func (w *TimerWheel) Cancel(ctx context.Context, id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.remove(id) {
+		return event.ErrNotScheduled
+	}
+
+	return nil
+}
+
+// Len returns the number of events waiting to be published.
+func (w *TimerWheel) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.timers)
+}
+
+// remove removes the timer of the event with given ID and reports whether it existed.
+// Must be called with the mutex held.
+func (w *TimerWheel) remove(id string) bool {
+	t, ok := w.timers[id]
+	if !ok {
+		return false
+	}
+
+	delete(w.timers, id)
+	delete(w.slots[t.slot], id)
+	return true
+}
+
+// advance moves the wheel to the next slot and publishes events which are due.
+func (w *TimerWheel) advance(ctx context.Context) {
+	w.mu.Lock()
+	w.cursor = (w.cursor + 1) % len(w.slots)
+
+	due := []event.Event{}
+	for id, t := range w.slots[w.cursor] {
+		if t.rounds > 0 {
+			t.rounds--
+			continue
+		}
+
+		due = append(due, t.event)
+		w.remove(id)
+	}
+	w.mu.Unlock()
+
+	for _, e := range due {
+		if err := w.publisher.Publish(ctx, e); err != nil {
+			w.opts.logger.Log(ctx, "Failed to publish scheduled event", "err", err, "id", e.Id, "type", e.Type)
+		}
+	}
+}
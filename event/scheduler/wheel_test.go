@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+)
+
+// publisher records IDs of published events.
+type publisher struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (p *publisher) Publish(ctx context.Context, e event.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ids = append(p.ids, e.Id)
+	return nil
+}
+
+func (p *publisher) ResilientPublish(e event.Event) error {
+	return p.Publish(context.Background(), e)
+}
+
+func (p *publisher) published() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ids)
+}
+
+func TestTimerWheel_PublishAfter(t *testing.T) {
+	tests := []struct {
+		desc      string
+		delay     time.Duration
+		wantTicks int
+	}{
+		{
+			desc:      "Test if event is published after its delay",
+			delay:     time.Millisecond * 3,
+			wantTicks: 3,
+		},
+		{
+			desc:      "Test if delay is rounded up to the tick",
+			delay:     time.Microsecond * 2500,
+			wantTicks: 3,
+		},
+		{
+			desc:      "Test if event scheduled further than one turn of the wheel waits for the following turns",
+			delay:     time.Millisecond * 10,
+			wantTicks: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			ctx := context.Background()
+			p := &publisher{}
+			w := NewTimerWheel(p, WithTick(time.Millisecond), WithSlots(4))
+
+			if err := w.PublishAfter(ctx, event.Event{Id: "1"}, tt.delay); err != nil {
+				t.Errorf("TimerWheel.PublishAfter() error = %v", err)
+				return
+			}
+
+			for i := 1; i < tt.wantTicks; i++ {
+				w.advance(ctx)
+			}
+
+			if p.published() != 0 {
+				t.Errorf("Event was published too early")
+				return
+			}
+
+			w.advance(ctx)
+
+			if p.published() != 1 || w.Len() != 0 {
+				t.Errorf("Event was not published on time:\n published = %v\n scheduled = %v", p.published(), w.Len())
+			}
+		})
+	}
+}
+
+func TestTimerWheel_PublishAt(t *testing.T) {
+	t.Run("Test if event scheduled in the past is published immediately", func(t *testing.T) {
+		p := &publisher{}
+		w := NewTimerWheel(p)
+
+		if err := w.PublishAt(context.Background(), event.Event{Id: "1"}, time.Now().Add(-time.Second)); err != nil {
+			t.Errorf("TimerWheel.PublishAt() error = %v", err)
+			return
+		}
+
+		if p.published() != 1 {
+			t.Errorf("Event was not published immediately")
+		}
+	})
+
+	t.Run("Test if event without ID is rejected", func(t *testing.T) {
+		w := NewTimerWheel(&publisher{})
+
+		if err := w.PublishAt(context.Background(), event.Event{}, time.Now().Add(time.Second)); !errors.Is(err, ErrNoId) {
+			t.Errorf("TimerWheel.PublishAt():\n error = %v\n wantErr = %v", err, ErrNoId)
+		}
+	})
+}
+
+func TestTimerWheel_Cancel(t *testing.T) {
+	t.Run("Test if cancelled event is not published", func(t *testing.T) {
+		ctx := context.Background()
+		p := &publisher{}
+		w := NewTimerWheel(p, WithTick(time.Millisecond), WithSlots(4))
+
+		if err := w.PublishAfter(ctx, event.Event{Id: "1"}, time.Millisecond); err != nil {
+			t.Errorf("TimerWheel.PublishAfter() error = %v", err)
+			return
+		}
+
+		if err := w.Cancel(ctx, "1"); err != nil {
+			t.Errorf("TimerWheel.Cancel() error = %v", err)
+			return
+		}
+
+		for i := 0; i < 8; i++ {
+			w.advance(ctx)
+		}
+
+		if p.published() != 0 {
+			t.Errorf("Cancelled event was published")
+			return
+		}
+
+		if err := w.Cancel(ctx, "1"); !errors.Is(err, event.ErrNotScheduled) {
+			t.Errorf("TimerWheel.Cancel():\n error = %v\n wantErr = %v", err, event.ErrNotScheduled)
+		}
+	})
+}
+
+func TestTimerWheel_Run(t *testing.T) {
+	t.Run("Test if running wheel publishes due events", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		p := &publisher{}
+		w := NewTimerWheel(p, WithTick(time.Millisecond))
+		go w.Run(ctx)
+
+		if err := w.PublishAfter(ctx, event.Event{Id: "1"}, time.Millisecond*5); err != nil {
+			t.Errorf("TimerWheel.PublishAfter() error = %v", err)
+			return
+		}
+
+		for p.published() == 0 {
+			select {
+			case <-ctx.Done():
+				t.Errorf("Event was not published")
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	})
+}
+
+func TestNewTimerWheel(t *testing.T) {
+	t.Run("Test if non-positive tick and number of slots fall back to the defaults", func(t *testing.T) {
+		w := NewTimerWheel(&publisher{}, WithTick(0), WithSlots(0))
+
+		want := defaultOptions()
+		if w.opts.tick != want.tick || len(w.slots) != want.slots {
+			t.Errorf("NewTimerWheel():\n got = %v, %v\n want = %v, %v", w.opts.tick, len(w.slots), want.tick, want.slots)
+			return
+		}
+
+		if err := w.PublishAfter(context.Background(), event.Event{Id: "1"}, time.Second); err != nil {
+			t.Errorf("TimerWheel.PublishAfter() error = %v", err)
+		}
+	})
+}
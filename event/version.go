@@ -0,0 +1,92 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrMissingUpcaster = errors.New("missing upcaster")
+
+// Upcaster converts an event from one schema version to the next one.
+// It should only change the body of the event, the version is updated by Upcast.
+type Upcaster func(Event) (Event, error)
+
+// RegisterUpcaster registers an upcaster converting events of the given type
+// from the given version to the next one. Every registered upcaster bumps
+// the latest version of the event type, so that newly made events are
+// stamped with the version the upcasters lead to.
+//
+//	func init() {
+//		// Version 1 stored the title under "name".
+//		event.RegisterUpcaster(event.ArticleUpdated, 1, func(e event.Event) (event.Event, error) {
+//			body := map[string]any{}
+//			if err := json.Unmarshal(e.Body, &body); err != nil {
+//				return event.Event{}, err
+//			}
+//			body["title"] = body["name"]
+//			delete(body, "name")
+//
+//			data, err := json.Marshal(body)
+//			if err != nil {
+//				return event.Event{}, err
+//			}
+//			e.Body = data
+//			return e, nil
+//		})
+//	}
+func RegisterUpcaster(eType EventType, fromVersion int, upcaster Upcaster) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.upcasters[eType]; !ok {
+		registry.upcasters[eType] = make(map[int]Upcaster)
+	}
+	registry.upcasters[eType][fromVersion] = upcaster
+}
+
+// LatestVersion returns the latest schema version of the event type,
+// which is one more than the highest version an upcaster is registered for.
+// Event types without any upcasters are at version 1.
+func LatestVersion(eType EventType) int {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	latest := 1
+	for from := range registry.upcasters[eType] {
+		latest = max(latest, from+1)
+	}
+
+	return latest
+}
+
+// Upcast applies registered upcasters to the event until it reaches the latest version of its type.
+// Events without a version are treated as version 1. Events with a version newer than
+// the latest known one are returned unchanged. Returns ErrMissingUpcaster
+// if there is a gap in the registered upcasters.
+func Upcast(e Event) (Event, error) {
+	if e.Version == 0 {
+		e.Version = 1
+	}
+
+	latest := LatestVersion(e.Type)
+	for e.Version < latest {
+		registry.mu.RLock()
+		upcaster, ok := registry.upcasters[e.Type][e.Version]
+		registry.mu.RUnlock()
+
+		if !ok {
+			return Event{}, fmt.Errorf("%w: %q from version %d", ErrMissingUpcaster, e.Type, e.Version)
+		}
+
+		version := e.Version
+		upcasted, err := upcaster(e)
+		if err != nil {
+			return Event{}, fmt.Errorf("failed to upcast %q from version %d: %w", e.Type, version, err)
+		}
+
+		e = upcasted
+		e.Version = version + 1
+	}
+
+	return e, nil
+}
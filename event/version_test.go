@@ -0,0 +1,98 @@
+package event
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testUpcasted EventType = "test_versioned-upcasted"
+	testGap      EventType = "test_versioned-gap"
+)
+
+func init() {
+	appendVersion := func(e Event) (Event, error) {
+		e.Body = append(e.Body, byte('0'+e.Version))
+		return e, nil
+	}
+
+	RegisterUpcaster(testUpcasted, 1, appendVersion)
+	RegisterUpcaster(testUpcasted, 2, appendVersion)
+
+	RegisterUpcaster(testGap, 2, appendVersion)
+}
+
+func TestLatestVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		eType EventType
+		want  int
+	}{
+		{
+			name:  "Test if returns 1 for event type without upcasters",
+			eType: ArticleCreated,
+			want:  1,
+		},
+		{
+			name:  "Test if returns version following the last upcaster",
+			eType: testUpcasted,
+			want:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LatestVersion(tt.eType); got != tt.want {
+				t.Errorf("LatestVersion():\n got = %v\n want = %v\n", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpcast(t *testing.T) {
+	tests := []struct {
+		name    string
+		arg     Event
+		want    Event
+		wantErr error
+	}{
+		{
+			name: "Test if upcasters are applied in order from the event's version",
+			arg:  Event{Type: testUpcasted, Body: []byte("v"), Version: 1},
+			want: Event{Type: testUpcasted, Body: []byte("v12"), Version: 3},
+		},
+		{
+			name: "Test if event without a version is treated as version 1",
+			arg:  Event{Type: testUpcasted, Body: []byte("v")},
+			want: Event{Type: testUpcasted, Body: []byte("v12"), Version: 3},
+		},
+		{
+			name: "Test if event at the latest version is returned unchanged",
+			arg:  Event{Type: testUpcasted, Body: []byte("v"), Version: 3},
+			want: Event{Type: testUpcasted, Body: []byte("v"), Version: 3},
+		},
+		{
+			name:    "Test if returns ErrMissingUpcaster on a gap in upcasters",
+			arg:     Event{Type: testGap, Body: []byte("v"), Version: 1},
+			wantErr: ErrMissingUpcaster,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Upcast(tt.arg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Upcast():\n error = %v\n wantErr = %v\n", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("Upcast():\n got = %+v\n want = %+v\n", got, tt.want)
+			}
+		})
+	}
+}
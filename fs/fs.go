@@ -29,6 +29,17 @@ func Create(name string) (afero.File, error) {
 	return fileSystem.Create(name)
 }
 
+// OpenFile opens a file using the given flags and the given mode,
+// returning it or an error, if any happens.
+func OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return fileSystem.OpenFile(name, flag, perm)
+}
+
+// Rename renames a file, returning an error, if any happens.
+func Rename(oldname, newname string) error {
+	return fileSystem.Rename(oldname, newname)
+}
+
 // MkdirAll creates a directory path and all parents that does not exist
 // yet.
 func MkdirAll(path string, perm os.FileMode) error {
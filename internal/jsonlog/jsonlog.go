@@ -0,0 +1,207 @@
+// Package jsonlog implements an append-only log file keeping one JSON encoded entry per line.
+// It backs the file stores of the event packages and uses the lib/fs package
+// allowing to mock it out in tests.
+package jsonlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/krixlion/dev_forum-lib/fs"
+	"github.com/spf13/afero"
+)
+
+// Log is an append-only log of entries of type T.
+// It is not safe for concurrent use, callers are expected to synchronize access.
+type Log[T any] struct {
+	path string
+	file afero.File
+	size int64 // Size of the complete entries in the file.
+	err  error // Set when the file could not be restored after a failed write.
+}
+
+// Open opens the log under the given path, creating it if it does not exist,
+// and passes all entries to load in the order they were appended.
+// A trailing line without a newline is an entry which was not fully written,
+// eg. because of a crash, and is dropped from the file.
+// The log should be closed in order to release the file.
+func Open[T any](path string, load func(T) error) (*Log[T], error) {
+	size, err := read(path, load)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := openAt(path, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log[T]{
+		path: path,
+		file: file,
+		size: size,
+	}, nil
+}
+
+// read loads the entries and returns the size of the complete lines.
+func read[T any](path string, load func(T) error) (int64, error) {
+	file, err := fs.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var size int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var entry T
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return 0, fmt.Errorf("log %s is corrupted at offset %d: %w", path, size, err)
+		}
+
+		if err := load(entry); err != nil {
+			return 0, err
+		}
+
+		size += int64(len(line))
+	}
+}
+
+// openAt opens the file for appending and truncates it to the given size.
+func openAt(path string, size int64) (afero.File, error) {
+	file, err := fs.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := truncate(file, size); err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+
+	return file, nil
+}
+
+func truncate(file afero.File, size int64) error {
+	if err := file.Truncate(size); err != nil {
+		return err
+	}
+
+	// Files opened for appending are not guaranteed to write at the end after being truncated.
+	_, err := file.Seek(size, io.SeekStart)
+	return err
+}
+
+// Append writes the entries in a single write and syncs the file.
+// If either fails the file is truncated back to its previous size, so that
+// entries which were not appended are not loaded when the log is reopened.
+// If the file cannot be restored every following Append fails.
+func (l *Log[T]) Append(entries ...T) error {
+	if l.err != nil {
+		return l.err
+	}
+
+	buf, err := encode(entries)
+	if err != nil {
+		return err
+	}
+
+	if err := write(l.file, buf); err != nil {
+		if truncErr := truncate(l.file, l.size); truncErr != nil {
+			l.err = fmt.Errorf("log %s is in an unknown state: %w", l.path, truncErr)
+			return errors.Join(err, l.err)
+		}
+		return err
+	}
+
+	l.size += int64(len(buf))
+	return nil
+}
+
+// Rewrite replaces all entries in the log with the given ones.
+// The entries are written to a temporary file which is then renamed over the log,
+// so that the log keeps its previous entries if rewriting fails.
+func (l *Log[T]) Rewrite(entries ...T) error {
+	buf, err := encode(entries)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := l.path + ".tmp"
+	tmp, err := fs.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if err := write(tmp, buf); err != nil {
+		return errors.Join(err, tmp.Close())
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+
+	if err := fs.Rename(tmpPath, l.path); err != nil {
+		// Keep using the previous file.
+		return errors.Join(err, l.reopen(l.size))
+	}
+
+	return l.reopen(int64(len(buf)))
+}
+
+func (l *Log[T]) reopen(size int64) error {
+	file, err := openAt(l.path, size)
+	if err != nil {
+		l.err = fmt.Errorf("log %s could not be reopened: %w", l.path, err)
+		return l.err
+	}
+
+	l.file = file
+	l.size = size
+	l.err = nil
+	return nil
+}
+
+// Close closes the underlying file.
+func (l *Log[T]) Close() error {
+	return l.file.Close()
+}
+
+func encode[T any](entries []T) ([]byte, error) {
+	buf := []byte{}
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+	return buf, nil
+}
+
+func write(file afero.File, buf []byte) error {
+	if _, err := file.Write(buf); err != nil {
+		return err
+	}
+	return file.Sync()
+}
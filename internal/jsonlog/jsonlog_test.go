@@ -0,0 +1,124 @@
+package jsonlog
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/krixlion/dev_forum-lib/fs"
+	"github.com/spf13/afero"
+)
+
+type entry struct {
+	Id string `json:"id"`
+}
+
+func setUpLog(t *testing.T, path string) (*Log[entry], []entry) {
+	entries := []entry{}
+	log, err := Open(path, func(e entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+	return log, entries
+}
+
+func TestLog(t *testing.T) {
+	t.Run("Test if entries survive reopening the log", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+
+		log, _ := setUpLog(t, "test.log")
+		if err := log.Append(entry{Id: "1"}, entry{Id: "2"}); err != nil {
+			t.Errorf("Log.Append() error = %v", err)
+			return
+		}
+		log.Close()
+
+		_, got := setUpLog(t, "test.log")
+		if want := []entry{{Id: "1"}, {Id: "2"}}; !cmp.Equal(got, want) {
+			t.Errorf("Open():\n got = %+v\n want = %+v", got, want)
+		}
+	})
+
+	t.Run("Test if an incomplete trailing entry is dropped", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+
+		log, _ := setUpLog(t, "test.log")
+		if err := log.Append(entry{Id: "1"}); err != nil {
+			t.Errorf("Log.Append() error = %v", err)
+			return
+		}
+		log.Close()
+
+		file, err := fs.OpenFile("test.log", os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Errorf("fs.OpenFile() error = %v", err)
+			return
+		}
+		if _, err := file.Write([]byte(`{"id":"to`)); err != nil {
+			t.Errorf("File.Write() error = %v", err)
+			return
+		}
+		file.Close()
+
+		log, _ = setUpLog(t, "test.log")
+		if err := log.Append(entry{Id: "2"}); err != nil {
+			t.Errorf("Log.Append() error = %v", err)
+			return
+		}
+		log.Close()
+
+		_, got := setUpLog(t, "test.log")
+		if want := []entry{{Id: "1"}, {Id: "2"}}; !cmp.Equal(got, want) {
+			t.Errorf("Open():\n got = %+v\n want = %+v", got, want)
+		}
+	})
+
+	t.Run("Test if a corrupted entry is reported", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+
+		file, err := fs.Create("test.log")
+		if err != nil {
+			t.Errorf("fs.Create() error = %v", err)
+			return
+		}
+		if _, err := file.Write([]byte("{\"id\":\n{\"id\":\"2\"}\n")); err != nil {
+			t.Errorf("File.Write() error = %v", err)
+			return
+		}
+		file.Close()
+
+		if _, err := Open("test.log", func(entry) error { return nil }); err == nil {
+			t.Errorf("Open() error = nil, want an error")
+		}
+	})
+
+	t.Run("Test if Rewrite replaces entries", func(t *testing.T) {
+		fs.SetGlobalFileSystem(afero.NewMemMapFs())
+
+		log, _ := setUpLog(t, "test.log")
+		if err := log.Append(entry{Id: "1"}, entry{Id: "2"}); err != nil {
+			t.Errorf("Log.Append() error = %v", err)
+			return
+		}
+
+		if err := log.Rewrite(entry{Id: "2"}); err != nil {
+			t.Errorf("Log.Rewrite() error = %v", err)
+			return
+		}
+
+		if err := log.Append(entry{Id: "3"}); err != nil {
+			t.Errorf("Log.Append() error = %v", err)
+			return
+		}
+		log.Close()
+
+		_, got := setUpLog(t, "test.log")
+		if want := []entry{{Id: "2"}, {Id: "3"}}; !cmp.Equal(got, want) {
+			t.Errorf("Open():\n got = %+v\n want = %+v", got, want)
+		}
+	})
+}
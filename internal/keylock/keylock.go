@@ -0,0 +1,41 @@
+// Package keylock implements mutual exclusion of callers working on the same key,
+// eg. handlers of events with the same ID or of the same saga instance.
+package keylock
+
+import "sync"
+
+// Locks allows one holder per key at a time. The zero value is ready to use.
+type Locks struct {
+	mu   sync.Mutex
+	held map[string]chan struct{} // Closed when the key is released.
+}
+
+// Acquire blocks until no one else holds the key.
+// The returned func has to be called to release the key.
+func (l *Locks) Acquire(key string) (release func()) {
+	l.mu.Lock()
+	for {
+		done, ok := l.held[key]
+		if !ok {
+			break
+		}
+		l.mu.Unlock()
+		<-done
+		l.mu.Lock()
+	}
+
+	if l.held == nil {
+		l.held = make(map[string]chan struct{})
+	}
+
+	done := make(chan struct{})
+	l.held[key] = done
+	l.mu.Unlock()
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.held, key)
+		close(done)
+	}
+}
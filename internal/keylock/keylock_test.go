@@ -0,0 +1,56 @@
+package keylock
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLocks_Acquire(t *testing.T) {
+	t.Run("Test if holders of the same key are mutually exclusive", func(t *testing.T) {
+		var locks Locks
+		var wg sync.WaitGroup
+		holders, maxHolders := 0, 0
+		var mu sync.Mutex
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release := locks.Acquire("1")
+				defer release()
+
+				mu.Lock()
+				holders++
+				maxHolders = max(maxHolders, holders)
+				mu.Unlock()
+
+				mu.Lock()
+				holders--
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if maxHolders != 1 {
+			t.Errorf("Locks.Acquire() concurrent holders:\n got = %v\n want = %v", maxHolders, 1)
+		}
+	})
+
+	t.Run("Test if different keys do not block each other", func(t *testing.T) {
+		var locks Locks
+		release := locks.Acquire("1")
+		defer release()
+
+		locks.Acquire("2")()
+	})
+
+	t.Run("Test if released keys can be acquired again", func(t *testing.T) {
+		var locks Locks
+		locks.Acquire("1")()
+		locks.Acquire("1")()
+
+		if len(locks.held) != 0 {
+			t.Errorf("Locks.Acquire() held keys:\n got = %v\n want = %v", len(locks.held), 0)
+		}
+	})
+}
@@ -7,7 +7,11 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-var _ event.Broker = (*Broker)(nil)
+var (
+	_ event.Broker           = (*Broker)(nil)
+	_ event.DeliveryConsumer = (*Broker)(nil)
+	_ event.PatternConsumer  = (*Broker)(nil)
+)
 
 type Broker struct {
 	*mock.Mock
@@ -24,6 +28,16 @@ func (m Broker) Consume(ctx context.Context, queue string, eventType event.Event
 	return args.Get(0).(<-chan event.Event), args.Error(1)
 }
 
+func (m Broker) ConsumeDeliveries(ctx context.Context, queue string, eventType event.EventType) (<-chan event.Delivery, error) {
+	args := m.Called(ctx, queue, eventType)
+	return args.Get(0).(<-chan event.Delivery), args.Error(1)
+}
+
+func (m Broker) ConsumePattern(ctx context.Context, queue string, pattern event.Pattern) (<-chan event.Event, error) {
+	args := m.Called(ctx, queue, pattern)
+	return args.Get(0).(<-chan event.Event), args.Error(1)
+}
+
 func (m Broker) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -4,7 +4,10 @@ import (
 	"context"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/krixlion/dev_forum-lib/nulls"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -16,10 +19,18 @@ type Option interface {
 	apply(*options)
 }
 
+const (
+	defaultReconnectInterval    = time.Second * 2
+	defaultMaxReconnectInterval = time.Second * 30
+)
+
 type Config struct {
-	QueueSize         int           // Max number of messages internally queued for publishing.
-	MaxWorkers        int           // Max number of concurrent workers per operation type.
-	ReconnectInterval time.Duration // Time between reconnect attempts.
+	QueueSize            int           // Max number of messages internally queued for publishing.
+	MaxWorkers           int           // Max number of concurrent workers per operation type.
+	ReconnectInterval    time.Duration // Initial time between reconnect attempts, growing exponentially, 0 means 2s.
+	MaxReconnectInterval time.Duration // Max time between reconnect attempts, 0 means 30s.
+	PrefetchCount        int           // Max number of unacknowledged deliveries per consumer, 0 means no limit.
+	ChannelPoolSize      int           // Max number of pooled channels used for publishing and declaring, 0 means MaxWorkers.
 
 	// Settings for the internal circuit breaker.
 	MaxRequests   uint32        // Number of requests allowed to half-open state.
@@ -29,13 +40,90 @@ type Config struct {
 
 func DefaultConfig() Config {
 	return Config{
-		QueueSize:         100,              // Max number of messages internally queued for publishing.
-		MaxWorkers:        30,               // Max number of concurrent workers per operation type.
-		ReconnectInterval: time.Second * 2,  // Time between reconnect attempts.
-		MaxRequests:       10,               // Number of requests allowed to half-open state.
-		ClearInterval:     time.Second * 10, // Time after which failed calls count is cleared.
-		ClosedTimeout:     time.Second * 10, // Time after which closed state becomes half-open.
+		QueueSize:            100,                         // Max number of messages internally queued for publishing.
+		MaxWorkers:           30,                          // Max number of concurrent workers per operation type.
+		ReconnectInterval:    defaultReconnectInterval,    // Initial time between reconnect attempts, growing exponentially, 0 means 2s.
+		MaxReconnectInterval: defaultMaxReconnectInterval, // Max time between reconnect attempts, 0 means 30s.
+		PrefetchCount:        50,                          // Max number of unacknowledged deliveries per consumer, 0 means no limit.
+		ChannelPoolSize:      30,                          // Max number of pooled channels used for publishing and declaring, 0 means MaxWorkers.
+		MaxRequests:          10,                          // Number of requests allowed to half-open state.
+		ClearInterval:        time.Second * 10,            // Time after which failed calls count is cleared.
+		ClosedTimeout:        time.Second * 10,            // Time after which closed state becomes half-open.
+	}
+}
+
+// DeadLetterConfig configures dead-lettering of a single queue.
+type DeadLetterConfig struct {
+	Exchange      string // Name of the exchange dead-lettered messages are routed through, defaults to "dlx".
+	MaxDeliveries int    // Number of deliveries after which a requeued message is dead-lettered, 0 means no limit.
+}
+
+// WithDeadLetter enables dead-lettering for the queue with the given name.
+// Messages which are rejected or exceed the max delivery count are moved
+// to a dedicated "<queue>.dlq" queue instead of being discarded.
+//
+// The queue is declared with "x-dead-letter-exchange" and "x-dead-letter-routing-key" arguments,
+// which the broker refuses to add to a queue that already exists with PRECONDITION_FAILED.
+// Such a queue is used as it is and a warning is logged. Messages dead-lettered by the library
+// still reach the dead letter queue, but messages which expire or are rejected by other clients
+// are discarded. To migrate such a queue either delete it once it is drained, so that it is
+// redeclared with the arguments, or apply them using a policy, eg.:
+//
+//	rabbitmqctl set_policy <queue>-dlx '^<queue>$' '{"dead-letter-exchange":"dlx","dead-letter-routing-key":"<queue>"}' --apply-to queues
+func WithDeadLetter(queue string, config DeadLetterConfig) Option {
+	if config.Exchange == "" {
+		config.Exchange = "dlx"
+	}
+
+	return optionFunc(func(opts *options) {
+		opts.deadLetters[queue] = config
+	})
+}
+
+// WithPublisherConfirms puts publishing channels in confirm mode.
+// Every publish waits until the broker confirms the message and
+// returns ErrNack if the broker did not accept it.
+// Messages published through the resilient pipeline are enqueued again on a nack.
+func WithPublisherConfirms() Option {
+	return optionFunc(func(opts *options) {
+		opts.publisherConfirms = true
+	})
+}
+
+// WithScheduling enables publishing messages at a later time using PublishAt and PublishAfter.
+// Scheduled messages wait in delay queues until their TTL expires and are then dead-lettered
+// to the "<consumer>.scheduled" queue, from which they are forwarded to their exchanges.
+// Cancellations are stored for all instances in the "<consumer>.scheduled.cancelled" stream.
+// There are 20 delay queues, whose delays are the precision multiplied by consecutive powers of two.
+// Messages wait in the longest delay queue not exceeding their delay and move to the next one
+// until they are due, so they are published up to the precision late. A precision of 0 defaults to 1s.
+func WithScheduling(precision time.Duration) Option {
+	if precision <= 0 {
+		precision = time.Second
 	}
+
+	return optionFunc(func(opts *options) {
+		opts.scheduling = true
+		opts.schedulingPrecision = precision
+	})
+}
+
+// WithBackoff replaces the policy determining the time between reconnect attempts.
+// By default the interval grows exponentially with a 50% jitter from Config.ReconnectInterval
+// up to Config.MaxReconnectInterval. The policy is reset before reconnecting.
+// If the policy returns backoff.Stop the connection is closed.
+func WithBackoff(policy backoff.BackOff) Option {
+	return optionFunc(func(opts *options) {
+		opts.backoff = policy
+	})
+}
+
+// WithMeter sets the meter used to record the number of open, borrowed and replaced
+// pooled channels and the time spent waiting for a channel.
+func WithMeter(meter metric.Meter) Option {
+	return optionFunc(func(opts *options) {
+		opts.meter = meter
+	})
 }
 
 func WithTracer(tracer trace.Tracer) Option {
@@ -57,13 +145,23 @@ func (fn optionFunc) apply(opts *options) {
 }
 
 type options struct {
-	tracer trace.Tracer
-	logger Logger
+	tracer      trace.Tracer
+	meter       metric.Meter
+	logger      Logger
+	deadLetters map[string]DeadLetterConfig // Dead letter configs keyed by queue name.
+
+	publisherConfirms bool
+	backoff           backoff.BackOff // Policy for reconnect attempts, derived from Config unless set.
+
+	scheduling          bool
+	schedulingPrecision time.Duration
 }
 
 func defaultOptions() options {
 	return options{
-		tracer: nulls.NullTracer{},
-		logger: nulls.NullLogger{},
+		tracer:      nulls.NullTracer{},
+		meter:       noop.NewMeterProvider().Meter(""),
+		logger:      nulls.NullLogger{},
+		deadLetters: make(map[string]DeadLetterConfig),
 	}
 }
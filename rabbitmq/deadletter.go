@@ -0,0 +1,334 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/krixlion/dev_forum-lib/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers set on requeued and dead-lettered messages.
+const (
+	HeaderDeadLetterReason   = "x-dead-letter-reason"   // Reason why the message was dead-lettered.
+	HeaderOriginalExchange   = "x-original-exchange"    // Exchange the message was originally published to.
+	HeaderOriginalRoutingKey = "x-original-routing-key" // Routing key the message was originally published with.
+	HeaderDeliveryCount      = "x-delivery-count"       // Number of failed deliveries of the message.
+)
+
+// DeadLetter is a message stored in a dead letter queue.
+type DeadLetter struct {
+	Message // Route is the route the message was originally published with.
+
+	Reason string // Reason why the message was dead-lettered.
+}
+
+// DeadLetterQueueName returns the name of the queue storing messages dead-lettered from the given queue.
+func DeadLetterQueueName(queue string) string {
+	return queue + ".dlq"
+}
+
+// prepareDeadLetterQueue declares the dead letter exchange and queue if dead-lettering is
+// enabled for the given queue. It returns arguments the given queue should be declared with.
+func (mq *RabbitMQ) prepareDeadLetterQueue(ctx context.Context, queue string) (_ amqp.Table, err error) {
+	config, ok := mq.opts.deadLetters[queue]
+	if !ok {
+		return nil, nil
+	}
+
+	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.prepareDeadLetterQueue")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	dlxRoute := Route{
+		ExchangeName: config.Exchange,
+		ExchangeType: amqp.ExchangeDirect,
+		RoutingKey:   queue,
+	}
+
+	if err := mq.prepareExchange(ctx, dlxRoute); err != nil {
+		return nil, err
+	}
+
+	err = mq.withChannel(ctx, func(ch *amqp.Channel) error {
+		done, err := mq.breaker.Allow()
+		if err != nil {
+			return err
+		}
+
+		dlq, err := ch.QueueDeclare(DeadLetterQueueName(queue), true, false, false, false, nil)
+		if err != nil {
+			done(!isConnectionError(err))
+			return err
+		}
+
+		if err := ch.QueueBind(dlq.Name, dlxRoute.RoutingKey, dlxRoute.ExchangeName, false, nil); err != nil {
+			done(!isConnectionError(err))
+			return err
+		}
+		done(true)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return amqp.Table{
+		"x-dead-letter-exchange":    config.Exchange,
+		"x-dead-letter-routing-key": queue,
+	}, nil
+}
+
+// DeadLetter moves the message to the dead letter queue with the given reason stored in its headers.
+// If dead-lettering is not enabled for the queue the message was consumed from,
+// the message is rejected and discarded by the broker.
+func (d Delivery) DeadLetter(reason string) error {
+	config, ok := d.mq.opts.deadLetters[d.queue]
+	if !ok {
+		return d.delivery.Reject(false)
+	}
+
+	headers := d.originalRouteHeaders()
+	headers[HeaderDeadLetterReason] = reason
+
+	p := publishingFromDelivery(d.delivery, headers)
+	if err := d.mq.publishRaw(context.Background(), config.Exchange, d.queue, p); err != nil {
+		return err
+	}
+
+	return d.delivery.Ack(false)
+}
+
+// requeueOrDeadLetter requeues the message while tracking its delivery count.
+// The message is dead-lettered if it is not supposed to be requeued or if it exceeded the max delivery count.
+func (d Delivery) requeueOrDeadLetter(config DeadLetterConfig, requeue bool, reason string) error {
+	if !requeue {
+		return d.DeadLetter(reason)
+	}
+
+	if config.MaxDeliveries <= 0 {
+		return d.delivery.Nack(false, true)
+	}
+
+	if d.DeliveryCount >= config.MaxDeliveries {
+		return d.DeadLetter(fmt.Sprintf("exceeded max delivery count of %d", config.MaxDeliveries))
+	}
+
+	// The broker does not count requeues so the message is republished
+	// to the end of the queue with an incremented delivery count instead.
+	headers := d.originalRouteHeaders()
+	headers[HeaderDeliveryCount] = int64(d.DeliveryCount)
+
+	p := publishingFromDelivery(d.delivery, headers)
+	if err := d.mq.publishRaw(context.Background(), "", d.queue, p); err != nil {
+		return err
+	}
+
+	return d.delivery.Ack(false)
+}
+
+// originalRouteHeaders returns a copy of the delivery's headers
+// with the route the message was originally published with.
+func (d Delivery) originalRouteHeaders() amqp.Table {
+	headers := maps.Clone(d.delivery.Headers)
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+
+	if _, ok := headers[HeaderOriginalExchange]; !ok {
+		headers[HeaderOriginalExchange] = d.delivery.Exchange
+		headers[HeaderOriginalRoutingKey] = d.delivery.RoutingKey
+	}
+
+	return headers
+}
+
+// InspectDeadLetters returns up to limit messages stored in the dead letter queue of the given queue
+// without removing them from it.
+func (mq *RabbitMQ) InspectDeadLetters(ctx context.Context, queue string, limit int) (_ []DeadLetter, err error) {
+	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.InspectDeadLetters")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	// Inspected messages stay unacknowledged until all of them are fetched, so the channel is not pooled.
+	ch, err := mq.openChannel(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	deadLetters := []DeadLetter{}
+	var lastTag uint64
+
+	for len(deadLetters) < limit {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		delivery, ok, err := mq.getDeadLetter(ch, queue)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			break
+		}
+
+		lastTag = delivery.DeliveryTag
+		deadLetters = append(deadLetters, deadLetterFromDelivery(delivery))
+	}
+
+	if lastTag != 0 {
+		// Return all inspected messages to the queue.
+		if err := ch.Nack(lastTag, true, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters moves up to limit messages from the dead letter queue back to the given queue
+// and returns the number of replayed messages. Replayed messages have their delivery count reset.
+func (mq *RabbitMQ) ReplayDeadLetters(ctx context.Context, queue string, limit int) (_ int, err error) {
+	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.ReplayDeadLetters")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	// Messages are republished while being held, which requires another channel, so this one is not pooled.
+	ch, err := mq.openChannel(ctx, false)
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	replayed := 0
+	for replayed < limit {
+		if err := ctx.Err(); err != nil {
+			return replayed, err
+		}
+
+		delivery, ok, err := mq.getDeadLetter(ch, queue)
+		if err != nil {
+			return replayed, err
+		}
+
+		if !ok {
+			break
+		}
+
+		headers := maps.Clone(delivery.Headers)
+		delete(headers, HeaderDeadLetterReason)
+		delete(headers, HeaderDeliveryCount)
+
+		if err := mq.publishRaw(ctx, "", queue, publishingFromDelivery(delivery, headers)); err != nil {
+			return replayed, err
+		}
+
+		if err := delivery.Ack(false); err != nil {
+			return replayed, err
+		}
+
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func (mq *RabbitMQ) getDeadLetter(ch *amqp.Channel, queue string) (amqp.Delivery, bool, error) {
+	done, err := mq.breaker.Allow()
+	if err != nil {
+		return amqp.Delivery{}, false, err
+	}
+
+	delivery, ok, err := ch.Get(DeadLetterQueueName(queue), false)
+	if err != nil {
+		done(!isConnectionError(err))
+		return amqp.Delivery{}, false, err
+	}
+	done(true)
+
+	return delivery, ok, nil
+}
+
+// publishRaw publishes the message as is, without injecting tracing headers.
+func (mq *RabbitMQ) publishRaw(ctx context.Context, exchange, key string, p amqp.Publishing) error {
+	return mq.withChannel(ctx, func(ch *amqp.Channel) error {
+		done, err := mq.breaker.Allow()
+		if err != nil {
+			return err
+		}
+
+		if err := publishWithConfirm(ctx, ch, exchange, key, p); err != nil {
+			done(!isConnectionError(err))
+			mq.forgetMissingExchange(exchange, err)
+			return err
+		}
+		done(true)
+
+		return nil
+	})
+}
+
+func publishingFromDelivery(delivery amqp.Delivery, headers amqp.Table) amqp.Publishing {
+	return amqp.Publishing{
+		Headers:       headers,
+		ContentType:   delivery.ContentType,
+		DeliveryMode:  amqp.Persistent,
+		CorrelationId: delivery.CorrelationId,
+		MessageId:     delivery.MessageId,
+		Timestamp:     delivery.Timestamp,
+		Body:          delivery.Body,
+	}
+}
+
+func deadLetterFromDelivery(delivery amqp.Delivery) DeadLetter {
+	headers := stringHeaders(delivery.Headers)
+
+	return DeadLetter{
+		Message: Message{
+			Route: Route{
+				ExchangeName: headers[HeaderOriginalExchange],
+				RoutingKey:   headers[HeaderOriginalRoutingKey],
+			},
+			Body:          delivery.Body,
+			ContentType:   ContentType(delivery.ContentType),
+			MessageId:     delivery.MessageId,
+			CorrelationId: delivery.CorrelationId,
+			Timestamp:     delivery.Timestamp,
+			Headers:       headers,
+		},
+		Reason: headers[HeaderDeadLetterReason],
+	}
+}
+
+// stringHeaders returns all headers with string values.
+func stringHeaders(headers amqp.Table) map[string]string {
+	m := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if str, ok := v.(string); ok {
+			m[k] = str
+		}
+	}
+	return m
+}
+
+// deliveryCount returns the number of failed deliveries stored in the headers.
+func deliveryCount(headers amqp.Table) int {
+	switch v := headers[HeaderDeliveryCount].(type) {
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,98 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func Test_deliveryCount(t *testing.T) {
+	tests := []struct {
+		desc    string
+		headers amqp.Table
+		want    int
+	}{
+		{
+			desc:    "Test if returns 0 when header is missing",
+			headers: amqp.Table{},
+			want:    0,
+		},
+		{
+			desc:    "Test if returns count stored as int64",
+			headers: amqp.Table{HeaderDeliveryCount: int64(3)},
+			want:    3,
+		},
+		{
+			desc:    "Test if returns count stored as int32",
+			headers: amqp.Table{HeaderDeliveryCount: int32(2)},
+			want:    2,
+		},
+		{
+			desc:    "Test if returns 0 when header is not an integer",
+			headers: amqp.Table{HeaderDeliveryCount: "3"},
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := deliveryCount(tt.headers); got != tt.want {
+				t.Errorf("deliveryCount():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_deadLetterFromDelivery(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		desc     string
+		delivery amqp.Delivery
+		want     DeadLetter
+	}{
+		{
+			desc: "Test if reason and original route are extracted from headers",
+			delivery: amqp.Delivery{
+				Headers: amqp.Table{
+					HeaderDeadLetterReason:   "invalid body",
+					HeaderOriginalExchange:   "article",
+					HeaderOriginalRoutingKey: "article.event.created",
+					HeaderDeliveryCount:      int64(2),
+				},
+				ContentType:   string(ContentTypeJson),
+				MessageId:     "message-id",
+				CorrelationId: "correlation-id",
+				Timestamp:     now,
+				Body:          []byte("{"),
+			},
+			want: DeadLetter{
+				Message: Message{
+					Route: Route{
+						ExchangeName: "article",
+						RoutingKey:   "article.event.created",
+					},
+					Body:          []byte("{"),
+					ContentType:   ContentTypeJson,
+					MessageId:     "message-id",
+					CorrelationId: "correlation-id",
+					Timestamp:     now,
+					Headers: map[string]string{
+						HeaderDeadLetterReason:   "invalid body",
+						HeaderOriginalExchange:   "article",
+						HeaderOriginalRoutingKey: "article.event.created",
+					},
+				},
+				Reason: "invalid body",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := deadLetterFromDelivery(tt.delivery)
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("deadLetterFromDelivery():\n got = %+v\n want = %+v\n diff = %+v\n", got, tt.want, cmp.Diff(got, tt.want))
+			}
+		})
+	}
+}
@@ -24,5 +24,14 @@ Connection should be closed in order to shut it down gracefully.
 		rabbit := rabbitmq.NewRabbitMQ(consumer, user, pass, host, port, config, WithLogger(customLogger))
 		defer rabbit.Close()
 	}
+
+Use NewRabbitMQWithConnection in order to connect using a full AMQP URL
+or to specify a vhost, TLS and connection tuning, see ConnectionConfig.
+
+Lost connections are reestablished in the background with an exponential backoff, see WithBackoff.
+Use State and NotifyState in order to observe the connection, eg. in health checks.
+
+Exchanges, queues and bindings are declared once per connection and remembered until it is
+reestablished. Use DeclareTopology in order to declare them upfront.
 */
 package rabbitmq
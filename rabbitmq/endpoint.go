@@ -0,0 +1,111 @@
+package rabbitmq
+
+import (
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strconv"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// EndpointStrategy determines which endpoint is dialed when (re)connecting to a cluster.
+type EndpointStrategy int
+
+const (
+	// RoundRobin dials the next endpoint on every reconnect and after every failed dial.
+	// It is the default strategy.
+	RoundRobin EndpointStrategy = iota
+
+	// RandomEndpoint dials a random endpoint other than the previous one.
+	RandomEndpoint
+
+	// StickyUntilFail keeps reconnecting to the same endpoint and moves
+	// to the next one only after dialing it fails.
+	StickyUntilFail
+)
+
+func (s EndpointStrategy) String() string {
+	switch s {
+	case RoundRobin:
+		return "round_robin"
+	case RandomEndpoint:
+		return "random"
+	case StickyUntilFail:
+		return "sticky_until_fail"
+	default:
+		return "unknown"
+	}
+}
+
+// next returns the index of the endpoint to dial out of count endpoints given the index
+// of the previously dialed endpoint, which is negative on the first dial.
+// dialFailed reports whether dialing the previous endpoint failed
+// as opposed to losing an established connection.
+func (s EndpointStrategy) next(previous, count int, dialFailed bool) int {
+	if count <= 1 {
+		return 0
+	}
+
+	if previous < 0 {
+		if s == RandomEndpoint {
+			return rand.IntN(count)
+		}
+		return 0
+	}
+
+	switch s {
+	case RandomEndpoint:
+		i := rand.IntN(count - 1)
+		if i >= previous {
+			i++
+		}
+		return i
+	case StickyUntilFail:
+		if !dialFailed {
+			return previous
+		}
+		return (previous + 1) % count
+	default:
+		return (previous + 1) % count
+	}
+}
+
+// endpoint is a single broker the connection can be established with.
+type endpoint struct {
+	url     string // AMQP URI including credentials.
+	address string // Host and port safe to include in logs and spans.
+}
+
+// endpoints returns the endpoints described by the config, starting with the primary one.
+// Additional endpoints share the scheme, credentials and vhost of the primary one.
+func (c ConnectionConfig) endpoints() ([]endpoint, error) {
+	primary, err := c.URI()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(primary)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := append([]string{u.Host}, c.Endpoints...)
+	endpoints := make([]endpoint, 0, len(hosts))
+
+	for _, host := range hosts {
+		u.Host = host
+
+		uri, err := amqp.ParseURI(u.String())
+		if err != nil {
+			return nil, err
+		}
+
+		endpoints = append(endpoints, endpoint{
+			url:     u.String(),
+			address: net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port)),
+		})
+	}
+
+	return endpoints, nil
+}
@@ -2,6 +2,8 @@ package rabbitmq
 
 import (
 	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type ContentType string
@@ -9,15 +11,20 @@ type ContentType string
 const (
 	ContentTypeJson ContentType = "application/json"
 	ContentTypeText ContentType = "text/plain"
+
+	ContentTypeProtobuf ContentType = "application/x-protobuf"
+	ContentTypeMsgpack  ContentType = "application/msgpack"
 )
 
 type Message struct {
 	Route
 
-	Body        []byte
-	ContentType ContentType
-	Timestamp   time.Time
-	Headers     map[string]string
+	Body          []byte
+	ContentType   ContentType
+	MessageId     string // Carried in the message-id property.
+	CorrelationId string // Carried in the correlation-id property.
+	Timestamp     time.Time
+	Headers       map[string]string
 }
 
 type Route struct {
@@ -25,3 +32,48 @@ type Route struct {
 	ExchangeType string
 	RoutingKey   string
 }
+
+// Delivery is a consumed message which has to be settled by the consumer
+// using either Ack, Nack or Reject. Unsettled deliveries are redelivered by
+// the broker once the underlying AMQP channel or connection is closed.
+type Delivery struct {
+	Message
+
+	// Redelivered is true if the message was delivered before but was not acknowledged.
+	Redelivered bool
+
+	// DeliveryCount is the number of times the message was delivered including this delivery.
+	// Only requeues made through Nack and Reject on queues with dead-lettering enabled are counted.
+	DeliveryCount int
+
+	delivery amqp.Delivery
+	queue    string
+	mq       *RabbitMQ
+}
+
+// Ack acknowledges that the message was processed successfully and can be discarded by the broker.
+func (d Delivery) Ack() error {
+	return d.delivery.Ack(false)
+}
+
+// Nack negatively acknowledges the message. If requeue is true the broker
+// will try to redeliver the message, otherwise it is discarded or dead-lettered.
+// Messages exceeding the queue's max delivery count are dead-lettered regardless of requeue.
+func (d Delivery) Nack(requeue bool) error {
+	if config, ok := d.mq.opts.deadLetters[d.queue]; ok {
+		return d.requeueOrDeadLetter(config, requeue, "negatively acknowledged by the consumer")
+	}
+
+	return d.delivery.Nack(false, requeue)
+}
+
+// Reject rejects the message. If requeue is true the broker will try
+// to redeliver the message, otherwise it is discarded or dead-lettered.
+// Messages exceeding the queue's max delivery count are dead-lettered regardless of requeue.
+func (d Delivery) Reject(requeue bool) error {
+	if config, ok := d.mq.opts.deadLetters[d.queue]; ok {
+		return d.requeueOrDeadLetter(config, requeue, "rejected by the consumer")
+	}
+
+	return d.delivery.Reject(requeue)
+}
@@ -31,9 +31,6 @@ func (mq *RabbitMQ) tryToEnqueue(ctx context.Context, message Message, err error
 
 func (mq *RabbitMQ) publishPipelined(ctx context.Context, messages <-chan Message) {
 	go func() {
-		channel := mq.askForChannel()
-		defer channel.Close()
-
 		limiter := make(chan struct{}, mq.config.MaxWorkers)
 
 		for {
@@ -46,31 +43,23 @@ func (mq *RabbitMQ) publishPipelined(ctx context.Context, messages <-chan Messag
 					defer span.End()
 					defer func() { <-limiter }()
 
-					done, err := mq.breaker.Allow()
-					if err != nil {
-						tracing.SetSpanErr(span, err)
-						mq.tryToEnqueue(ctx, message, err, "Failed to publish msg")
-						return
-					}
-
 					p := amqp.Publishing{
-						ContentType: string(message.ContentType),
-						Body:        message.Body,
-						Timestamp:   message.Timestamp,
-						Headers:     extractAMQPHeadersFromCtx(ctx),
+						ContentType:   string(message.ContentType),
+						MessageId:     message.MessageId,
+						CorrelationId: message.CorrelationId,
+						Body:          message.Body,
+						Timestamp:     message.Timestamp,
+						Headers:       extractAMQPHeadersFromCtx(ctx),
 					}
 
-					if err := channel.PublishWithContext(ctx, message.ExchangeName, message.RoutingKey, false, false, p); err != nil {
+					if err := mq.publishRaw(ctx, message.ExchangeName, message.RoutingKey, p); err != nil {
 						tracing.SetSpanErr(span, err)
-						done(!isConnectionError(err))
 						mq.tryToEnqueue(ctx, message, err, "Failed to publish msg")
 						return
 					}
-					done(true)
 				}()
 
 			case <-ctx.Done():
-				channel.Close()
 				return
 			}
 		}
@@ -81,8 +70,6 @@ func (mq *RabbitMQ) prepareExchangePipelined(ctx context.Context, msgs <-chan Me
 	preparedMessages := make(chan Message)
 
 	go func() {
-		channel := mq.askForChannel()
-		defer channel.Close()
 		limiter := make(chan struct{}, mq.config.MaxWorkers)
 
 		for {
@@ -95,21 +82,11 @@ func (mq *RabbitMQ) prepareExchangePipelined(ctx context.Context, msgs <-chan Me
 					defer span.End()
 					defer func() { <-limiter }()
 
-					done, err := mq.breaker.Allow()
-					if err != nil {
-						done(!isConnectionError(err))
-						tracing.SetSpanErr(span, err)
-						mq.tryToEnqueue(ctx, message, err, "Failed to prepare exchange before publishing")
-						return
-					}
-
-					if err := channel.ExchangeDeclare(message.ExchangeName, message.ExchangeType, true, false, false, false, nil); err != nil {
-						done(!isConnectionError(err))
+					if err := mq.prepareExchange(ctx, message.Route); err != nil {
 						tracing.SetSpanErr(span, err)
 						mq.tryToEnqueue(ctx, message, err, "Failed to declare exchange")
 						return
 					}
-					done(true)
 
 					preparedMessages <- message
 				}()
@@ -0,0 +1,264 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ChannelPoolStats describes the usage of the channel pool.
+type ChannelPoolStats struct {
+	Size     int   // Max number of open pooled channels.
+	Open     int   // Number of open pooled channels.
+	InUse    int   // Number of channels currently borrowed.
+	Replaced int64 // Number of channels discarded after an exception or being closed.
+}
+
+// channelPool keeps a bounded number of channels for short-lived operations.
+// Channels are put in confirm mode if publisher confirms are enabled.
+// Borrowed channels are checked before being handed out and channels which were closed
+// or returned with an AMQP exception are discarded, so that a new one is opened in their place.
+// Channels returned with other errors, eg. an open circuit breaker or a cancelled context, are reused.
+// Channels closed by a not found exception reset the topology cache, since a declared
+// entity must have been deleted. This covers publishing without confirms, where
+// a missing exchange is reported only by closing the channel.
+type channelPool struct {
+	mq    *RabbitMQ
+	size  int
+	idle  chan *amqp.Channel // Open channels waiting to be borrowed.
+	slots chan struct{}      // Semaphore limiting the number of open channels.
+
+	mu       sync.Mutex
+	inUse    int
+	replaced int64
+
+	openCounter     metric.Int64UpDownCounter
+	inUseCounter    metric.Int64UpDownCounter
+	replacedCounter metric.Int64Counter
+	waitDuration    metric.Float64Histogram
+}
+
+func newChannelPool(mq *RabbitMQ, size int, meter metric.Meter) *channelPool {
+	p := &channelPool{
+		mq:    mq,
+		size:  size,
+		idle:  make(chan *amqp.Channel, size),
+		slots: make(chan struct{}, size),
+	}
+
+	errs := make([]error, 4)
+	p.openCounter, errs[0] = meter.Int64UpDownCounter("rabbitmq.channels.open", metric.WithDescription("Number of open pooled channels."))
+	p.inUseCounter, errs[1] = meter.Int64UpDownCounter("rabbitmq.channels.in_use", metric.WithDescription("Number of pooled channels currently borrowed."))
+	p.replacedCounter, errs[2] = meter.Int64Counter("rabbitmq.channels.replaced", metric.WithDescription("Number of pooled channels discarded after an exception."))
+	p.waitDuration, errs[3] = meter.Float64Histogram("rabbitmq.channels.wait", metric.WithDescription("Time spent waiting for a pooled channel."), metric.WithUnit("s"))
+
+	if err := errors.Join(errs...); err != nil {
+		mq.opts.logger.Log(context.Background(), "Failed to create channel pool metrics", "err", err)
+	}
+
+	return p
+}
+
+// get borrows a channel, opening a new one if there is no idle channel and the pool is not full.
+// It blocks until a channel is available or the context is cancelled.
+// Borrowed channels have to be returned using put.
+func (p *channelPool) get(ctx context.Context) (*amqp.Channel, error) {
+	start := time.Now()
+	defer func() {
+		p.waitDuration.Record(context.Background(), time.Since(start).Seconds())
+	}()
+
+	for {
+		// Reuse idle channels before opening new ones.
+		select {
+		case ch := <-p.idle:
+			if p.healthy(ch) {
+				return ch, nil
+			}
+			continue
+		default:
+		}
+
+		select {
+		case ch := <-p.idle:
+			if p.healthy(ch) {
+				return ch, nil
+			}
+
+		case p.slots <- struct{}{}:
+			ch, err := p.mq.openChannel(ctx, p.mq.opts.publisherConfirms)
+			if err != nil {
+				<-p.slots
+				return nil, err
+			}
+			go p.watch(ch.NotifyClose(make(chan *amqp.Error, 1)))
+			p.openCounter.Add(context.Background(), 1)
+			p.borrowed(1)
+			return ch, nil
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// healthy borrows the idle channel unless it was closed,
+// eg. by a channel exception or a connection loss, in which case it is discarded.
+func (p *channelPool) healthy(ch *amqp.Channel) bool {
+	if ch.IsClosed() {
+		p.discard()
+		return false
+	}
+
+	p.borrowed(1)
+	return true
+}
+
+// watch resets the topology cache if the channel is closed by a not found exception.
+func (p *channelPool) watch(closed <-chan *amqp.Error) {
+	if err := <-closed; err != nil && err.Code == amqp.NotFound {
+		p.mq.topology.reset()
+	}
+}
+
+// put returns a borrowed channel to the pool. Channels which were closed or used
+// by an operation which failed with an AMQP exception are discarded.
+func (p *channelPool) put(ch *amqp.Channel, err error) {
+	p.borrowed(-1)
+
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) || ch.IsClosed() {
+		ch.Close()
+		p.discard()
+		return
+	}
+
+	p.idle <- ch
+}
+
+// discard releases the slot of a channel which is no longer usable.
+func (p *channelPool) discard() {
+	<-p.slots
+
+	p.mu.Lock()
+	p.replaced++
+	p.mu.Unlock()
+
+	p.openCounter.Add(context.Background(), -1)
+	p.replacedCounter.Add(context.Background(), 1)
+}
+
+func (p *channelPool) borrowed(n int) {
+	p.mu.Lock()
+	p.inUse += n
+	p.mu.Unlock()
+
+	p.inUseCounter.Add(context.Background(), int64(n))
+}
+
+// close closes all idle channels.
+func (p *channelPool) close() {
+	for {
+		select {
+		case ch := <-p.idle:
+			ch.Close()
+			<-p.slots
+			p.openCounter.Add(context.Background(), -1)
+		default:
+			return
+		}
+	}
+}
+
+func (p *channelPool) stats() ChannelPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return ChannelPoolStats{
+		Size:     p.size,
+		Open:     len(p.slots),
+		InUse:    p.inUse,
+		Replaced: p.replaced,
+	}
+}
+
+// ChannelPoolStats returns the current usage of the channel pool.
+func (mq *RabbitMQ) ChannelPoolStats() ChannelPoolStats {
+	return mq.channels.stats()
+}
+
+// withChannel runs the operation on a pooled channel.
+// The channel is replaced if the operation fails with an AMQP exception or closes it.
+func (mq *RabbitMQ) withChannel(ctx context.Context, operation func(*amqp.Channel) error) error {
+	ch, err := mq.channels.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = operation(ch)
+	mq.channels.put(ch, err)
+
+	return err
+}
+
+// openChannel opens a new channel on the current connection, retrying until
+// it succeeds or the context is cancelled. The channel is put in confirm mode if requested.
+// Channels opened outside of the pool, eg. for consuming, have to be closed by the caller.
+func (mq *RabbitMQ) openChannel(ctx context.Context, confirm bool) (*amqp.Channel, error) {
+	for {
+		ch, err := mq.tryOpenChannel(ctx, confirm)
+		if err == nil {
+			return ch, nil
+		}
+
+		mq.opts.logger.Log(ctx, "Failed to open a new channel", "err", err)
+
+		select {
+		case <-time.After(mq.config.ReconnectInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (mq *RabbitMQ) tryOpenChannel(ctx context.Context, confirm bool) (_ *amqp.Channel, err error) {
+	_, span := mq.opts.tracer.Start(ctx, "rabbitmq.openChannel")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	mq.connMutex.Lock()
+	conn := mq.conn
+	mq.connMutex.Unlock()
+
+	if conn == nil || conn.IsClosed() {
+		return nil, errors.New("connection is not open")
+	}
+
+	done, err := mq.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		done(false)
+		return nil, err
+	}
+	done(true)
+
+	if !confirm {
+		return ch, nil
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	return ch, nil
+}
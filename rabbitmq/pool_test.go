@@ -0,0 +1,71 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sony/gobreaker"
+)
+
+func Test_channelPool_get(t *testing.T) {
+	t.Run("Test if waiting for a channel respects the context and releases the slot", func(t *testing.T) {
+		config := DefaultConfig()
+		config.ChannelPoolSize = 1
+		config.ReconnectInterval = time.Millisecond
+
+		mq := &RabbitMQ{config: config, opts: defaultOptions()}
+		mq.channels = newChannelPool(mq, config.ChannelPoolSize, mq.opts.meter)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+
+		if _, err := mq.channels.get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("channelPool.get():\n error = %v\n want = %v", err, context.DeadlineExceeded)
+			return
+		}
+
+		want := ChannelPoolStats{Size: 1}
+		if got := mq.ChannelPoolStats(); got != want {
+			t.Errorf("RabbitMQ.ChannelPoolStats():\n got = %+v\n want = %+v", got, want)
+		}
+	})
+}
+
+func Test_channelPool_put(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+	}{
+		{
+			desc: "Test if channel is reused after a successful operation",
+		},
+		{
+			desc: "Test if channel is reused after an operation cancelled by the context",
+			err:  context.Canceled,
+		},
+		{
+			desc: "Test if channel is reused after an operation rejected by the circuit breaker",
+			err:  gobreaker.ErrOpenState,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			mq := &RabbitMQ{config: DefaultConfig(), opts: defaultOptions()}
+			mq.channels = newChannelPool(mq, 1, mq.opts.meter)
+
+			// Borrow a channel as if it was opened by get.
+			mq.channels.slots <- struct{}{}
+			mq.channels.borrowed(1)
+
+			mq.channels.put(&amqp.Channel{}, tt.err)
+
+			want := ChannelPoolStats{Size: 1, Open: 1}
+			if got := mq.ChannelPoolStats(); got != want || len(mq.channels.idle) != 1 {
+				t.Errorf("RabbitMQ.ChannelPoolStats():\n got = %+v, idle = %d\n want = %+v, idle = 1", got, len(mq.channels.idle), want)
+			}
+		})
+	}
+}
@@ -2,8 +2,8 @@ package rabbitmq
 
 import (
 	"context"
+	"errors"
 
-	"github.com/krixlion/dev_forum-lib/chans"
 	"github.com/krixlion/dev_forum-lib/tracing"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel/trace"
@@ -12,7 +12,7 @@ import (
 func (mq *RabbitMQ) Publish(ctx context.Context, msg Message) (err error) {
 	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.Publish")
 	defer span.End()
-	defer tracing.SetSpanErr(span, err)
+	defer func() { tracing.SetSpanErr(span, err) }()
 
 	if err := mq.prepareExchange(ctx, msg.Route); err != nil {
 		return err
@@ -21,163 +21,335 @@ func (mq *RabbitMQ) Publish(ctx context.Context, msg Message) (err error) {
 	return mq.publish(ctx, msg)
 }
 
-// prepareExchange validates a message and declares a RabbitMQ exchange derived from the message.
+// prepareExchange declares a RabbitMQ exchange derived from the route
+// unless it was already declared on the current connection.
 func (mq *RabbitMQ) prepareExchange(ctx context.Context, route Route) (err error) {
+	generation, declared := mq.topology.lookup(exchangeKey(route))
+	if declared {
+		return nil
+	}
+
 	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.prepareExchange")
 	defer span.End()
-	defer tracing.SetSpanErr(span, err)
-
-	ch := mq.askForChannel()
-	defer ch.Close()
+	defer func() { tracing.SetSpanErr(span, err) }()
 
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	done, err := mq.breaker.Allow()
-	if err != nil {
-		return err
-	}
+	err = mq.withChannel(ctx, func(ch *amqp.Channel) error {
+		done, err := mq.breaker.Allow()
+		if err != nil {
+			return err
+		}
 
-	if err := ch.ExchangeDeclare(route.ExchangeName, route.ExchangeType, true, false, false, false, nil); err != nil {
-		done(!isConnectionError(err))
+		if err := ch.ExchangeDeclare(route.ExchangeName, route.ExchangeType, true, false, false, false, nil); err != nil {
+			done(!isConnectionError(err))
+			return err
+		}
+		done(true)
+
+		return nil
+	})
+	if err != nil {
 		return err
 	}
-	done(true)
 
+	mq.topology.remember(generation, exchangeKey(route))
 	return nil
 }
 
 func (mq *RabbitMQ) publish(ctx context.Context, msg Message) (err error) {
 	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.publish", trace.WithSpanKind(trace.SpanKindProducer))
 	defer span.End()
-	defer tracing.SetSpanErr(span, err)
-
-	ch := mq.askForChannel()
-	defer ch.Close()
+	defer func() { tracing.SetSpanErr(span, err) }()
 
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	done, err := mq.breaker.Allow()
-	if err != nil {
-		return err
-	}
-
 	p := amqp.Publishing{
-		ContentType: string(msg.ContentType),
-		Body:        msg.Body,
-		Timestamp:   msg.Timestamp,
-		Headers:     extractAMQPHeadersFromCtx(ctx),
+		ContentType:   string(msg.ContentType),
+		MessageId:     msg.MessageId,
+		CorrelationId: msg.CorrelationId,
+		Body:          msg.Body,
+		Timestamp:     msg.Timestamp,
+		Headers:       extractAMQPHeadersFromCtx(ctx),
 	}
 
-	if err := ch.PublishWithContext(ctx, msg.ExchangeName, msg.RoutingKey, false, false, p); err != nil {
-		done(!isConnectionError(err))
-		return err
+	return mq.publishRaw(ctx, msg.ExchangeName, msg.RoutingKey, p)
+}
+
+// Consume returns a channel receiving messages from the given queue bound to the routes.
+// Each message is acknowledged as soon as it is received from the returned channel,
+// so messages which are being handled when the process stops are lost. For at-least-once
+// processing use ConsumeDeliveries and acknowledge messages once they are handled.
+// Messages which were not received before the context got cancelled are requeued.
+// The returned channel is closed when the context is cancelled.
+func (mq *RabbitMQ) Consume(ctx context.Context, command string, route Route, routes ...Route) (<-chan Message, error) {
+	deliveries, err := mq.ConsumeDeliveries(ctx, command, route, routes...)
+	if err != nil {
+		return nil, err
 	}
 
-	done(true)
-	return nil
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+		for delivery := range deliveries {
+			select {
+			case messages <- delivery.Message:
+				if err := delivery.Ack(); err != nil {
+					mq.opts.logger.Log(ctx, "Failed to acknowledge message delivery", "err", err)
+				}
+			case <-ctx.Done():
+				if err := delivery.Nack(true); err != nil {
+					mq.opts.logger.Log(ctx, "Failed to requeue message delivery", "err", err)
+				}
+			}
+		}
+	}()
+
+	return messages, nil
 }
 
-func (mq *RabbitMQ) Consume(ctx context.Context, command string, route Route) (_ <-chan Message, err error) {
+// ConsumeDeliveries returns a channel receiving deliveries from the given queue bound to the routes.
+// Routing keys of the routes may contain wildcards supported by the exchange's type.
+// Every delivery has to be settled by the caller using its Ack, Nack or Reject methods.
+// The returned channel is closed when the context is cancelled.
+func (mq *RabbitMQ) ConsumeDeliveries(ctx context.Context, command string, route Route, routes ...Route) (_ <-chan Delivery, err error) {
 	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.Consume init")
 	defer span.End()
-	defer tracing.SetSpanErr(span, err)
+	defer func() { tracing.SetSpanErr(span, err) }()
 
-	messages := make(chan Message)
-	ch := mq.askForChannel()
+	out := make(chan Delivery)
+	routes = append([]Route{route}, routes...)
+
+	queue, err := mq.prepareQueue(ctx, command, routes)
+	if err != nil {
+		return nil, err
+	}
 
-	queue, err := mq.prepareQueue(ctx, command, route)
+	// Consumers hold their channel until the context is cancelled, so it is not pooled.
+	ch, err := mq.openChannel(ctx, false)
 	if err != nil {
 		return nil, err
 	}
 
 	done, err := mq.breaker.Allow()
 	if err != nil {
+		ch.Close()
 		return nil, err
 	}
 
-	deliveries, err := ch.ConsumeWithContext(ctx, queue.Name, mq.consumerName, false, false, false, false, nil)
+	if err := ch.Qos(mq.config.PrefetchCount, 0, false); err != nil {
+		done(!isConnectionError(err))
+		ch.Close()
+		return nil, err
+	}
+
+	deliveries, err := ch.ConsumeWithContext(ctx, queue, mq.consumerName, false, false, false, false, nil)
 	if err != nil {
 		done(!isConnectionError(err))
+		ch.Close()
 		return nil, err
 	}
 	done(true)
 
 	go func() {
+		defer close(out)
+		defer ch.Close()
 		for {
 			select {
-			case delivery := <-deliveries:
-				func() {
-					ctx := injectAMQPHeadersIntoCtx(context.Background(), delivery.Headers)
-					ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.Consume", trace.WithSpanKind(trace.SpanKindConsumer))
-					defer span.End()
-
-					if err := delivery.Ack(false); err != nil {
-						tracing.SetSpanErr(span, err)
-						mq.opts.logger.Log(ctx, "Failed to acknowledge message delivery", "err", err)
-						return
+			case delivery, ok := <-deliveries:
+				if !ok {
+					return
+				}
+
+				select {
+				case out <- mq.deliveryFromAMQP(queue, deliveryRoute(routes, delivery), delivery):
+				case <-ctx.Done():
+					if err := delivery.Nack(false, true); err != nil {
+						mq.opts.logger.Log(ctx, "Failed to requeue message delivery", "err", err)
 					}
-
-					message := Message{
-						Route:       route,
-						Body:        delivery.Body,
-						ContentType: ContentType(delivery.ContentType),
-						Timestamp:   delivery.Timestamp,
-						Headers:     tracing.ExtractMetadataFromContext(ctx),
-					}
-
-					chans.NonBlockSend(messages, message)
-				}()
+					return
+				}
 			case <-ctx.Done():
-				close(messages)
 				return
 			}
 		}
 	}()
 
-	return messages, nil
+	return out, nil
+}
+
+func (mq *RabbitMQ) deliveryFromAMQP(queue string, route Route, delivery amqp.Delivery) Delivery {
+	ctx := injectAMQPHeadersIntoCtx(context.Background(), delivery.Headers)
+	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.Consume", trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+
+	return Delivery{
+		Message: Message{
+			Route:         route,
+			Body:          delivery.Body,
+			ContentType:   ContentType(delivery.ContentType),
+			MessageId:     delivery.MessageId,
+			CorrelationId: delivery.CorrelationId,
+			Timestamp:     delivery.Timestamp,
+			Headers:       tracing.ExtractMetadataFromContext(ctx),
+		},
+		Redelivered:   delivery.Redelivered,
+		DeliveryCount: deliveryCount(delivery.Headers) + 1,
+		delivery:      delivery,
+		queue:         queue,
+		mq:            mq,
+	}
 }
 
-func (mq *RabbitMQ) prepareQueue(ctx context.Context, command string, route Route) (_ amqp.Queue, err error) {
+// deliveryRoute returns the route the delivery was received through.
+// The routing key is the one the message was originally published with, which differs
+// from the route's routing key if the latter contains wildcards.
+func deliveryRoute(routes []Route, delivery amqp.Delivery) Route {
+	exchange, key := delivery.Exchange, delivery.RoutingKey
+
+	// Requeued messages are republished directly to the queue.
+	if original, ok := delivery.Headers[HeaderOriginalExchange].(string); ok {
+		exchange = original
+		key, _ = delivery.Headers[HeaderOriginalRoutingKey].(string)
+	}
+
+	for _, r := range routes {
+		if r.ExchangeName == exchange {
+			r.RoutingKey = key
+			return r
+		}
+	}
+
+	return routes[0]
+}
+
+// prepareQueue declares the queue and binds it to the routes, skipping the queue,
+// exchanges and bindings which were already declared on the current connection.
+func (mq *RabbitMQ) prepareQueue(ctx context.Context, command string, routes []Route) (_ string, err error) {
 	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.prepareQueue")
 	defer span.End()
-	defer tracing.SetSpanErr(span, err)
+	defer func() { tracing.SetSpanErr(span, err) }()
 
-	ch := mq.askForChannel()
+	if err := mq.declareQueue(ctx, command); err != nil {
+		return "", err
+	}
 
-	done, err := mq.breaker.Allow()
-	if err != nil {
-		return amqp.Queue{}, err
+	for _, route := range routes {
+		if err := mq.prepareExchange(ctx, route); err != nil {
+			return "", err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
 
-	queue, err := ch.QueueDeclare(command, false, false, false, false, nil)
+	generation, _ := mq.topology.lookup(queueKey(command))
+
+	unbound := []Route{}
+	for _, route := range routes {
+		if _, declared := mq.topology.lookup(bindingKey(command, route)); !declared {
+			unbound = append(unbound, route)
+		}
+	}
+
+	if len(unbound) == 0 {
+		return command, nil
+	}
+
+	err = mq.withChannel(ctx, func(ch *amqp.Channel) error {
+		done, err := mq.breaker.Allow()
+		if err != nil {
+			return err
+		}
+
+		for _, route := range unbound {
+			if err := ch.QueueBind(command, route.RoutingKey, route.ExchangeName, false, nil); err != nil {
+				done(!isConnectionError(err))
+				return err
+			}
+		}
+		done(true)
+
+		return nil
+	})
 	if err != nil {
-		done(!isConnectionError(err))
-		return amqp.Queue{}, err
+		return "", err
 	}
-	done(true)
 
-	if err := ctx.Err(); err != nil {
-		return amqp.Queue{}, err
+	for _, route := range unbound {
+		mq.topology.remember(generation, bindingKey(command, route))
 	}
 
-	if err := mq.prepareExchange(ctx, route); err != nil {
-		return amqp.Queue{}, err
+	return command, nil
+}
+
+// declareQueue declares the queue consumed by the command together with its dead letter queue
+// unless it was already declared on the current connection.
+func (mq *RabbitMQ) declareQueue(ctx context.Context, queue string) error {
+	generation, declared := mq.topology.lookup(queueKey(queue))
+	if declared {
+		return nil
 	}
 
-	done, err = mq.breaker.Allow()
+	args, err := mq.prepareDeadLetterQueue(ctx, queue)
 	if err != nil {
-		return amqp.Queue{}, err
+		return err
 	}
 
-	if err := ch.QueueBind(queue.Name, route.RoutingKey, route.ExchangeName, false, nil); err != nil {
-		done(!isConnectionError(err))
-		return amqp.Queue{}, err
+	err = mq.withChannel(ctx, func(ch *amqp.Channel) error {
+		done, err := mq.breaker.Allow()
+		if err != nil {
+			return err
+		}
+
+		if _, err := ch.QueueDeclare(queue, false, false, false, false, args); err != nil {
+			done(!isConnectionError(err))
+			return err
+		}
+		done(true)
+
+		return nil
+	})
+	if args != nil && isPreconditionFailed(err) {
+		err = mq.declareExistingQueue(ctx, queue, err)
 	}
-	done(true)
+	if err != nil {
+		return err
+	}
+
+	mq.topology.remember(generation, queueKey(queue))
+	return nil
+}
+
+// declareExistingQueue makes sure the queue exists after it failed to be redeclared
+// with dead-letter arguments because it had been declared without them, see WithDeadLetter.
+// The queue is used as it is, since messages are dead-lettered by publishing them to
+// the dead letter exchange and do not rely on the queue's arguments.
+func (mq *RabbitMQ) declareExistingQueue(ctx context.Context, queue string, declareErr error) error {
+	mq.opts.logger.Log(ctx, "Queue exists with different arguments, messages rejected or expired outside of the library are not dead-lettered", "queue", queue, "err", declareErr)
+
+	return mq.withChannel(ctx, func(ch *amqp.Channel) error {
+		done, err := mq.breaker.Allow()
+		if err != nil {
+			return err
+		}
+
+		if _, err := ch.QueueDeclarePassive(queue, false, false, false, false, nil); err != nil {
+			done(!isConnectionError(err))
+			return err
+		}
+		done(true)
+
+		return nil
+	})
+}
 
-	return queue, nil
+func isPreconditionFailed(err error) bool {
+	var amqpErr *amqp.Error
+	return errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed
 }
@@ -0,0 +1,80 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func Test_deliveryRoute(t *testing.T) {
+	routes := []Route{
+		{ExchangeName: "article", ExchangeType: amqp.ExchangeTopic, RoutingKey: "article.event.*"},
+		{ExchangeName: "user", ExchangeType: amqp.ExchangeTopic, RoutingKey: "user.event.*"},
+	}
+
+	tests := []struct {
+		desc     string
+		delivery amqp.Delivery
+		want     Route
+	}{
+		{
+			desc:     "Test if returns the route of the delivery's exchange with the published routing key",
+			delivery: amqp.Delivery{Exchange: "user", RoutingKey: "user.event.deleted"},
+			want:     Route{ExchangeName: "user", ExchangeType: amqp.ExchangeTopic, RoutingKey: "user.event.deleted"},
+		},
+		{
+			desc: "Test if returns the original route of requeued deliveries",
+			delivery: amqp.Delivery{
+				Exchange:   "",
+				RoutingKey: "queue",
+				Headers: amqp.Table{
+					HeaderOriginalExchange:   "user",
+					HeaderOriginalRoutingKey: "user.event.created",
+				},
+			},
+			want: Route{ExchangeName: "user", ExchangeType: amqp.ExchangeTopic, RoutingKey: "user.event.created"},
+		},
+		{
+			desc:     "Test if returns the first route if the exchange is unknown",
+			delivery: amqp.Delivery{Exchange: "unknown", RoutingKey: "unknown.event.created"},
+			want:     routes[0],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := deliveryRoute(routes, tt.delivery); got != tt.want {
+				t.Errorf("deliveryRoute():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isPreconditionFailed(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{
+			desc: "Test if detects queues redeclared with different arguments",
+			err:  &amqp.Error{Code: amqp.PreconditionFailed, Server: true},
+			want: true,
+		},
+		{
+			desc: "Test if other exceptions are not detected",
+			err:  &amqp.Error{Code: amqp.NotFound, Server: true},
+			want: false,
+		},
+		{
+			desc: "Test if nil error is not detected",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := isPreconditionFailed(tt.err); got != tt.want {
+				t.Errorf("isPreconditionFailed():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,7 +15,7 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-func setUpMQ(t *testing.T) *rabbitmq.RabbitMQ {
+func setUpMQ(t *testing.T, opts ...rabbitmq.Option) *rabbitmq.RabbitMQ {
 	const consumer = "TESTING"
 	port := "5672"
 	host := "localhost"
@@ -38,7 +39,7 @@ func setUpMQ(t *testing.T) *rabbitmq.RabbitMQ {
 		ClosedTimeout:     time.Second * 15,
 		MaxWorkers:        10,
 	}
-	return rabbitmq.NewRabbitMQ(consumer, user, pass, host, port, config)
+	return rabbitmq.NewRabbitMQ(consumer, user, pass, host, port, config, opts...)
 }
 
 func TestPubSub(t *testing.T) {
@@ -49,6 +50,7 @@ func TestPubSub(t *testing.T) {
 	tests := []struct {
 		desc    string
 		msg     rabbitmq.Message
+		opts    []rabbitmq.Option
 		wantErr bool
 	}{
 		{
@@ -66,6 +68,39 @@ func TestPubSub(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			desc: "Test if a simple message is correctly published with publisher confirms and consumed.",
+			msg: rabbitmq.Message{
+				Body:        gentest.RandomJSONArticle(2, 5),
+				ContentType: rabbitmq.ContentTypeJson,
+				Timestamp:   time.Now().Round(time.Second),
+				Route: rabbitmq.Route{
+					ExchangeName: gentest.RandomString(7),
+					ExchangeType: amqp.ExchangeTopic,
+					RoutingKey:   "test.event." + strings.ToLower(gentest.RandomString(5)),
+				},
+				Headers: make(map[string]string),
+			},
+			opts:    []rabbitmq.Option{rabbitmq.WithPublisherConfirms()},
+			wantErr: false,
+		},
+		{
+			desc: "Test if message and correlation IDs are preserved.",
+			msg: rabbitmq.Message{
+				Body:          gentest.RandomJSONArticle(2, 5),
+				ContentType:   rabbitmq.ContentTypeJson,
+				MessageId:     gentest.RandomString(10),
+				CorrelationId: gentest.RandomString(10),
+				Timestamp:     time.Now().Round(time.Second),
+				Route: rabbitmq.Route{
+					ExchangeName: gentest.RandomString(7),
+					ExchangeType: amqp.ExchangeTopic,
+					RoutingKey:   "test.event." + strings.ToLower(gentest.RandomString(5)),
+				},
+				Headers: make(map[string]string),
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -73,7 +108,7 @@ func TestPubSub(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 			defer cancel()
 
-			mq := setUpMQ(t)
+			mq := setUpMQ(t, tt.opts...)
 			defer mq.Close()
 
 			err := mq.Publish(ctx, tt.msg)
@@ -117,3 +152,230 @@ func TestIfExchangeIsCreatedBeforeBindingQueue(t *testing.T) {
 		t.Errorf("RabbitMQ.Consume() error = %+v\n", err)
 	}
 }
+
+func TestDeadLetter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping dead letter integration test...")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	queue := gentest.RandomString(8)
+	mq := setUpMQ(t, rabbitmq.WithDeadLetter(queue, rabbitmq.DeadLetterConfig{MaxDeliveries: 2}))
+	defer mq.Close()
+
+	msg := rabbitmq.Message{
+		Body:        gentest.RandomJSONArticle(2, 5),
+		ContentType: rabbitmq.ContentTypeJson,
+		Timestamp:   time.Now().Round(time.Second),
+		Route: rabbitmq.Route{
+			ExchangeName: gentest.RandomString(7),
+			ExchangeType: amqp.ExchangeTopic,
+			RoutingKey:   "test.event." + strings.ToLower(gentest.RandomString(5)),
+		},
+		Headers: make(map[string]string),
+	}
+
+	consumeCtx, stopConsuming := context.WithCancel(ctx)
+	deliveries, err := mq.ConsumeDeliveries(consumeCtx, queue, msg.Route)
+	if err != nil {
+		stopConsuming()
+		t.Errorf("RabbitMQ.ConsumeDeliveries() error = %+v\n", err)
+		return
+	}
+
+	if err := mq.Publish(ctx, msg); err != nil {
+		stopConsuming()
+		t.Errorf("RabbitMQ.Publish() error = %+v\n", err)
+		return
+	}
+
+	// Requeue the message until it exceeds the max delivery count.
+	for i := 1; i <= 2; i++ {
+		delivery := <-deliveries
+		if delivery.DeliveryCount != i {
+			t.Errorf("Delivery.DeliveryCount:\n got = %d\n want = %d\n", delivery.DeliveryCount, i)
+		}
+
+		if err := delivery.Nack(true); err != nil {
+			t.Errorf("Delivery.Nack() error = %+v\n", err)
+		}
+	}
+	stopConsuming()
+
+	// Wait for the dead-lettered message to be routed.
+	time.Sleep(time.Millisecond * 100)
+
+	deadLetters, err := mq.InspectDeadLetters(ctx, queue, 10)
+	if err != nil {
+		t.Errorf("RabbitMQ.InspectDeadLetters() error = %+v\n", err)
+		return
+	}
+
+	if len(deadLetters) != 1 {
+		t.Errorf("RabbitMQ.InspectDeadLetters():\n got = %d messages\n want = 1 message\n", len(deadLetters))
+		return
+	}
+
+	got := deadLetters[0]
+	if got.Reason == "" || got.ExchangeName != msg.ExchangeName || got.RoutingKey != msg.RoutingKey || !cmp.Equal(got.Body, msg.Body) {
+		t.Errorf("RabbitMQ.InspectDeadLetters():\n got = %+v\n want route = %+v\n", got, msg.Route)
+	}
+
+	replayed, err := mq.ReplayDeadLetters(ctx, queue, 10)
+	if err != nil || replayed != 1 {
+		t.Errorf("RabbitMQ.ReplayDeadLetters():\n replayed = %d\n error = %+v\n", replayed, err)
+		return
+	}
+
+	msgs, err := mq.Consume(ctx, queue, msg.Route)
+	if err != nil {
+		t.Errorf("RabbitMQ.Consume() error = %+v\n", err)
+		return
+	}
+
+	if replayedMsg := <-msgs; !cmp.Equal(replayedMsg.Body, msg.Body) {
+		t.Errorf("Replayed message body is not equal:\n want = %+v\n got = %+v\n", msg.Body, replayedMsg.Body)
+	}
+}
+
+func TestPublishAfter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping scheduling integration test...")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mq := setUpMQ(t, rabbitmq.WithScheduling(time.Millisecond*100))
+	defer mq.Close()
+
+	route := rabbitmq.Route{
+		ExchangeName: gentest.RandomString(7),
+		ExchangeType: amqp.ExchangeTopic,
+		RoutingKey:   "test.event." + strings.ToLower(gentest.RandomString(5)),
+	}
+
+	msgs, err := mq.Consume(ctx, gentest.RandomString(8), route)
+	if err != nil {
+		t.Errorf("RabbitMQ.Consume() error = %+v\n", err)
+		return
+	}
+
+	scheduled := rabbitmq.Message{Route: route, Body: []byte(`"scheduled"`), ContentType: rabbitmq.ContentTypeJson, MessageId: gentest.RandomString(8)}
+	cancelled := rabbitmq.Message{Route: route, Body: []byte(`"cancelled"`), ContentType: rabbitmq.ContentTypeJson, MessageId: gentest.RandomString(8)}
+
+	for _, msg := range []rabbitmq.Message{cancelled, scheduled} {
+		if err := mq.PublishAfter(ctx, msg, time.Millisecond*500); err != nil {
+			t.Errorf("RabbitMQ.PublishAfter() error = %+v\n", err)
+			return
+		}
+	}
+
+	if err := mq.CancelScheduled(ctx, cancelled.MessageId); err != nil {
+		t.Errorf("RabbitMQ.CancelScheduled() error = %+v\n", err)
+		return
+	}
+
+	start := time.Now()
+	select {
+	case got := <-msgs:
+		if got.MessageId != scheduled.MessageId {
+			t.Errorf("Received message:\n got = %+v\n want = %+v\n", got, scheduled)
+		}
+
+		if elapsed := time.Since(start); elapsed < time.Millisecond*400 {
+			t.Errorf("Message was published too early, after %v", elapsed)
+		}
+	case <-ctx.Done():
+		t.Errorf("Scheduled message was not published")
+	}
+}
+
+func TestChannelPool(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping channel pool integration test...")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mq := setUpMQ(t)
+	defer mq.Close()
+
+	route := rabbitmq.Route{
+		ExchangeName: gentest.RandomString(7),
+		ExchangeType: amqp.ExchangeTopic,
+		RoutingKey:   "test.event." + strings.ToLower(gentest.RandomString(5)),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg := rabbitmq.Message{Route: route, Body: []byte(`"pooled"`), ContentType: rabbitmq.ContentTypeJson}
+			if err := mq.Publish(ctx, msg); err != nil {
+				t.Errorf("RabbitMQ.Publish() error = %+v\n", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := mq.ChannelPoolStats()
+	if stats.Open < 1 || stats.Open > stats.Size || stats.InUse != 0 {
+		t.Errorf("Invalid channel pool stats = %+v\n", stats)
+	}
+}
+
+func TestDeclareTopology(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping topology integration test...")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	mq := setUpMQ(t)
+	defer mq.Close()
+
+	queue := gentest.RandomString(8)
+	route := rabbitmq.Route{
+		ExchangeName: gentest.RandomString(7),
+		ExchangeType: amqp.ExchangeTopic,
+		RoutingKey:   "test.event." + strings.ToLower(gentest.RandomString(5)),
+	}
+
+	topology := rabbitmq.Topology{
+		Exchanges: []rabbitmq.Route{route},
+		Queues:    []string{queue},
+		Bindings:  []rabbitmq.Binding{{Queue: queue, Route: route}},
+	}
+
+	if err := mq.DeclareTopology(ctx, topology); err != nil {
+		t.Errorf("RabbitMQ.DeclareTopology() error = %+v\n", err)
+		return
+	}
+
+	msgs, err := mq.Consume(ctx, queue, route)
+	if err != nil {
+		t.Errorf("RabbitMQ.Consume() error = %+v\n", err)
+		return
+	}
+
+	msg := rabbitmq.Message{Route: route, Body: []byte(`"declared"`), ContentType: rabbitmq.ContentTypeJson}
+	if err := mq.Publish(ctx, msg); err != nil {
+		t.Errorf("RabbitMQ.Publish() error = %+v\n", err)
+		return
+	}
+
+	select {
+	case got := <-msgs:
+		if !cmp.Equal(got.Body, msg.Body) {
+			t.Errorf("Received message body:\n got = %+v\n want = %+v\n", got.Body, msg.Body)
+		}
+	case <-ctx.Done():
+		t.Errorf("Message was not received")
+	}
+}
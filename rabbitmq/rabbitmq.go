@@ -1,30 +1,49 @@
 package rabbitmq
 
 import (
+	"cmp"
 	"context"
-	"fmt"
+	"errors"
+	"net"
 	"slices"
 	"sync"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/krixlion/dev_forum-lib/tracing"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/sony/gobreaker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrNack is returned when the broker negatively acknowledges a published message.
+var ErrNack = errors.New("message was not accepted by the broker")
+
 type RabbitMQ struct {
 	consumerName string
 	shutdown     context.CancelFunc
 	config       Config
-	url          string // Connection string to RabbitMQ broker.
+	connection   ConnectionConfig // Settings used when dialing the broker.
+	endpoints    []endpoint       // Brokers the connection can be established with.
+	endpoint     int              // Index of the currently used endpoint, negative before the first dial.
 
 	conn      *amqp.Connection
+	closed    bool // Set by Close, after which no connection is established. Guarded by connMutex.
 	breaker   *gobreaker.TwoStepCircuitBreaker
 	connMutex sync.Mutex // Mutex protecting connection during reconnecting.
 
-	notifyConnClose chan *amqp.Error        // Channel to watch for errors from the broker in order to renew the connection.
-	publishQueue    chan Message            // Queue for messages waiting to be republished.
-	getChannel      chan chan *amqp.Channel // Access channel for accessing the RabbitMQ Channel in a thread-safe way.
+	notifyConnClose chan *amqp.Error // Channel to watch for errors from the broker in order to renew the connection.
+	publishQueue    chan Message     // Queue for messages waiting to be republished.
+	channels        *channelPool     // Pool of channels for short-lived operations.
+	topology        *topologyCache   // Exchanges, queues and bindings declared on the current connection.
+
+	stateMutex       sync.Mutex
+	state            State
+	stateSubscribers map[chan State]struct{} // Channels notified about state changes.
+
+	scheduleMutex sync.Mutex
+	cancelled     map[string]time.Time // Times scheduled messages were cancelled at keyed by message IDs.
 
 	opts options
 }
@@ -57,7 +76,63 @@ type RabbitMQ struct {
 //		defer rabbit.Close()
 //	}
 func NewRabbitMQ(consumer, user, pass, host, port string, config Config, opts ...Option) *RabbitMQ {
-	url := fmt.Sprintf("amqp://%s:%s@%s:%s/", user, pass, host, port)
+	connection := ConnectionConfig{
+		User:     user,
+		Password: pass,
+		Host:     host,
+		Port:     port,
+	}
+
+	endpoints, err := connection.endpoints()
+	if err != nil {
+		// Invalid addresses are reported by the background reconnects.
+		endpoints = []endpoint{{address: net.JoinHostPort(host, port)}}
+	}
+
+	return newRabbitMQ(consumer, endpoints, connection, config, opts...)
+}
+
+// NewRabbitMQWithConnection is like NewRabbitMQ but connects using the given connection config,
+// which allows to specify a full URL, vhost, TLS, connection tuning and cluster endpoints to fail over to.
+// Returns a non-nil error if the config does not describe a valid AMQP URI.
+//
+//	func example() {
+//		caPool, err := cert.LoadCaPool("/etc/rabbitmq/ca.crt")
+//		if err != nil {
+//			return err
+//		}
+//
+//		clientCert, err := cert.LoadX509KeyPair("/etc/rabbitmq/client.crt", "/etc/rabbitmq/client.key")
+//		if err != nil {
+//			return err
+//		}
+//
+//		connection := rabbitmq.ConnectionConfig{
+//			User:      "user-service",
+//			Password:  "p@ss/word",
+//			Host:      "rabbitmq-0.internal",
+//			VHost:     "forum",
+//			Endpoints: []string{"rabbitmq-1.internal:5671", "rabbitmq-2.internal:5671"},
+//			Heartbeat: time.Second * 5,
+//			TLS:       cert.NewClientTLSConfig(caPool, clientCert),
+//		}
+//
+//		rabbit, err := rabbitmq.NewRabbitMQWithConnection("user-service", connection, rabbitmq.DefaultConfig())
+//		if err != nil {
+//			return err
+//		}
+//		defer rabbit.Close()
+//	}
+func NewRabbitMQWithConnection(consumer string, connection ConnectionConfig, config Config, opts ...Option) (*RabbitMQ, error) {
+	endpoints, err := connection.endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	return newRabbitMQ(consumer, endpoints, connection, config, opts...), nil
+}
+
+func newRabbitMQ(consumer string, endpoints []endpoint, connection ConnectionConfig, config Config, opts ...Option) *RabbitMQ {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Make sure there is at least one worker.
@@ -65,16 +140,26 @@ func NewRabbitMQ(consumer, user, pass, host, port string, config Config, opts ..
 		config.MaxWorkers++
 	}
 
+	// A zero interval would make the reconnect loops spin while the broker is unavailable.
+	if config.ReconnectInterval <= 0 {
+		config.ReconnectInterval = defaultReconnectInterval
+	}
+
 	mq := &RabbitMQ{
-		consumerName:    consumer,
-		url:             url,
-		shutdown:        cancel,
-		config:          config,
-		connMutex:       sync.Mutex{},
-		publishQueue:    make(chan Message, config.QueueSize),
-		getChannel:      make(chan chan *amqp.Channel),
-		notifyConnClose: make(chan *amqp.Error, 16),
-		opts:            defaultOptions(),
+		consumerName:     consumer,
+		connection:       connection,
+		endpoints:        endpoints,
+		endpoint:         -1,
+		shutdown:         cancel,
+		config:           config,
+		connMutex:        sync.Mutex{},
+		publishQueue:     make(chan Message, config.QueueSize),
+		notifyConnClose:  make(chan *amqp.Error, 16),
+		state:            Connecting,
+		stateSubscribers: make(map[chan State]struct{}),
+		topology:         newTopologyCache(),
+		cancelled:        make(map[string]time.Time),
+		opts:             defaultOptions(),
 		breaker: gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
 			Name:        consumer,
 			MaxRequests: config.MaxRequests,
@@ -87,6 +172,16 @@ func NewRabbitMQ(consumer, user, pass, host, port string, config Config, opts ..
 		opt.apply(&mq.opts)
 	}
 
+	mq.channels = newChannelPool(mq, cmp.Or(config.ChannelPoolSize, config.MaxWorkers), mq.opts.meter)
+
+	if mq.opts.backoff == nil {
+		mq.opts.backoff = backoff.NewExponentialBackOff(
+			backoff.WithInitialInterval(config.ReconnectInterval),
+			backoff.WithMaxInterval(cmp.Or(config.MaxReconnectInterval, defaultMaxReconnectInterval)),
+			backoff.WithMaxElapsedTime(0),
+		)
+	}
+
 	defer mq.run(ctx)
 	return mq
 }
@@ -98,17 +193,32 @@ func (mq *RabbitMQ) run(ctx context.Context) {
 	mq.opts.logger.Log(ctx, "Connecting to RabbitMQ")
 	mq.reDial(ctx)
 
+	// The connection was closed before it could be established.
+	if ctx.Err() != nil {
+		return
+	}
+
 	go mq.runPublishQueue(ctx)
 	go mq.handleConnectionErrors(ctx)
-	go mq.handleChannelPropagation(ctx)
+
+	if mq.opts.scheduling {
+		go mq.forwardScheduled(ctx)
+	}
 }
 
 // Close closes active connection gracefully.
 func (mq *RabbitMQ) Close() error {
 	mq.shutdown()
+	mq.setState(Closed)
+	mq.channels.close()
 
-	if mq.conn != nil && !mq.conn.IsClosed() {
-		return mq.conn.Close()
+	mq.connMutex.Lock()
+	mq.closed = true
+	conn := mq.conn
+	mq.connMutex.Unlock()
+
+	if conn != nil && !conn.IsClosed() {
+		return conn.Close()
 	}
 
 	return nil
@@ -120,42 +230,6 @@ func (mq *RabbitMQ) runPublishQueue(ctx context.Context) {
 	mq.publishPipelined(ctx, preparedMessages)
 }
 
-// handleChannelPropagation is meant to be run in a separate goroutine.
-func (mq *RabbitMQ) handleChannelPropagation(ctx context.Context) {
-	limiter := make(chan struct{}, mq.config.MaxWorkers)
-	for {
-		select {
-		case req := <-mq.getChannel:
-			limiter <- struct{}{}
-			go func() {
-				ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.handleChannelRead")
-				defer span.End()
-				defer func() { <-limiter }()
-				done, err := mq.breaker.Allow()
-				if err != nil {
-					req <- nil
-					tracing.SetSpanErr(span, err)
-					return
-				}
-
-				channel, err := mq.conn.Channel()
-				if err != nil {
-					req <- nil
-					mq.opts.logger.Log(ctx, "Failed to open a new channel", "err", err)
-					done(false)
-					tracing.SetSpanErr(span, err)
-					return
-				}
-
-				done(true)
-				req <- channel
-			}()
-		case <-ctx.Done():
-			return
-		}
-	}
-}
-
 // handleConnectionErrors is meant to be run in a separate goroutine.
 func (mq *RabbitMQ) handleConnectionErrors(ctx context.Context) {
 	for {
@@ -164,6 +238,8 @@ func (mq *RabbitMQ) handleConnectionErrors(ctx context.Context) {
 			if e == nil {
 				continue
 			}
+			mq.opts.logger.Log(ctx, "Lost connection to RabbitMQ", "err", e)
+			mq.setState(Disconnected)
 			mq.reDial(ctx)
 
 		case <-ctx.Done():
@@ -172,68 +248,133 @@ func (mq *RabbitMQ) handleConnectionErrors(ctx context.Context) {
 	}
 }
 
-// reDial will keep reconecting until it succeeds.
+// Endpoint returns the "host:port" address of the endpoint currently used by the connection.
+func (mq *RabbitMQ) Endpoint() string {
+	mq.connMutex.Lock()
+	defer mq.connMutex.Unlock()
+
+	if mq.endpoint < 0 {
+		return ""
+	}
+
+	return mq.endpoints[mq.endpoint].address
+}
+
+// reDial will keep reconecting until it succeeds, the context is cancelled or
+// the backoff policy gives up, in which case the connection is closed.
+// Endpoints are dialed according to the configured EndpointStrategy.
 func (mq *RabbitMQ) reDial(ctx context.Context) {
 	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.ReDial")
 	defer span.End()
 
-	for {
-		if err := ctx.Err(); err != nil {
-			return
+	mq.setState(Connecting)
+
+	mq.connMutex.Lock()
+	previous := mq.endpoint
+	mq.connMutex.Unlock()
+
+	dialFailed := false
+	operation := func() error {
+		i := mq.connection.EndpointStrategy.next(previous, len(mq.endpoints), dialFailed)
+		if previous >= 0 && i != previous {
+			from, to := mq.endpoints[previous].address, mq.endpoints[i].address
+			span.AddEvent("failover", trace.WithAttributes(
+				attribute.String("rabbitmq.endpoint.from", from),
+				attribute.String("rabbitmq.endpoint.to", to),
+			))
+			mq.opts.logger.Log(ctx, "Failing over to another RabbitMQ endpoint", "from", from, "to", to)
 		}
 
-		err := mq.dial(ctx)
-		if err == nil {
-			return
+		if err := mq.dial(ctx, i); err != nil {
+			previous, dialFailed = i, true
+			tracing.SetSpanErr(span, err)
+			mq.opts.logger.Log(ctx, "Failed to connect to RabbitMQ", "err", err, "endpoint", mq.endpoints[i].address)
+			return err
 		}
 
-		tracing.SetSpanErr(span, err)
-		mq.opts.logger.Log(ctx, "Failed to connect to RabbitMQ", "err", err)
+		span.SetAttributes(attribute.String("rabbitmq.endpoint", mq.endpoints[i].address))
+		return nil
+	}
+
+	notify := func(_ error, wait time.Duration) {
+		mq.opts.logger.Log(ctx, "Reconnecting to RabbitMQ", "in", wait)
+	}
+
+	if err := backoff.RetryNotify(operation, backoff.WithContext(mq.opts.backoff, ctx), notify); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
 
-		time.Sleep(mq.config.ReconnectInterval)
-		mq.opts.logger.Log(ctx, "Reconnecting to RabbitMQ")
+		mq.opts.logger.Log(ctx, "Gave up reconnecting to RabbitMQ", "err", err)
+		mq.Close()
 	}
 }
 
-// dial renews current TCP connection.
-func (mq *RabbitMQ) dial(ctx context.Context) (err error) {
-	_, span := mq.opts.tracer.Start(ctx, "rabbitmq.dial")
+// dial renews current TCP connection using the endpoint with given index.
+func (mq *RabbitMQ) dial(ctx context.Context, i int) (err error) {
+	_, span := mq.opts.tracer.Start(ctx, "rabbitmq.dial", trace.WithAttributes(
+		attribute.String("rabbitmq.endpoint", mq.endpoints[i].address),
+	))
 	defer span.End()
-	defer tracing.SetSpanErr(span, err)
+	defer func() { tracing.SetSpanErr(span, err) }()
 
 	done, err := mq.breaker.Allow()
 	if err != nil {
 		return err
 	}
 
-	conn, err := amqp.Dial(mq.url)
+	conn, err := amqp.DialConfig(mq.endpoints[i].url, mq.connection.amqpConfig())
 	if err != nil {
 		done(!isConnectionError(err))
 		return err
 	}
 	done(true)
 
+	// The broker might have lost non-durable entities or be another cluster node.
+	// The cache is reset before the connection is used, so that nothing declared
+	// on the new connection is forgotten.
+	mq.topology.reset()
+
 	mq.connMutex.Lock()
+	if mq.closed {
+		// Close was called while dialing and would not close the new connection.
+		mq.connMutex.Unlock()
+		conn.Close()
+		return errors.New("connection was closed while dialing")
+	}
 	mq.notifyConnClose = conn.NotifyClose(mq.notifyConnClose)
 	mq.conn = conn
+	mq.endpoint = i
 	mq.connMutex.Unlock()
 
+	mq.setState(Connected)
+
 	return nil
 }
 
-// askForChannel returns a *amqp.Channel in a thread-safe way.
-func (mq *RabbitMQ) askForChannel() *amqp.Channel {
-	for {
-		ask := make(chan *amqp.Channel)
-		mq.getChannel <- ask
+// publishWithConfirm publishes the message on the given channel. If the channel is in
+// confirm mode it waits for the broker's confirmation and returns ErrNack on a nack.
+func publishWithConfirm(ctx context.Context, ch *amqp.Channel, exchange, key string, p amqp.Publishing) error {
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, p)
+	if err != nil {
+		return err
+	}
 
-		channel := <-ask
-		if channel != nil {
-			return channel
-		}
+	// Confirmation is nil if the channel is not in confirm mode.
+	if confirmation == nil {
+		return nil
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
 
-		time.Sleep(mq.config.ReconnectInterval)
+	if !acked {
+		return ErrNack
 	}
+
+	return nil
 }
 
 func isConnectionError(e error) bool {
@@ -0,0 +1,503 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/krixlion/dev_forum-lib/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers set on scheduled messages.
+const (
+	HeaderScheduledExchange     = "x-scheduled-exchange"      // Exchange the message is published to once it is due.
+	HeaderScheduledExchangeType = "x-scheduled-exchange-type" // Type of the exchange the message is published to.
+	HeaderScheduledRoutingKey   = "x-scheduled-routing-key"   // Routing key the message is published with once it is due.
+	HeaderScheduledAt           = "x-scheduled-at"            // Time the message is due at in Unix milliseconds.
+)
+
+var ErrSchedulingDisabled = errors.New("scheduling is not enabled, see WithScheduling")
+
+// delayBuckets is the number of delay queues. Their delays are the scheduling precision
+// multiplied by consecutive powers of two, starting from 1.
+const delayBuckets = 20
+
+// cancellationRetention is the time cancellations of scheduled messages are kept for.
+const cancellationRetention = 7 * 24 * time.Hour
+
+// cancellationMarker is the type of messages published to the cancelled stream
+// by instances in order to find out when they caught up with it.
+const cancellationMarker = "marker"
+
+// ScheduledQueueName returns the name of the queue storing scheduled messages which are due.
+func ScheduledQueueName(consumer string) string {
+	return consumer + ".scheduled"
+}
+
+// cancelledStreamName returns the name of the stream storing cancellations
+// of scheduled messages for all instances sharing the consumer name.
+func cancelledStreamName(consumer string) string {
+	return consumer + ".scheduled.cancelled"
+}
+
+// delayQueueName returns the name of the queue holding messages of the consumer for the given delay.
+func delayQueueName(consumer string, delay time.Duration) string {
+	return consumer + ".delay." + strconv.FormatInt(delay.Milliseconds(), 10)
+}
+
+// delayBucket returns the delay of the longest delay queue not exceeding the given delay.
+// Delays shorter than the precision are rounded up to it.
+func delayBucket(delay, precision time.Duration) time.Duration {
+	bucket := precision
+	for i := 1; i < delayBuckets && bucket*2 <= delay; i++ {
+		bucket *= 2
+	}
+	return bucket
+}
+
+// PublishAfter publishes the message after the given delay. See PublishAt.
+func (mq *RabbitMQ) PublishAfter(ctx context.Context, msg Message, delay time.Duration) error {
+	return mq.PublishAt(ctx, msg, time.Now().Add(delay))
+}
+
+// PublishAt publishes the message at the given time, up to the scheduling precision later.
+// Messages scheduled in the past are published immediately. The message has to have an ID
+// in order to be cancellable. Returns ErrSchedulingDisabled unless WithScheduling is used.
+func (mq *RabbitMQ) PublishAt(ctx context.Context, msg Message, at time.Time) (err error) {
+	if !mq.opts.scheduling {
+		return ErrSchedulingDisabled
+	}
+
+	if msg.MessageId == "" {
+		return errors.New("scheduled message has no ID")
+	}
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return mq.Publish(ctx, msg)
+	}
+
+	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.PublishAt")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	headers := extractAMQPHeadersFromCtx(ctx)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderScheduledExchange] = msg.ExchangeName
+	headers[HeaderScheduledExchangeType] = msg.ExchangeType
+	headers[HeaderScheduledRoutingKey] = msg.RoutingKey
+	headers[HeaderScheduledAt] = at.UnixMilli()
+
+	p := amqp.Publishing{
+		Headers:       headers,
+		ContentType:   string(msg.ContentType),
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     msg.MessageId,
+		CorrelationId: msg.CorrelationId,
+		Timestamp:     msg.Timestamp,
+		Body:          msg.Body,
+	}
+
+	return mq.delay(ctx, p, delay)
+}
+
+// delay publishes the message to the longest delay queue not exceeding the delay.
+// Messages which are not due once they leave the queue are delayed again.
+func (mq *RabbitMQ) delay(ctx context.Context, p amqp.Publishing, delay time.Duration) error {
+	queue, err := mq.prepareDelayQueue(ctx, delayBucket(delay, mq.opts.schedulingPrecision))
+	if err != nil {
+		return err
+	}
+
+	return mq.publishRaw(ctx, "", queue, p)
+}
+
+// CancelScheduled cancels publishing of the scheduled message with the given ID,
+// no matter which instance sharing the consumer name scheduled it.
+// The message stays in its delay queue and is discarded once it is due.
+// Cancelling a message which is not scheduled has no effect.
+//
+// Cancellations are stored in the "<consumer>.scheduled.cancelled" stream, which every
+// instance reads from the beginning when it starts forwarding scheduled messages,
+// so that cancellations survive restarts. The stream keeps cancellations for 7 days,
+// so messages due later than that after being cancelled are published by instances
+// started in the meantime. Streams require RabbitMQ 3.9 or newer.
+func (mq *RabbitMQ) CancelScheduled(ctx context.Context, id string) (err error) {
+	if !mq.opts.scheduling {
+		return ErrSchedulingDisabled
+	}
+
+	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.CancelScheduled")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	now := time.Now()
+	mq.markCancelled(id, now)
+
+	if err := mq.prepareCancelledStream(ctx); err != nil {
+		return err
+	}
+
+	return mq.publishRaw(ctx, "", cancelledStreamName(mq.consumerName), amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		MessageId:    id,
+		Timestamp:    now,
+	})
+}
+
+// markCancelled remembers that the scheduled message was cancelled at given time.
+func (mq *RabbitMQ) markCancelled(id string, at time.Time) {
+	mq.scheduleMutex.Lock()
+	defer mq.scheduleMutex.Unlock()
+
+	mq.pruneCancelled(time.Now())
+	mq.cancelled[id] = at
+}
+
+// pruneCancelled forgets cancellations which are older than cancellationRetention,
+// the same way the cancelled stream does. Must be called with the scheduleMutex held.
+func (mq *RabbitMQ) pruneCancelled(now time.Time) {
+	maps.DeleteFunc(mq.cancelled, func(_ string, at time.Time) bool {
+		return now.Sub(at) > cancellationRetention
+	})
+}
+
+// prepareCancelledStream declares the stream storing cancellations unless it was already declared.
+func (mq *RabbitMQ) prepareCancelledStream(ctx context.Context) error {
+	stream := cancelledStreamName(mq.consumerName)
+	generation, declared := mq.topology.lookup(queueKey(stream))
+	if declared {
+		return nil
+	}
+
+	err := mq.withChannel(ctx, func(ch *amqp.Channel) error {
+		done, err := mq.breaker.Allow()
+		if err != nil {
+			return err
+		}
+
+		if err := declareCancelledStream(ch, stream); err != nil {
+			done(!isConnectionError(err))
+			return err
+		}
+		done(true)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	mq.topology.remember(generation, queueKey(stream))
+	return nil
+}
+
+func declareCancelledStream(ch *amqp.Channel, stream string) error {
+	_, err := ch.QueueDeclare(stream, true, false, false, false, amqp.Table{
+		"x-queue-type": "stream",
+		"x-max-age":    strconv.Itoa(int(cancellationRetention.Hours())) + "h",
+	})
+	return err
+}
+
+// prepareDelayQueue declares the queue holding messages for the given delay
+// and the queue the messages are dead-lettered to once their TTL expires.
+// Delay queues are deleted by the broker once they are not used for longer than their delay.
+func (mq *RabbitMQ) prepareDelayQueue(ctx context.Context, delay time.Duration) (_ string, err error) {
+	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.prepareDelayQueue")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var queue amqp.Queue
+	err = mq.withChannel(ctx, func(ch *amqp.Channel) (err error) {
+		done, err := mq.breaker.Allow()
+		if err != nil {
+			return err
+		}
+
+		if _, err := ch.QueueDeclare(ScheduledQueueName(mq.consumerName), true, false, false, false, nil); err != nil {
+			done(!isConnectionError(err))
+			return err
+		}
+
+		ttl := delay.Milliseconds()
+		queue, err = ch.QueueDeclare(delayQueueName(mq.consumerName, delay), true, false, false, false, amqp.Table{
+			"x-message-ttl":             ttl,
+			"x-expires":                 ttl + time.Minute.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": ScheduledQueueName(mq.consumerName),
+		})
+		if err != nil {
+			done(!isConnectionError(err))
+			return err
+		}
+		done(true)
+
+		return nil
+	})
+
+	return queue.Name, err
+}
+
+// forwardScheduled is meant to be run in a separate goroutine.
+// It consumes due messages and publishes them to their exchanges until the context is cancelled.
+func (mq *RabbitMQ) forwardScheduled(ctx context.Context) {
+	for {
+		if err := mq.consumeScheduled(ctx); err != nil {
+			mq.opts.logger.Log(ctx, "Failed to consume scheduled messages", "err", err)
+		}
+
+		select {
+		case <-time.After(mq.config.ReconnectInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// consumeScheduled forwards due messages and receives cancellations stored by all instances
+// until the channel is closed or the context is cancelled.
+func (mq *RabbitMQ) consumeScheduled(ctx context.Context) error {
+	ch, err := mq.openChannel(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	queue := ScheduledQueueName(mq.consumerName)
+
+	done, err := mq.breaker.Allow()
+	if err != nil {
+		return err
+	}
+
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+		done(!isConnectionError(err))
+		return err
+	}
+
+	cancellations, err := mq.consumeCancellations(ctx, ch)
+	if err != nil {
+		done(!isConnectionError(err))
+		return err
+	}
+
+	deliveries, err := ch.ConsumeWithContext(ctx, queue, mq.consumerName, false, false, false, false, nil)
+	if err != nil {
+		done(!isConnectionError(err))
+		return err
+	}
+	done(true)
+
+	// Due messages must not be forwarded before earlier cancellations are known.
+	if err := mq.catchUpCancellations(ctx, cancellations); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case cancellation, ok := <-cancellations:
+			if !ok {
+				return nil
+			}
+			mq.receiveCancellation(ctx, cancellation)
+
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return nil
+			}
+
+			mq.receivePendingCancellations(ctx, cancellations)
+
+			if err := mq.forward(ctx, delivery); err != nil {
+				mq.opts.logger.Log(ctx, "Failed to forward scheduled message", "err", err, "id", delivery.MessageId)
+			}
+		}
+	}
+}
+
+// consumeCancellations reads the cancelled stream from the beginning.
+// The prefetch count set on the channel applies to due messages consumed afterwards as well.
+func (mq *RabbitMQ) consumeCancellations(ctx context.Context, ch *amqp.Channel) (<-chan amqp.Delivery, error) {
+	stream := cancelledStreamName(mq.consumerName)
+	if err := declareCancelledStream(ch, stream); err != nil {
+		return nil, err
+	}
+
+	// Streams can be consumed only with a prefetch count.
+	if err := ch.Qos(mq.config.MaxWorkers, 0, false); err != nil {
+		return nil, err
+	}
+
+	return ch.ConsumeWithContext(ctx, stream, "", false, false, false, false, amqp.Table{
+		"x-stream-offset": "first",
+	})
+}
+
+// catchUpCancellations publishes a marker to the cancelled stream and receives
+// cancellations until the marker is received, so that cancellations stored before
+// this instance started consuming are known.
+func (mq *RabbitMQ) catchUpCancellations(ctx context.Context, cancellations <-chan amqp.Delivery) error {
+	marker, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+
+	if err := mq.publishRaw(ctx, "", cancelledStreamName(mq.consumerName), amqp.Publishing{
+		Type:      cancellationMarker,
+		MessageId: marker.String(),
+		Timestamp: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case cancellation, ok := <-cancellations:
+			if !ok {
+				return errors.New("cancelled stream was closed")
+			}
+
+			if mq.receiveCancellation(ctx, cancellation) == marker.String() {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// receivePendingCancellations receives cancellations which are ready without blocking,
+// so that they are applied before the next due message is forwarded.
+func (mq *RabbitMQ) receivePendingCancellations(ctx context.Context, cancellations <-chan amqp.Delivery) {
+	for {
+		select {
+		case cancellation, ok := <-cancellations:
+			if !ok {
+				return
+			}
+			mq.receiveCancellation(ctx, cancellation)
+		default:
+			return
+		}
+	}
+}
+
+// receiveCancellation applies the cancellation read from the cancelled stream.
+// Markers are not applied, their IDs are returned instead.
+func (mq *RabbitMQ) receiveCancellation(ctx context.Context, delivery amqp.Delivery) (marker string) {
+	if err := delivery.Ack(false); err != nil {
+		mq.opts.logger.Log(ctx, "Failed to acknowledge scheduled message cancellation", "err", err, "id", delivery.MessageId)
+	}
+
+	if delivery.Type == cancellationMarker {
+		return delivery.MessageId
+	}
+
+	at := delivery.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	mq.markCancelled(delivery.MessageId, at)
+	return ""
+}
+
+// forward publishes the due message to its exchange unless it was cancelled.
+// Messages which are not due yet are moved to the next delay queue.
+func (mq *RabbitMQ) forward(ctx context.Context, delivery amqp.Delivery) (err error) {
+	ctx, span := mq.opts.tracer.Start(injectAMQPHeadersIntoCtx(ctx, delivery.Headers), "rabbitmq.forwardScheduled")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	mq.scheduleMutex.Lock()
+	_, cancelled := mq.cancelled[delivery.MessageId]
+	delete(mq.cancelled, delivery.MessageId)
+	mq.scheduleMutex.Unlock()
+
+	if cancelled {
+		return delivery.Ack(false)
+	}
+
+	if delay := time.Until(scheduledAt(delivery.Headers)); delay > 0 {
+		if err := mq.delay(ctx, publishingFromDelivery(delivery, withoutDeathHeaders(delivery.Headers)), delay); err != nil {
+			mq.requeueLater(ctx, delivery)
+			return err
+		}
+
+		return delivery.Ack(false)
+	}
+
+	route, headers := scheduledRoute(delivery.Headers)
+	if err := mq.prepareExchange(ctx, route); err != nil {
+		mq.requeueLater(ctx, delivery)
+		return err
+	}
+
+	if err := mq.publishRaw(ctx, route.ExchangeName, route.RoutingKey, publishingFromDelivery(delivery, headers)); err != nil {
+		mq.requeueLater(ctx, delivery)
+		return err
+	}
+
+	return delivery.Ack(false)
+}
+
+// requeueLater requeues the message after the reconnect interval, so that messages
+// which fail to be forwarded are not redelivered in a tight loop. Other messages
+// are forwarded in the meantime.
+func (mq *RabbitMQ) requeueLater(ctx context.Context, delivery amqp.Delivery) {
+	time.AfterFunc(mq.config.ReconnectInterval, func() {
+		if err := delivery.Nack(false, true); err != nil {
+			mq.opts.logger.Log(ctx, "Failed to requeue scheduled message", "err", err, "id", delivery.MessageId)
+		}
+	})
+}
+
+// scheduledRoute returns the route stored in the headers of a scheduled message
+// together with the headers it should be published with.
+func scheduledRoute(headers amqp.Table) (Route, amqp.Table) {
+	str := stringHeaders(headers)
+	route := Route{
+		ExchangeName: str[HeaderScheduledExchange],
+		ExchangeType: str[HeaderScheduledExchangeType],
+		RoutingKey:   str[HeaderScheduledRoutingKey],
+	}
+
+	headers = withoutDeathHeaders(headers)
+	maps.DeleteFunc(headers, func(k string, _ any) bool {
+		return strings.HasPrefix(k, "x-scheduled-")
+	})
+
+	return route, headers
+}
+
+// scheduledAt returns the time the scheduled message is due at.
+// Messages without the due time are due immediately.
+func scheduledAt(headers amqp.Table) time.Time {
+	at, ok := headers[HeaderScheduledAt].(int64)
+	if !ok {
+		return time.Time{}
+	}
+	return time.UnixMilli(at)
+}
+
+// withoutDeathHeaders returns a copy of the headers without the ones set by the broker
+// when the message was dead-lettered.
+func withoutDeathHeaders(headers amqp.Table) amqp.Table {
+	headers = maps.Clone(headers)
+	maps.DeleteFunc(headers, func(k string, _ any) bool {
+		return k == "x-death" || strings.HasPrefix(k, "x-first-death-") || strings.HasPrefix(k, "x-last-death-")
+	})
+	return headers
+}
@@ -0,0 +1,138 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func Test_scheduledRoute(t *testing.T) {
+	t.Run("Test if returns the scheduled route and strips scheduling headers", func(t *testing.T) {
+		headers := amqp.Table{
+			HeaderScheduledExchange:     "article",
+			HeaderScheduledExchangeType: amqp.ExchangeTopic,
+			HeaderScheduledRoutingKey:   "article.event.created",
+			"x-death":                   []any{},
+			"x-first-death-queue":       "test.delay.1000",
+			HeaderScheduledAt:           int64(1000),
+			"traceparent":               "test",
+		}
+
+		route, got := scheduledRoute(headers)
+
+		wantRoute := Route{ExchangeName: "article", ExchangeType: amqp.ExchangeTopic, RoutingKey: "article.event.created"}
+		if route != wantRoute {
+			t.Errorf("scheduledRoute():\n got = %v\n want = %v", route, wantRoute)
+			return
+		}
+
+		want := amqp.Table{"traceparent": "test"}
+		if !cmp.Equal(got, want) {
+			t.Errorf("scheduledRoute():\n got = %v\n want = %v", got, want)
+		}
+	})
+}
+
+func Test_delayBucket(t *testing.T) {
+	tests := []struct {
+		desc  string
+		delay time.Duration
+		want  time.Duration
+	}{
+		{
+			desc:  "Test if delay shorter than the precision is rounded up to it",
+			delay: time.Millisecond * 10,
+			want:  time.Second,
+		},
+		{
+			desc:  "Test if returns the longest bucket not exceeding the delay",
+			delay: time.Second*5 + time.Millisecond,
+			want:  time.Second * 4,
+		},
+		{
+			desc:  "Test if delay longer than the longest bucket returns the longest bucket",
+			delay: time.Hour * 24 * 365,
+			want:  time.Second << (delayBuckets - 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := delayBucket(tt.delay, time.Second); got != tt.want {
+				t.Errorf("delayBucket():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_scheduledAt(t *testing.T) {
+	t.Run("Test if returns the due time stored in the headers", func(t *testing.T) {
+		want := time.Now().Truncate(time.Millisecond)
+		if got := scheduledAt(amqp.Table{HeaderScheduledAt: want.UnixMilli()}); !got.Equal(want) {
+			t.Errorf("scheduledAt():\n got = %v\n want = %v", got, want)
+		}
+	})
+
+	t.Run("Test if message without the due time is due immediately", func(t *testing.T) {
+		if got := scheduledAt(amqp.Table{}); !got.IsZero() {
+			t.Errorf("scheduledAt():\n got = %v\n want = %v", got, time.Time{})
+		}
+	})
+}
+
+func TestRabbitMQ_receiveCancellation(t *testing.T) {
+	t.Run("Test if cancellations stored by other instances are applied", func(t *testing.T) {
+		mq := &RabbitMQ{
+			cancelled: map[string]time.Time{},
+			opts:      defaultOptions(),
+		}
+		at := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+		cancellations := make(chan amqp.Delivery, 2)
+		cancellations <- amqp.Delivery{MessageId: "1", Timestamp: at}
+		cancellations <- amqp.Delivery{MessageId: "2"}
+
+		mq.receivePendingCancellations(context.Background(), cancellations)
+
+		if got, ok := mq.cancelled["1"]; !ok || !got.Equal(at) {
+			t.Errorf("RabbitMQ.receiveCancellation():\n got = %v\n want = %v", got, at)
+			return
+		}
+
+		if _, ok := mq.cancelled["2"]; !ok {
+			t.Errorf("RabbitMQ.receiveCancellation(): cancellation with no timestamp was not applied")
+		}
+	})
+
+	t.Run("Test if markers are returned instead of being applied", func(t *testing.T) {
+		mq := &RabbitMQ{
+			cancelled: map[string]time.Time{},
+			opts:      defaultOptions(),
+		}
+
+		marker := mq.receiveCancellation(context.Background(), amqp.Delivery{MessageId: "1", Type: cancellationMarker})
+		if marker != "1" {
+			t.Errorf("RabbitMQ.receiveCancellation():\n got = %v\n want = %v", marker, "1")
+			return
+		}
+
+		if _, ok := mq.cancelled["1"]; ok {
+			t.Errorf("RabbitMQ.receiveCancellation(): marker was applied as a cancellation")
+		}
+	})
+
+	t.Run("Test if cancellations older than the retention are forgotten", func(t *testing.T) {
+		mq := &RabbitMQ{
+			cancelled: map[string]time.Time{"old": time.Now().Add(-cancellationRetention - time.Minute)},
+			opts:      defaultOptions(),
+		}
+
+		mq.receiveCancellation(context.Background(), amqp.Delivery{MessageId: "1"})
+
+		if _, ok := mq.cancelled["old"]; ok {
+			t.Errorf("RabbitMQ.receiveCancellation(): cancellation older than the retention was not forgotten")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package rabbitmq
+
+// State is the state of the connection to the broker.
+type State int
+
+const (
+	// Connecting means the connection is being established, possibly after a connection loss.
+	Connecting State = iota
+
+	// Connected means the connection is established.
+	Connected
+
+	// Disconnected means the connection was lost and will be reestablished.
+	Disconnected
+
+	// Closed means the connection was closed using Close or the backoff policy gave up reconnecting.
+	// It is the final state.
+	Closed
+)
+
+func (s State) String() string {
+	switch s {
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	case Disconnected:
+		return "disconnected"
+	case Closed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the current state of the connection.
+func (mq *RabbitMQ) State() State {
+	mq.stateMutex.Lock()
+	defer mq.stateMutex.Unlock()
+
+	return mq.state
+}
+
+// NotifyState returns a channel receiving the current state of the connection
+// followed by every change of it, and a func unsubscribing from the changes.
+// The channel holds only the latest state, so that slow readers never miss the current one.
+// It is closed after unsubscribing or once the connection is closed.
+func (mq *RabbitMQ) NotifyState() (<-chan State, func()) {
+	mq.stateMutex.Lock()
+	defer mq.stateMutex.Unlock()
+
+	states := make(chan State, 1)
+	states <- mq.state
+
+	if mq.state == Closed {
+		close(states)
+		return states, func() {}
+	}
+
+	mq.stateSubscribers[states] = struct{}{}
+
+	unsubscribe := func() {
+		mq.stateMutex.Lock()
+		defer mq.stateMutex.Unlock()
+
+		if _, ok := mq.stateSubscribers[states]; ok {
+			delete(mq.stateSubscribers, states)
+			close(states)
+		}
+	}
+
+	return states, unsubscribe
+}
+
+// setState changes the state and notifies the subscribers.
+// Closed state is final and cannot be changed.
+func (mq *RabbitMQ) setState(state State) {
+	mq.stateMutex.Lock()
+	defer mq.stateMutex.Unlock()
+
+	if mq.state == Closed || mq.state == state {
+		return
+	}
+	mq.state = state
+
+	for states := range mq.stateSubscribers {
+		// Replace the state the subscriber did not read yet.
+		select {
+		case <-states:
+		default:
+		}
+		states <- state
+
+		if state == Closed {
+			delete(mq.stateSubscribers, states)
+			close(states)
+		}
+	}
+}
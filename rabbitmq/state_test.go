@@ -0,0 +1,98 @@
+package rabbitmq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRabbitMQ_NotifyState(t *testing.T) {
+	t.Run("Test if subscribers receive the latest state and are closed with the connection", func(t *testing.T) {
+		mq := &RabbitMQ{state: Connecting, stateSubscribers: make(map[chan State]struct{})}
+
+		states, unsubscribe := mq.NotifyState()
+		defer unsubscribe()
+
+		if got := <-states; got != Connecting {
+			t.Errorf("RabbitMQ.NotifyState():\n got = %v\n want = %v", got, Connecting)
+			return
+		}
+
+		// Only the latest unread state is kept.
+		mq.setState(Connected)
+		mq.setState(Disconnected)
+		if got := <-states; got != Disconnected {
+			t.Errorf("RabbitMQ.NotifyState():\n got = %v\n want = %v", got, Disconnected)
+			return
+		}
+
+		mq.setState(Closed)
+		mq.setState(Connected)
+
+		got := []State{}
+		for state := range states {
+			got = append(got, state)
+		}
+
+		if want := []State{Closed}; !cmp.Equal(got, want) {
+			t.Errorf("RabbitMQ.NotifyState():\n got = %v\n want = %v", got, want)
+			return
+		}
+
+		if mq.State() != Closed {
+			t.Errorf("RabbitMQ.State():\n got = %v\n want = %v", mq.State(), Closed)
+		}
+	})
+
+	t.Run("Test if unsubscribing closes the channel", func(t *testing.T) {
+		mq := &RabbitMQ{state: Connected, stateSubscribers: make(map[chan State]struct{})}
+
+		states, unsubscribe := mq.NotifyState()
+		unsubscribe()
+		unsubscribe()
+		mq.setState(Disconnected)
+
+		got := []State{}
+		for state := range states {
+			got = append(got, state)
+		}
+
+		if want := []State{Connected}; !cmp.Equal(got, want) {
+			t.Errorf("RabbitMQ.NotifyState():\n got = %v\n want = %v", got, want)
+		}
+	})
+}
+
+func TestRabbitMQ_reDial(t *testing.T) {
+	t.Run("Test if connection is closed when the backoff policy gives up", func(t *testing.T) {
+		connection := ConnectionConfig{User: "guest", Password: "guest", Host: "127.0.0.1", Port: "1"}
+		mq, err := NewRabbitMQWithConnection("test", connection, DefaultConfig(), WithBackoff(backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Millisecond), 2)))
+		if err != nil {
+			t.Errorf("NewRabbitMQWithConnection() error = %v", err)
+			return
+		}
+		defer mq.Close()
+
+		if got := mq.State(); got != Closed {
+			t.Errorf("RabbitMQ.State():\n got = %v\n want = %v", got, Closed)
+		}
+	})
+	t.Run("Test if zero reconnect interval defaults to a positive one", func(t *testing.T) {
+		connection := ConnectionConfig{User: "guest", Password: "guest", Host: "127.0.0.1", Port: "1"}
+		config := DefaultConfig()
+		config.ReconnectInterval = 0
+
+		mq, err := NewRabbitMQWithConnection("test", connection, config, WithBackoff(backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Millisecond), 2)))
+		if err != nil {
+			t.Errorf("NewRabbitMQWithConnection() error = %v", err)
+			return
+		}
+		defer mq.Close()
+
+		if got := mq.config.ReconnectInterval; got != defaultReconnectInterval {
+			t.Errorf("RabbitMQ.config.ReconnectInterval:\n got = %v\n want = %v", got, defaultReconnectInterval)
+		}
+	})
+}
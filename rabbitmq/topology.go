@@ -0,0 +1,140 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+
+	"github.com/krixlion/dev_forum-lib/tracing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Topology describes exchanges, queues and bindings to declare upfront using DeclareTopology.
+type Topology struct {
+	Exchanges []Route   // Exchanges to declare, routing keys are ignored.
+	Queues    []string  // Queues to declare the same way Consume does, including their dead letter queues.
+	Bindings  []Binding // Bindings to declare together with their queues and exchanges.
+}
+
+// Binding binds a queue to a route.
+type Binding struct {
+	Queue string
+	Route Route
+}
+
+// DeclareTopology declares the given exchanges, queues and bindings unless they were
+// already declared on the current connection, so that publishing and consuming
+// does not have to declare them.
+// Declared entities are remembered until the connection is reestablished
+// or a channel is closed because one of them does not exist.
+func (mq *RabbitMQ) DeclareTopology(ctx context.Context, topology Topology) (err error) {
+	ctx, span := mq.opts.tracer.Start(ctx, "rabbitmq.DeclareTopology")
+	defer span.End()
+	defer func() { tracing.SetSpanErr(span, err) }()
+
+	for _, route := range topology.Exchanges {
+		if err := mq.prepareExchange(ctx, route); err != nil {
+			return err
+		}
+	}
+
+	for _, queue := range topology.Queues {
+		if err := mq.declareQueue(ctx, queue); err != nil {
+			return err
+		}
+	}
+
+	for _, binding := range topology.Bindings {
+		if _, err := mq.prepareQueue(ctx, binding.Queue, []Route{binding.Route}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// topologyKey identifies a declared exchange, queue or binding.
+type topologyKey struct {
+	kind         string // "exchange", "queue" or "binding".
+	name         string // Name of the exchange or queue.
+	exchangeType string // Type of the exchange.
+	exchange     string // Exchange the queue is bound to.
+	routingKey   string // Routing key the queue is bound with.
+}
+
+func exchangeKey(route Route) topologyKey {
+	return topologyKey{kind: "exchange", name: route.ExchangeName, exchangeType: route.ExchangeType}
+}
+
+func queueKey(queue string) topologyKey {
+	return topologyKey{kind: "queue", name: queue}
+}
+
+func bindingKey(queue string, route Route) topologyKey {
+	return topologyKey{kind: "binding", name: queue, exchange: route.ExchangeName, routingKey: route.RoutingKey}
+}
+
+// topologyCache remembers exchanges, queues and bindings declared on the current connection.
+// Declarations started before the cache was reset are not remembered, since they might
+// have been made on the previous connection.
+type topologyCache struct {
+	mu         sync.Mutex
+	generation uint64 // Incremented on every reset.
+	declared   map[topologyKey]struct{}
+}
+
+func newTopologyCache() *topologyCache {
+	return &topologyCache{
+		declared: make(map[topologyKey]struct{}),
+	}
+}
+
+// lookup reports whether the entity was declared and returns
+// the generation a following declaration should be remembered with.
+func (c *topologyCache) lookup(key topologyKey) (generation uint64, declared bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, declared = c.declared[key]
+	return c.generation, declared
+}
+
+// remember marks the entity as declared unless the cache was reset since the given generation.
+func (c *topologyCache) remember(generation uint64, key topologyKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation == c.generation {
+		c.declared[key] = struct{}{}
+	}
+}
+
+// forgetExchange forgets the exchange with given name and the bindings to it.
+func (c *topologyCache) forgetExchange(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	maps.DeleteFunc(c.declared, func(key topologyKey, _ struct{}) bool {
+		return key.kind == "exchange" && key.name == name || key.kind == "binding" && key.exchange == name
+	})
+}
+
+// reset forgets all declared entities.
+func (c *topologyCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.declared)
+}
+
+// forgetMissingExchange forgets the exchange if the error indicates it does not exist,
+// eg. because it was deleted after being declared. Such errors are returned only when
+// publisher confirms are enabled, otherwise the pool resets the cache once the channel is closed.
+func (mq *RabbitMQ) forgetMissingExchange(exchange string, err error) {
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+		mq.topology.forgetExchange(exchange)
+	}
+}
@@ -0,0 +1,148 @@
+package rabbitmq
+
+import (
+	"errors"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func Test_topologyCache(t *testing.T) {
+	route := Route{ExchangeName: "article", ExchangeType: amqp.ExchangeTopic, RoutingKey: "article.event.*"}
+
+	tests := []struct {
+		desc   string
+		modify func(c *topologyCache, generation uint64)
+		key    topologyKey
+		want   bool
+	}{
+		{
+			desc:   "Test if remembers declared exchange",
+			modify: func(c *topologyCache, generation uint64) { c.remember(generation, exchangeKey(route)) },
+			key:    exchangeKey(route),
+			want:   true,
+		},
+		{
+			desc:   "Test if exchange of another type is not declared",
+			modify: func(c *topologyCache, generation uint64) { c.remember(generation, exchangeKey(route)) },
+			key:    exchangeKey(Route{ExchangeName: "article", ExchangeType: amqp.ExchangeDirect}),
+			want:   false,
+		},
+		{
+			desc: "Test if forgets everything on reset",
+			modify: func(c *topologyCache, generation uint64) {
+				c.remember(generation, queueKey("test"))
+				c.reset()
+			},
+			key:  queueKey("test"),
+			want: false,
+		},
+		{
+			desc: "Test if declarations started before reset are not remembered",
+			modify: func(c *topologyCache, generation uint64) {
+				c.reset()
+				c.remember(generation, queueKey("test"))
+			},
+			key:  queueKey("test"),
+			want: false,
+		},
+		{
+			desc: "Test if forgets bindings to a forgotten exchange",
+			modify: func(c *topologyCache, generation uint64) {
+				c.remember(generation, bindingKey("test", route))
+				c.forgetExchange(route.ExchangeName)
+			},
+			key:  bindingKey("test", route),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			c := newTopologyCache()
+			generation, _ := c.lookup(tt.key)
+
+			tt.modify(c, generation)
+
+			if _, got := c.lookup(tt.key); got != tt.want {
+				t.Errorf("topologyCache.lookup():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRabbitMQ_forgetMissingExchange(t *testing.T) {
+	route := Route{ExchangeName: "article", ExchangeType: amqp.ExchangeTopic}
+
+	tests := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{
+			desc: "Test if forgets the exchange on not found error",
+			err:  &amqp.Error{Code: amqp.NotFound, Server: true},
+			want: false,
+		},
+		{
+			desc: "Test if remembers the exchange on other errors",
+			err:  errors.New("test"),
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			mq := &RabbitMQ{topology: newTopologyCache()}
+			mq.topology.remember(0, exchangeKey(route))
+
+			mq.forgetMissingExchange(route.ExchangeName, tt.err)
+
+			if _, got := mq.topology.lookup(exchangeKey(route)); got != tt.want {
+				t.Errorf("topologyCache.lookup():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_channelPool_watch(t *testing.T) {
+	route := Route{ExchangeName: "article", ExchangeType: amqp.ExchangeTopic}
+
+	tests := []struct {
+		desc string
+		err  *amqp.Error
+		want bool
+	}{
+		{
+			desc: "Test if resets the topology when channel is closed by a not found exception",
+			err:  &amqp.Error{Code: amqp.NotFound, Server: true},
+			want: false,
+		},
+		{
+			desc: "Test if keeps the topology when channel is closed by other exceptions",
+			err:  &amqp.Error{Code: amqp.ChannelError, Server: true},
+			want: true,
+		},
+		{
+			desc: "Test if keeps the topology when channel is closed gracefully",
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			mq := &RabbitMQ{topology: newTopologyCache()}
+			mq.channels = newChannelPool(mq, 1, defaultOptions().meter)
+			mq.topology.remember(0, exchangeKey(route))
+
+			closed := make(chan *amqp.Error, 1)
+			if tt.err != nil {
+				closed <- tt.err
+			}
+			close(closed)
+
+			mq.channels.watch(closed)
+
+			if _, got := mq.topology.lookup(exchangeKey(route)); got != tt.want {
+				t.Errorf("topologyCache.lookup():\n got = %v\n want = %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -22,14 +22,5 @@ func extractAMQPHeadersFromCtx(ctx context.Context) amqp.Table {
 // injectAMQPHeadersIntoCtx extracts the trace data from the header and puts it
 // into the returned context. Any extracted non-string values are discarded.
 func injectAMQPHeadersIntoCtx(ctx context.Context, headers amqp.Table) context.Context {
-	m := make(map[string]string, len(headers))
-	for k, v := range headers {
-		str, ok := v.(string)
-		if !ok {
-			continue
-		}
-		m[k] = str
-	}
-
-	return tracing.InjectMetadataIntoContext(ctx, m)
+	return tracing.InjectMetadataIntoContext(ctx, stringHeaders(headers))
 }
@@ -0,0 +1,61 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krixlion/dev_forum-lib/event"
+	"github.com/krixlion/dev_forum-lib/event/dispatcher"
+)
+
+// TestDispatcher_Shutdown runs under goleak.VerifyTestMain, which fails
+// if any goroutine started by the dispatcher outlives its shutdown.
+func TestDispatcher_Shutdown(t *testing.T) {
+	provider := make(chan event.Event)
+	started := make(chan struct{}, 10)
+	handled := make(chan struct{}, 10)
+
+	d := dispatcher.NewDispatcher(5)
+	d.AddEventProviders(provider)
+	d.SubscribeContext(event.ArticleCreated, event.ContextHandlerFunc(func(ctx context.Context, e event.Event) error {
+		started <- struct{}{}
+		time.Sleep(time.Millisecond * 10)
+		handled <- struct{}{}
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(stopped)
+	}()
+
+	for i := 0; i < 10; i++ {
+		provider <- event.Event{Type: event.ArticleCreated}
+	}
+	close(provider)
+
+	// Shut down while the handlers are still in flight.
+	for i := 0; i < 10; i++ {
+		<-started
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Second)
+	defer cancelShutdown()
+
+	abandoned, err := d.Shutdown(shutdownCtx)
+	if err != nil || abandoned != 0 {
+		t.Errorf("Dispatcher.Shutdown():\n abandoned = %v\n error = %v", abandoned, err)
+		return
+	}
+
+	<-stopped
+
+	if len(handled) != 10 {
+		t.Errorf("Shutdown did not wait for in-flight handlers:\n got = %v\n want = %v", len(handled), 10)
+	}
+}